@@ -1,13 +1,19 @@
 package main
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+
+	"xgit/apps/patch/gitops"
 )
 
 // ApplyOnce：增加 patchFile 参数用于从文件头读取 repo: 兜底（拿不到可传 ""）
-func ApplyOnce(logger *DualLogger, repo string, patch *Patch, patchFile string) {
+// git 为注入的 Git 后端（生产用 gitops.NewExecBackend()，测试可用 gitops.FakeBackend）
+func ApplyOnce(logger *DualLogger, repo string, patch *Patch, patchFile string, git gitops.GitBackend) {
 	// 0) 解析真实仓库路径（优先 Patch.Repo，其次补丁头 repo:，最后 .repos 的 default）
 	patchDir := "."
 	if strings.TrimSpace(patchFile) != "" {
@@ -69,27 +75,117 @@ func ApplyOnce(logger *DualLogger, repo string, patch *Patch, patchFile string)
 			}
 		}
 	}
+	// 任务日志（WAL）：中途被杀时，下次启动可据此回滚或续跑
+	if old, _ := loadJournal(patchDir); old != nil {
+		logf("❌ 存在未能回滚的中断任务 job=%s（%s），工作区状态未知，暂不执行新补丁；重启 patchd 后将重试回滚", old.Job, old.Repo)
+		return
+	}
+	preHead, _ := git.RevParse(repo, "HEAD")
+	text, _ := os.ReadFile(patchFile)
+	sum := md5.Sum(text)
+	jr, jerr := newJournal(patchDir, hex.EncodeToString(sum[:])[:8], repo, preHead, patch.Recover, text)
+	if jerr != nil {
+		logf("⚠️ 任务日志不可用：%v（中断后将无法自动恢复）", jerr)
+		jr = nil
+	}
+	defer jr.finish()
+
+	runBatch(logger, repo, patch, git, jr, 0)
+}
+
+// runBatch 从第 start 条指令（0-based）开始执行 → 提交前校验 → 提交 → 推送；返回是否成功
+// start > 0 表示续跑中断的任务：不清理工作区（保留已完成指令的结果）
+func runBatch(logger *DualLogger, repo string, patch *Patch, git gitops.GitBackend, jr *Journal, start int) bool {
+	log := func(format string, a ...any) {
+		if logger != nil {
+			logger.Log(format, a...)
+		}
+	}
+	logf := func(format string, a ...any) { log(format, a...) }
+
+	hasCommit := false
+	for _, op := range patch.Ops {
+		if op.Cmd == "git.commit" {
+			hasCommit = true
+		}
+	}
+	// 补丁头 idempotent: 作为各指令的缺省幂等模式（指令自身的 idempotent= 优先）
+	if patch.Idempotent != "" {
+		for _, op := range patch.Ops {
+			if _, ok := op.Args["idempotent"]; !ok {
+				op.Args["idempotent"] = patch.Idempotent
+			}
+		}
+	}
 	opts := TxnOpts{
-		CleanAtStart:    !hasCommit, // 有 git.commit 就不要清理工作区
-		RollbackOnError: true,       // 失败仍然回滚（按你现有策略）
+		CleanAtStart:    !hasCommit && start == 0, // 有 git.commit / 续跑时不要清理工作区
+		RollbackOnError: true,                     // 失败仍然回滚（按你现有策略）
+	}
+	// 基线固定：base: 必须存在于目标仓库；HEAD 已前进时逐文件校验/合并
+	bi, err := resolveBase(repo, patch, git)
+	if err != nil {
+		logf("❌ %v", err)
+		return false
+	}
+	if bi.stale {
+		logf("⚠️ base %s 已落后于 HEAD，逐文件校验目标是否被修改", shortSHA(bi.sha))
+	}
+	// 路径安全：任一指令越出仓库 / 触及受保护路径 → 整批拒绝
+	if err := guardBatch(repo, patch.Ops, start, logf); err != nil {
+		logf("❌ %v", err)
+		return false
+	}
+	// 提交前校验取自批前 HEAD（续跑时为任务日志记录的 preHead），补丁改不了本批的闸门
+	checksRef, _ := git.RevParse(repo, "HEAD")
+	if jr != nil && jr.PreHead != "" {
+		checksRef = jr.PreHead
+	}
+	checks, err := LoadChecks(repo, checksRef, git)
+	if err != nil {
+		logf("❌ 读取 %s 失败：%v", checksFile, err)
+		return false
 	}
 	// 事务阶段
-	err = WithGitTxnOpts(repo, logf, opts, func() error {
-		// 1) 先应用所有指令
-		for i, op := range patch.Ops {
+	err = WithGitTxnOpts(repo, git, logf, opts, func() error {
+		// 0) 记录目标文件的批前快照：同一文件的后续指令不会因前序指令的改动被判为 stale
+		bi.snapshot(repo, patch.Ops[start:], git)
+		// 1) 先应用所有指令（每条前后写任务日志）
+		for i := start; i < len(patch.Ops); i++ {
+			op := patch.Ops[i]
 			tag := fmt.Sprintf("%s #%d", op.Cmd, i+1)
-			if e := applyOp(repo, op, logger); e != nil {
+			if e := jr.beginOp(i, op, git); e != nil {
+				logf("❌ 写任务日志失败：%v", e)
+				return e
+			}
+			if e := applyOpTargets(repo, op, patch, bi, git, logger); e != nil {
 				logf("❌ %s 失败：%v", tag, e)
 				return e
 			}
+			_ = jr.doneOp(i)
 		}
-		return nil
+		// 2) 提交前校验（.xgit/checks）；失败则整体回滚
+		return RunChecks(repo, checks, logf)
 	})
 
 	if err != nil {
-		return
+		return false
+	}
+
+	_ = jr.setPhase(phaseCommit)
+	committed, err := commitBatch(repo, patch, git, logf)
+	if err != nil || !committed {
+		return err == nil
 	}
+	_ = jr.setPhase(phasePush)
+	if err := pushBatch(repo, git, logf); err != nil {
+		return false
+	}
+	log("✅ 本次补丁完成")
+	return true
+}
 
+// commitBatch 统一暂存并提交；无改动时返回 (false, nil)
+func commitBatch(repo string, patch *Patch, git gitops.GitBackend, log func(string, ...any)) (bool, error) {
 	commit := strings.TrimSpace(patch.CommitMsg)
 	if commit == "" {
 		commit = "chore: apply file ops patch"
@@ -102,40 +198,36 @@ func ApplyOnce(logger *DualLogger, repo string, patch *Patch, patchFile string)
 	log("ℹ️ 提交说明：%s", commit)
 	log("ℹ️ 提交作者：%s", author)
 	// === 统一纳入索引 ===
-	if _, err := runGit(repo, logger, "add", "-A", "--"); err != nil {
+	if err := git.Add(repo); err != nil {
 		log("❌ stage 失败：%v", err)
-		return
+		return false, err
 	}
 
 	// === 只看已暂存改动，决定是否提交 ===
-	out, _ := runGit(repo, logger, "diff", "--cached", "--name-only", "-z")
-	hasStaged := false
-	for _, p := range strings.Split(out, "\x00") {
-		if strings.TrimSpace(p) != "" {
-			hasStaged = true
-			break
-		}
-	}
-	if !hasStaged {
+	staged, _ := git.StagedNames(repo)
+	if len(staged) == 0 {
 		log("ℹ️ 无改动需要提交。")
-		return
+		return false, nil
 	}
 
 	// === 提交 ===
-	if err := runCmd("git", "-C", repo, "commit", "--author", author, "-m", commit); err != nil {
+	if err := git.Commit(repo, author, commit); err != nil {
 		log("❌ 提交失败：%v", err)
-		return
+		return false, err
 	}
 	log("✅ 已提交：%s", commit)
+	return true, nil
+}
 
-	// === 推送 ===
+// pushBatch 推送到 origin HEAD
+func pushBatch(repo string, git gitops.GitBackend, log func(string, ...any)) error {
 	log("🚀 正在推送（origin HEAD）…")
-	if _, err := runGit(repo, logger, "push", "origin", "HEAD"); err != nil {
+	if _, err := git.Push(repo, "origin", "HEAD"); err != nil {
 		log("❌ 推送失败：%v", err)
-		return
+		return err
 	}
 	log("🚀 推送完成")
-	log("✅ 本次补丁完成")
+	return nil
 }
 
 // 统一仓库解析：Patch.Repo > 头部 repo: > .repos default
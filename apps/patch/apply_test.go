@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"xgit/apps/patch/gitops"
+)
+
+const fakeHead = "base000000000000000000000000000000000000"
+
+// newTestRepo 建一个只有工作区的临时“仓库”（git 调用全部交给 FakeBackend）
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for rel, body := range files {
+		abs := filepath.Join(repo, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func newTestFake(repo string, staged ...string) *gitops.FakeBackend {
+	f := gitops.NewFakeBackend()
+	f.Heads[repo] = fakeHead
+	f.On("staged", func(gitops.FakeCall) (string, error) { return strings.Join(staged, "\n"), nil })
+	return f
+}
+
+func testLogger() (*DualLogger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return &DualLogger{Console: &buf, w: &buf}, &buf
+}
+
+func hasOp(ops []string, prefix string) bool {
+	for _, o := range ops {
+		if strings.HasPrefix(o, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunBatchCommitAndPush(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "one\n"})
+	git := newTestFake(repo, "a.txt", "b.txt")
+	logger, buf := testLogger()
+	patch := &Patch{
+		CommitMsg: "feat: test",
+		Ops: []*FileOp{
+			{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}},
+			{Cmd: "file.write", Path: "b.txt", Body: "b\n", Args: map[string]string{}},
+		},
+	}
+	if !runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatalf("runBatch 失败：\n%s", buf)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(b) != "one\ntwo\n" {
+		t.Fatalf("a.txt = %q", b)
+	}
+	ops := git.Ops()
+	for _, want := range []string{"reset hard", "clean", "add", "commit XGit Bot <bot@xgit.local> feat: test", "push origin HEAD"} {
+		if !hasOp(ops, want) {
+			t.Errorf("缺少 git 调用 %q：%v", want, ops)
+		}
+	}
+	if git.Heads[repo] == fakeHead {
+		t.Errorf("提交后 HEAD 未前进")
+	}
+}
+
+func TestRunBatchRollbackOnOpError(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "one\n"})
+	git := newTestFake(repo, "a.txt")
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{
+		{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}},
+		{Cmd: "line.replace", Path: "a.txt", Body: "x\n", Args: map[string]string{"keys": "no-such-line", "match": "exact"}},
+	}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("定位失败的指令应使整批失败")
+	}
+	ops := git.Ops()
+	if !hasOp(ops, "reset hard "+fakeHead) {
+		t.Errorf("未回滚到补丁前的 HEAD：%v", ops)
+	}
+	if hasOp(ops, "commit") || hasOp(ops, "push") {
+		t.Errorf("失败后不应提交 / 推送：%v", ops)
+	}
+	if !strings.Contains(buf.String(), "line.replace #2 失败") {
+		t.Errorf("日志缺少失败指令：\n%s", buf)
+	}
+}
+
+func TestRunBatchRollbackOnCheckFailure(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{
+		"a.txt":        "one\n",
+		".xgit/checks": "lint: echo broken; exit 3\n",
+	})
+	git := newTestFake(repo, "a.txt")
+	git.Files[fakeHead+":.xgit/checks"] = "lint: echo broken; exit 3\n"
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}}}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("校验失败应使整批失败")
+	}
+	ops := git.Ops()
+	if !hasOp(ops, "reset hard "+fakeHead) || hasOp(ops, "commit") {
+		t.Errorf("校验失败后应回滚且不提交：%v", ops)
+	}
+	if !strings.Contains(buf.String(), "broken") {
+		t.Errorf("日志缺少校验输出：\n%s", buf)
+	}
+}
+
+func TestRunBatchChecksComeFromHead(t *testing.T) {
+	// 工作区里的 .xgit/checks 已被改成放行，闸门仍按 HEAD 中的定义执行
+	repo := newTestRepo(t, map[string]string{
+		"a.txt":        "one\n",
+		".xgit/checks": "lint: true\n",
+	})
+	git := newTestFake(repo, "a.txt")
+	git.Files[fakeHead+":.xgit/checks"] = "lint: echo from-head; exit 3\n"
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}}}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("HEAD 中的校验失败应使整批失败")
+	}
+	if !strings.Contains(buf.String(), "from-head") {
+		t.Errorf("应执行 HEAD 中的校验：\n%s", buf)
+	}
+}
+
+func TestRunBatchCannotRewriteChecks(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "one\n"})
+	git := newTestFake(repo, "a.txt")
+	git.Files[fakeHead+":.xgit/checks"] = "lint: exit 3\n"
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{
+		{Cmd: "file.write", Path: ".xgit/checks", Body: "lint: true\n", Args: map[string]string{}},
+		{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}},
+	}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("改写 .xgit/checks 的补丁应被拒绝")
+	}
+	if !strings.Contains(buf.String(), "受保护路径：.xgit/") {
+		t.Errorf("日志缺少拒绝原因：\n%s", buf)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".xgit/checks")); !os.IsNotExist(err) {
+		t.Errorf("被拒绝的补丁不应写入 .xgit/checks")
+	}
+}
+
+func TestLoadChecksUncommitted(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{".xgit/checks": "lint: true\n"})
+	git := newTestFake(repo)
+	if _, err := LoadChecks(repo, fakeHead, git); err == nil {
+		t.Error("工作区有、HEAD 中没有的校验配置应报错，而不是跳过闸门")
+	}
+	if checks, err := LoadChecks(repo, "", git); err != nil || checks != nil {
+		t.Errorf("无提交时应无校验：%v %v", checks, err)
+	}
+	bare := newTestRepo(t, nil)
+	if checks, err := LoadChecks(bare, fakeHead, newTestFake(bare)); err != nil || checks != nil {
+		t.Errorf("未配置校验时应无校验：%v %v", checks, err)
+	}
+}
+
+func TestRunBatchNothingStaged(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "one\n"})
+	git := newTestFake(repo)
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{{Cmd: "file.write", Path: "a.txt", Body: "one\n", Args: map[string]string{}}}}
+	if !runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatalf("无改动不应视为失败：\n%s", buf)
+	}
+	if ops := git.Ops(); hasOp(ops, "commit") || hasOp(ops, "push") {
+		t.Errorf("无改动时不应提交 / 推送：%v", ops)
+	}
+}
+
+func TestRunBatchCommitFailure(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "one\n"})
+	git := newTestFake(repo, "a.txt").Fail("commit", errors.New("hook rejected"))
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}}}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("提交失败应返回 false")
+	}
+	if hasOp(git.Ops(), "push") {
+		t.Errorf("提交失败后不应推送")
+	}
+	if !strings.Contains(buf.String(), "hook rejected") {
+		t.Errorf("日志缺少提交错误：\n%s", buf)
+	}
+}
+
+func TestRunBatchPushFailure(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "one\n"})
+	git := newTestFake(repo, "a.txt").Fail("push", errors.New("rejected (non-fast-forward)"))
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{{Cmd: "file.append", Path: "a.txt", Body: "two\n", Args: map[string]string{}}}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("推送失败应返回 false")
+	}
+	if !hasOp(git.Ops(), "commit") {
+		t.Errorf("推送前应已提交：%v", git.Ops())
+	}
+	if !strings.Contains(buf.String(), "推送失败") {
+		t.Errorf("日志缺少推送错误：\n%s", buf)
+	}
+}
+
+func TestRunBatchStageErrorFailsOp(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.json": "{\"a\": 1}\n"})
+	git := newTestFake(repo, "a.json").Fail("add", errors.New("index.lock exists"))
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{{Cmd: "json.set", Path: "a.json", Args: map[string]string{"pointer": "/b", "value": "2"}}}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("暂存失败应使指令失败")
+	}
+	if !strings.Contains(buf.String(), "index.lock exists") {
+		t.Errorf("日志缺少暂存错误：\n%s", buf)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+// XGIT:BEGIN FILE-HEADER
+// checks.go — 提交前校验闸门：执行目标仓库 .xgit/checks 中定义的命令
+// 任一命令失败（退出码非 0 / 超时）→ 返回错误，由事务回滚，补丁不会进入远端
+// XGIT:END FILE-HEADER
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"xgit/apps/patch/gitops"
+)
+
+const (
+	checksFile          = ".xgit/checks"
+	defaultCheckTimeout = 5 * time.Minute
+	checkWaitDelay      = 5 * time.Second // 超时杀进程后等待输出管道关闭的上限（孙进程可能仍持有管道）
+	checkOutputTail     = 4000            // 日志中保留的输出尾部字节数
+)
+
+// Check 一条校验命令
+type Check struct {
+	Name    string
+	Cmd     string        // 交给 sh -c 执行
+	Dir     string        // 相对仓库根的工作目录（默认仓库根）
+	Timeout time.Duration // 超时（默认 5m）
+	Line    int           // 定义所在行（便于报错）
+}
+
+// CheckResult 单条校验结果
+type CheckResult struct {
+	Check    Check
+	Passed   bool
+	TimedOut bool
+	Output   string // stdout+stderr
+	Elapsed  time.Duration
+	Err      error
+}
+
+// LoadChecks 从 ref（批前 HEAD）读取并解析 .xgit/checks，而不是读工作区：
+// 补丁在同一批次里改写校验配置不能绕过闸门。
+//   - ref 为空（仓库尚无提交）→ 无校验
+//   - ref 中没有该文件：工作区也没有 → 无校验；工作区有（尚未提交）→ 报错，不静默跳过闸门
+//
+// 格式（每行一条，# 开头为注释）：
+//
+//	<名称> [timeout=<时长>] [dir=<子目录>]: <命令>
+//
+// 示例：
+//
+//	build timeout=2m dir=apps/patch: go build ./...
+//	test: go test ./pkg/...
+func LoadChecks(repo, ref string, git gitops.GitBackend) ([]Check, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	data, err := git.Show(repo, ref, checksFile)
+	if err != nil {
+		if _, serr := os.Stat(filepath.Join(repo, checksFile)); os.IsNotExist(serr) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s 未能从 %s 读取（校验配置须先提交）：%w", checksFile, shortSHA(ref), err)
+	}
+	return parseChecks(data)
+}
+
+// parseChecks 解析校验配置内容
+func parseChecks(data []byte) ([]Check, error) {
+	var out []Check
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		head, cmd, ok := strings.Cut(line, ":")
+		cmd = strings.TrimSpace(cmd)
+		if !ok || cmd == "" {
+			return nil, fmt.Errorf("%s 第 %d 行格式错误（期望 <名称> [timeout=..] [dir=..]: <命令>）", checksFile, n)
+		}
+		fields := strings.Fields(head)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s 第 %d 行缺少名称", checksFile, n)
+		}
+		c := Check{Name: fields[0], Cmd: cmd, Timeout: defaultCheckTimeout, Line: n}
+		for _, kv := range fields[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			switch strings.ToLower(k) {
+			case "timeout":
+				d, err := time.ParseDuration(v)
+				if err != nil || d <= 0 {
+					return nil, fmt.Errorf("%s 第 %d 行 timeout 非法：%q", checksFile, n, v)
+				}
+				c.Timeout = d
+			case "dir":
+				d := filepath.Clean(filepath.FromSlash(v))
+				if filepath.IsAbs(d) || d == ".." || strings.HasPrefix(d, ".."+string(filepath.Separator)) {
+					return nil, fmt.Errorf("%s 第 %d 行 dir 越出仓库：%q", checksFile, n, v)
+				}
+				c.Dir = d
+			default:
+				return nil, fmt.Errorf("%s 第 %d 行未知参数：%q", checksFile, n, kv)
+			}
+		}
+		out = append(out, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// runCheck 在 repo（或其子目录）下执行一条校验命令，捕获输出
+func runCheck(repo string, c Check) CheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Cmd)
+	cmd.Dir = filepath.Join(repo, c.Dir)
+	killProcessGroup(cmd) // 超时连同 go test 等派生的子进程一起结束
+	cmd.WaitDelay = checkWaitDelay
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err := cmd.Run()
+	res := CheckResult{Check: c, Output: out.String(), Elapsed: time.Since(start), Err: err}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		res.TimedOut = true
+		res.Err = fmt.Errorf("超时（%s）", c.Timeout)
+	}
+	res.Passed = res.Err == nil
+	return res
+}
+
+// RunChecks 依次执行 checks（由 LoadChecks 在批前加载）；遇到首个失败即停止并返回错误（含输出）
+func RunChecks(repo string, checks []Check, logf func(string, ...any)) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	logf("🧪 提交前校验：共 %d 项（%s）", len(checks), checksFile)
+	for i, c := range checks {
+		logf("▶ 校验 #%d %s：%s", i+1, c.Name, c.Cmd)
+		res := runCheck(repo, c)
+		if res.Passed {
+			logf("✔ 校验通过 %s（%s）", c.Name, res.Elapsed.Round(time.Millisecond))
+			continue
+		}
+		tail := tailOutput(res.Output, checkOutputTail)
+		logf("❌ 校验失败 %s（%s）：%v\n%s", c.Name, res.Elapsed.Round(time.Millisecond), res.Err, tail)
+		return fmt.Errorf("提交前校验 %s 失败：%v\n%s", c.Name, res.Err, tail)
+	}
+	logf("✅ 提交前校验全部通过")
+	return nil
+}
+
+// tailOutput 只保留输出末尾 max 字节（从完整行开始）
+func tailOutput(s string, max int) string {
+	s = strings.TrimRight(s, "\n")
+	if len(s) <= max {
+		return s
+	}
+	s = s[len(s)-max:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return "…\n" + s
+}
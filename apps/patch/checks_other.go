@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killProcessGroup 非 Unix 平台没有进程组，沿用 exec 默认的只结束直接子进程
+func killProcessGroup(cmd *exec.Cmd) {}
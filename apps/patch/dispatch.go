@@ -11,18 +11,21 @@ import (
 	"xgit/apps/patch/gitops"
 )
 
-func applyOp(repo string, op *FileOp, logger *DualLogger) error {
+func applyOp(repo string, op *FileOp, git gitops.GitBackend, logger *DualLogger) error {
+	if err := guardOp(repo, op); err != nil {
+		return err
+	}
 
 	switch op.Cmd {
 
 	case "file.write":
-		return fileops.FileWrite(repo, op.Path, []byte(op.Body), logger)
+		return fileops.FileWrite(repo, op.Path, []byte(op.Body), op.Args, logger)
 
 	case "file.append":
-		return fileops.FileAppend(repo, op.Path, []byte(op.Body), logger)
+		return fileops.FileAppend(repo, op.Path, []byte(op.Body), op.Args, logger)
 
 	case "file.prepend":
-		return fileops.FilePrepend(repo, op.Path, []byte(op.Body), logger)
+		return fileops.FilePrepend(repo, op.Path, []byte(op.Body), op.Args, logger)
 
 	case "file.delete":
 		return fileops.FileDelete(repo, op.Path, logger)
@@ -61,6 +64,9 @@ func applyOp(repo string, op *FileOp, logger *DualLogger) error {
 		}
 		return fileops.FileImage(repo, op.Path, string(bin), logger)
 
+	case "file.ensure-line", "file.ensure-block":
+		return fileops.Ensure(op.Cmd, repo, op.Path, op.Body, op.Args, git, logger)
+
 	case "file.binary":
 		raw := strings.TrimSpace(op.Body)
 		if raw == "" {
@@ -74,7 +80,7 @@ func applyOp(repo string, op *FileOp, logger *DualLogger) error {
 
 	// ========== gitops 系列 ==========
 	case "git.diff":
-		return gitops.Diff(repo, op.Body, logger)
+		return gitops.Diff(repo, op.Body, git, logger)
 
 	case "git.reset":
 		ref := strings.TrimSpace(argStr(op.Args, "ref", strings.TrimSpace(op.Body)))
@@ -82,7 +88,7 @@ func applyOp(repo string, op *FileOp, logger *DualLogger) error {
 			return errors.New("git.reset: 缺少目标提交 ref")
 		}
 		mode := strings.TrimSpace(argStr(op.Args, "mode", "hard"))
-		return gitops.Reset(repo, ref, mode, logger)
+		return gitops.Reset(repo, ref, mode, git, logger)
 
 	case "git.revert":
 		// 统一处理 git.revert 的入参：ref/spec/body + no_commit/strategy(兼容)
@@ -106,8 +112,8 @@ func applyOp(repo string, op *FileOp, logger *DualLogger) error {
 			}
 		}
 
-		// 正确签名：Revert(repo, ref, noCommit, logger)
-		return gitops.Revert(repo, ref, noCommit, logger)
+		// 正确签名：Revert(repo, ref, noCommit, git, logger)
+		return gitops.Revert(repo, ref, noCommit, git, logger)
 
 	case "git.tag":
 		name := strings.TrimSpace(argStr(op.Args, "name", ""))
@@ -118,7 +124,7 @@ func applyOp(repo string, op *FileOp, logger *DualLogger) error {
 		message := argStr(op.Args, "message", "")
 		annotate := argBool(op.Args, "annotate", message != "")
 		force := argBool(op.Args, "force", false)
-		return gitops.Tag(repo, name, ref, message, annotate, force, logger)
+		return gitops.Tag(repo, name, ref, message, annotate, force, git, logger)
 
 	case "git.commit":
 		if logger != nil {
@@ -146,6 +152,51 @@ func applyOp(repo string, op *FileOp, logger *DualLogger) error {
 	case "block.replace":
 		return fileops.BlockReplace(repo, op.Path, op.Body, op.Args, logger)
 
+	case "text.replace":
+		return fileops.TextReplace(repo, op.Path, op.Args, git, logger)
+
+	case "repo.replace":
+		return fileops.RepoReplace(repo, op.Path, op.Args, git, logger)
+
+	case "anchor.replace", "anchor.insert-before", "anchor.insert-after", "anchor.delete", "anchor.wrap":
+		name := strings.TrimSpace(argStr(op.Args, "name", ""))
+		begin, end := BeginEndMarkers(op.Path, name)
+		a := fileops.Anchor{Name: name, Begin: begin, End: end}
+		switch op.Cmd {
+		case "anchor.replace":
+			return fileops.AnchorReplace(repo, op.Path, op.Body, a, op.Args, git, logger)
+		case "anchor.insert-before":
+			return fileops.AnchorInsert(repo, op.Path, op.Body, false, a, op.Args, git, logger)
+		case "anchor.insert-after":
+			return fileops.AnchorInsert(repo, op.Path, op.Body, true, a, op.Args, git, logger)
+		case "anchor.delete":
+			return fileops.AnchorDelete(repo, op.Path, a, op.Args, git, logger)
+		default:
+			return fileops.AnchorWrap(repo, op.Path, a, op.Args, git, logger)
+		}
+
+	case "go.import.add":
+		return fileops.GoImportAdd(repo, op.Path, op.Body, op.Args, git, logger)
+
+	case "go.import.remove":
+		return fileops.GoImportRemove(repo, op.Path, op.Body, op.Args, git, logger)
+
+	case "go.rename":
+		return fileops.GoRename(repo, op.Path, op.Args, git, logger)
+
+	case "go.move-package":
+		return fileops.GoMovePackage(repo, op.Path, op.Args, git, logger)
+
+	case "json.set", "json.delete", "json.merge", "json.append":
+		return fileops.JSONEdit(op.Cmd, repo, op.Path, op.Body, op.Args, git, logger)
+	case "yaml.set", "yaml.delete", "toml.set", "toml.delete", "ini.set", "ini.delete":
+		return fileops.ConfigEdit(op.Cmd, repo, op.Path, op.Body, op.Args, git, logger)
+	case "html.replace", "html.insert", "html.remove", "html.set-attr":
+		return fileops.HTMLEdit(op.Cmd, repo, op.Path, op.Body, op.Args, git, logger)
+	case "md.section.replace", "md.section.append", "md.section.delete", "md.section.insert",
+		"md.table.set-row", "md.table.delete-row":
+		return fileops.MarkdownEdit(op.Cmd, repo, op.Path, op.Body, op.Args, git, logger)
+
 	default:
 		return errors.New("未知指令: " + op.Cmd)
 	}
@@ -0,0 +1,219 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+)
+
+//
+// 锚点区域：由 XGIT:BEGIN <名称> / XGIT:END <名称> 标记包围的一段内容。
+// 新标记的注释样式由上层按扩展名给出（main.BeginEndMarkers）；识别已有标记时兼容常见注释写法
+// （// # /* */ <!-- --> -- ;），名称按整段比较（允许空格，如 "GITOPS BACKEND"）。
+// 同名标记重复、BEGIN/END 不成对或顺序颠倒时一律报错。
+//
+
+// Anchor 一个命名锚点及其新建时使用的标记行
+type Anchor struct {
+	Name  string
+	Begin string
+	End   string
+}
+
+type anchorSpan struct{ begin, end int } // BEGIN/END 标记所在行（1-based）
+
+func markerRe(kind, name string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*(?://|#|/\*|<!--|--|;)?\s*XGIT:` + kind + `\s+` + regexp.QuoteMeta(name) + `\s*(?:\*/|-->)?\s*$`)
+}
+
+// findAnchor 定位锚点；不存在返回 (anchorSpan{}, false, nil)
+func findAnchor(lines []string, name string) (anchorSpan, bool, error) {
+	reB, reE := markerRe("BEGIN", name), markerRe("END", name)
+	var bs, es []int
+	for i, l := range lines {
+		t := strings.TrimRight(l, "\r\n")
+		switch {
+		case reB.MatchString(t):
+			bs = append(bs, i+1)
+		case reE.MatchString(t):
+			es = append(es, i+1)
+		}
+	}
+	switch {
+	case len(bs) == 0 && len(es) == 0:
+		return anchorSpan{}, false, nil
+	case len(bs) > 1 || len(es) > 1:
+		return anchorSpan{}, false, fmt.Errorf("锚点 %q 重复（BEGIN %v / END %v）", name, bs, es)
+	case len(bs) == 0 || len(es) == 0:
+		return anchorSpan{}, false, fmt.Errorf("锚点 %q 不成对（BEGIN %v / END %v）", name, bs, es)
+	case es[0] < bs[0]:
+		return anchorSpan{}, false, fmt.Errorf("锚点 %q 顺序颠倒（END L%d 在 BEGIN L%d 之前）", name, es[0], bs[0])
+	}
+	return anchorSpan{begin: bs[0], end: es[0]}, true, nil
+}
+
+func mustAnchor(op string, lines []string, a Anchor) (anchorSpan, error) {
+	if strings.TrimSpace(a.Name) == "" {
+		return anchorSpan{}, fmt.Errorf("%s: 缺少锚点名称 name=", op)
+	}
+	sp, ok, err := findAnchor(lines, a.Name)
+	if err != nil {
+		return anchorSpan{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return anchorSpan{}, fmt.Errorf("%s: 未找到锚点 %q", op, a.Name)
+	}
+	return sp, nil
+}
+
+// anchor.replace —— 替换锚点内部内容（保留标记行）
+func AnchorReplace(repo, rel, body string, a Anchor, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	lines, ff, err := readLines(abs)
+	if err != nil {
+		return err
+	}
+	sp, err := mustAnchor("anchor.replace", lines, a)
+	if err != nil {
+		return err
+	}
+	newLines := splitPayload(body)
+	inner := sp.end - sp.begin - 1
+	if inner == len(newLines) && matchExact(lines, sp.begin, newLines) {
+		if skip, err := idemSkip("anchor.replace", rel, stateApplied, args, logger); err != nil || skip {
+			return err
+		}
+	}
+	lines = splice(lines, sp.begin, inner, newLines)
+	return writeAnchor(repo, rel, lines, ff, fmt.Sprintf("✏️ anchor.replace %s:%s [%d..%d] (%d→%d)", rel, a.Name, sp.begin, sp.end, inner, len(newLines)), git, logger)
+}
+
+// anchor.insert-before / anchor.insert-after —— 在锚点区域之前 / 之后插入；inside=true 时插入到区域内部开头 / 末尾
+func AnchorInsert(repo, rel, body string, after bool, a Anchor, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	op := "anchor.insert-before"
+	if after {
+		op = "anchor.insert-after"
+	}
+	abs := filepath.Join(repo, rel)
+	lines, ff, err := readLines(abs)
+	if err != nil {
+		return err
+	}
+	sp, err := mustAnchor(op, lines, a)
+	if err != nil {
+		return err
+	}
+	insert := splitPayload(body)
+	if len(insert) == 0 {
+		return fmt.Errorf("%s: 正文为空", op)
+	}
+	inside := argOn(args, "inside")
+	// at 为 0-based 插入位置；已应用 = 正文已紧邻位于插入点之前（BEGIN 前 / END 前）或之后（BEGIN 后 / END 后）
+	var at int
+	var st applyState
+	switch {
+	case !after && !inside:
+		at = sp.begin - 1
+		st = stateBefore(lines, at, insert)
+	case !after && inside:
+		at = sp.begin
+		st = stateAfter(lines, at, insert)
+	case after && inside:
+		at = sp.end - 1
+		st = stateBefore(lines, at, insert)
+	default:
+		at = sp.end
+		st = stateAfter(lines, at, insert)
+	}
+	if skip, err := idemSkip(op, rel, st, args, logger); err != nil || skip {
+		return err
+	}
+	lines = insertAt(lines, at, insert)
+	return writeAnchor(repo, rel, lines, ff, fmt.Sprintf("➕ %s %s:%s L%d (+%d)", op, rel, a.Name, at+1, len(insert)), git, logger)
+}
+
+// anchor.delete —— 删除整个锚点区域（含标记行）；keep-markers=true 时只清空内部
+func AnchorDelete(repo, rel string, a Anchor, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	lines, ff, err := readLines(abs)
+	if err != nil {
+		return err
+	}
+	sp, err := mustAnchor("anchor.delete", lines, a)
+	if err != nil {
+		return err
+	}
+	from, n := sp.begin-1, sp.end-sp.begin+1
+	if argOn(args, "keep-markers") {
+		from, n = sp.begin, sp.end-sp.begin-1
+	}
+	lines = splice(lines, from, n, nil)
+	return writeAnchor(repo, rel, lines, ff, fmt.Sprintf("🗑️ anchor.delete %s:%s (-%d)", rel, a.Name, n), git, logger)
+}
+
+// anchor.wrap —— 用 BEGIN/END 标记包围一段已有内容（start-keys/end-keys/end=auto/scope= 或单行 keys/lineno 定位）
+func AnchorWrap(repo, rel string, a Anchor, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	lines, ff, err := readLines(abs)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(a.Name) == "" {
+		return errors.New("anchor.wrap: 缺少锚点名称 name=")
+	}
+	existing, exists, err := findAnchor(lines, a.Name)
+	if err != nil {
+		return fmt.Errorf("anchor.wrap: %w", err)
+	}
+	sc, err := resolveScope(rel, lines, args, logger)
+	if err != nil {
+		return fmt.Errorf("anchor.wrap: %w", err)
+	}
+	hasScope := false
+	for _, k := range []string{"start-keys", "start-context", "scope"} {
+		if strings.TrimSpace(args[k]) != "" {
+			hasScope = true
+		}
+	}
+	if strings.TrimSpace(args["keys"]) != "" || strings.TrimSpace(args["lineno"]) != "" || strings.TrimSpace(args["context"]) != "" {
+		ln, err := resolveLineInScope(lines, sc, args, logger)
+		if err != nil {
+			return fmt.Errorf("anchor.wrap: %w", err)
+		}
+		sc, hasScope = scope{start: ln, end: ln}, true
+	}
+	if !hasScope {
+		return errors.New("anchor.wrap: 需要 start-keys/end-keys、scope= 或 keys 指定包围范围")
+	}
+	if exists {
+		if existing.begin == sc.start-1 && existing.end == sc.end+1 {
+			if skip, err := idemSkip("anchor.wrap", rel, stateApplied, args, logger); err != nil || skip {
+				return err
+			}
+		}
+		return fmt.Errorf("anchor.wrap: 锚点 %q 已存在（L%d..L%d）", a.Name, existing.begin, existing.end)
+	}
+	indent := ""
+	if sc.start <= len(lines) {
+		l := lines[sc.start-1]
+		indent = l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+	}
+	lines = insertAt(lines, sc.end, []string{indent + a.End + "\n"})
+	lines = insertAt(lines, sc.start-1, []string{indent + a.Begin + "\n"})
+	return writeAnchor(repo, rel, lines, ff, fmt.Sprintf("🏷️ anchor.wrap %s:%s [%d..%d]", rel, a.Name, sc.start, sc.end), git, logger)
+}
+
+func writeAnchor(repo, rel string, lines []string, ff fileFormat, msg string, git gitops.GitBackend, logger DualLogger) error {
+	lines = ensureTrailingNL(lines)
+	if err := writeLines(filepath.Join(repo, rel), lines, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("%s", msg)
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
@@ -0,0 +1,83 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnchorOps(t *testing.T) {
+	a := Anchor{Name: "CFG BLOCK", Begin: "# XGIT:BEGIN CFG BLOCK", End: "# XGIT:END CFG BLOCK"}
+	const src = "top\n# XGIT:BEGIN CFG BLOCK\nold\n# XGIT:END CFG BLOCK\nbottom\n"
+	run := func(op, repo, body string, args map[string]string) error {
+		switch op {
+		case "replace":
+			return AnchorReplace(repo, "a.txt", body, a, args, nil, nil)
+		case "insert-before":
+			return AnchorInsert(repo, "a.txt", body, false, a, args, nil, nil)
+		case "insert-after":
+			return AnchorInsert(repo, "a.txt", body, true, a, args, nil, nil)
+		case "delete":
+			return AnchorDelete(repo, "a.txt", a, args, nil, nil)
+		}
+		return AnchorWrap(repo, "a.txt", a, args, nil, nil)
+	}
+	cases := []struct {
+		name, op, src, body string
+		args                map[string]string
+		want, err           string
+	}{
+		{"replace", "replace", src, "new1\nnew2\n", nil,
+			"top\n# XGIT:BEGIN CFG BLOCK\nnew1\nnew2\n# XGIT:END CFG BLOCK\nbottom\n", ""},
+		{"replace 兼容 // 与 <!-- --> 标记", "replace", "// XGIT:BEGIN CFG BLOCK\nold\n<!-- XGIT:END CFG BLOCK -->\n", "new\n", nil,
+			"// XGIT:BEGIN CFG BLOCK\nnew\n<!-- XGIT:END CFG BLOCK -->\n", ""},
+		{"insert-before", "insert-before", src, "x\n", nil,
+			"top\nx\n# XGIT:BEGIN CFG BLOCK\nold\n# XGIT:END CFG BLOCK\nbottom\n", ""},
+		{"insert-before inside", "insert-before", src, "x\n", map[string]string{"inside": "true"},
+			"top\n# XGIT:BEGIN CFG BLOCK\nx\nold\n# XGIT:END CFG BLOCK\nbottom\n", ""},
+		{"insert-after", "insert-after", src, "x\n", nil,
+			"top\n# XGIT:BEGIN CFG BLOCK\nold\n# XGIT:END CFG BLOCK\nx\nbottom\n", ""},
+		{"insert-after inside", "insert-after", src, "x\n", map[string]string{"inside": "true"},
+			"top\n# XGIT:BEGIN CFG BLOCK\nold\nx\n# XGIT:END CFG BLOCK\nbottom\n", ""},
+		{"insert 已应用", "insert-after", "top\n# XGIT:BEGIN CFG BLOCK\nold\n# XGIT:END CFG BLOCK\nx\nbottom\n", "x\n", nil,
+			"top\n# XGIT:BEGIN CFG BLOCK\nold\n# XGIT:END CFG BLOCK\nx\nbottom\n", ""},
+		{"delete", "delete", src, "", nil, "top\nbottom\n", ""},
+		{"delete keep-markers", "delete", src, "", map[string]string{"keep-markers": "true"},
+			"top\n# XGIT:BEGIN CFG BLOCK\n# XGIT:END CFG BLOCK\nbottom\n", ""},
+		{"wrap keys 单行", "wrap", "a\n  b\nc\n", "", map[string]string{"keys": "b"},
+			"a\n  # XGIT:BEGIN CFG BLOCK\n  b\n  # XGIT:END CFG BLOCK\nc\n", ""},
+		{"wrap 已包围同一范围", "wrap", "a\n# XGIT:BEGIN CFG BLOCK\nb\n# XGIT:END CFG BLOCK\n", "", map[string]string{"keys": "b", "match": "exact"},
+			"a\n# XGIT:BEGIN CFG BLOCK\nb\n# XGIT:END CFG BLOCK\n", ""},
+		{"wrap 已存在于别处", "wrap", src, "", map[string]string{"keys": "top"}, "", "已存在"},
+		{"wrap 缺少范围", "wrap", "a\n", "", nil, "", "需要 start-keys"},
+		{"未找到", "replace", "a\n", "x\n", nil, "", "未找到锚点"},
+		{"重复", "replace", src + src, "x\n", nil, "", "重复"},
+		{"不成对", "delete", "# XGIT:BEGIN CFG BLOCK\nx\n", "", nil, "", "不成对"},
+		{"顺序颠倒", "delete", "# XGIT:END CFG BLOCK\nx\n# XGIT:BEGIN CFG BLOCK\n", "", nil, "", "顺序颠倒"},
+		{"名称按整段比较", "delete", "# XGIT:BEGIN CFG\nx\n# XGIT:END CFG\n", "", nil, "", "未找到锚点"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.txt": c.src})
+			args := c.args
+			if args == nil {
+				args = map[string]string{}
+			}
+			err := run(c.op, repo, c.body, args)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v，期望包含 %q", err, c.err)
+				}
+				if got := readTestFile(t, repo, "a.txt"); got != c.src {
+					t.Fatalf("报错时不应修改文件：%q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, repo, "a.txt"); got != c.want {
+				t.Fatalf("内容 = %q\n期望 = %q", got, c.want)
+			}
+		})
+	}
+}
@@ -11,13 +11,14 @@ package fileops
 import (
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // XGIT:END GO:IMPORTS
 
 // XGIT:BEGIN GO:FUNC_FILE_APPEND
 // FileAppend 末尾追加 —— 协议: file.append
-func FileAppend(repo, rel string, data []byte, logger DualLogger) error {
+func FileAppend(repo, rel string, data []byte, args map[string]string, logger DualLogger) error {
 	abs := filepath.Join(repo, rel)
 	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
 		if logger != nil {
@@ -25,19 +26,27 @@ func FileAppend(repo, rel string, data []byte, logger DualLogger) error {
 		}
 		return err
 	}
-	b := []byte(normalizeLF(string(data)))
-	f, err := os.OpenFile(abs, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-	if err != nil {
-		if logger != nil {
-			logger.Log("❌ file.append 打开失败：%s (%v)", rel, err)
-		}
+	b := normalizeLF(string(data))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b += "\n"
+	}
+	// 已有文件：沿用权限、换行风格与 BOM；原末行无换行时先补上，避免与追加内容粘连
+	old, ff, err := readText(abs)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	defer f.Close()
-	if len(b) > 0 && b[len(b)-1] != '\n' {
-		b = append(b, '\n')
+	// 幂等：文件末尾已是这段内容 → 跳过；仅末尾存在其前几行（上次中断）→ 部分存在
+	if ff.exists {
+		oldLines := textLines(old)
+		st := stateBefore(oldLines, len(oldLines), textLines(b))
+		if skip, err := idemSkip("file.append", rel, st, args, logger); err != nil || skip {
+			return err
+		}
+	}
+	if old != "" && !strings.HasSuffix(old, "\n") {
+		old += "\n"
 	}
-	if _, err := f.Write(b); err != nil {
+	if err := writeText(abs, old+b, ff); err != nil {
 		if logger != nil {
 			logger.Log("❌ file.append 写入失败：%s (%v)", rel, err)
 		}
@@ -0,0 +1,101 @@
+package fileops
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"xgit/apps/patch/preflight"
+)
+
+//
+// 统一写入层：所有 fileops 都经由这里落盘
+//   - 原子写入：tmp → fsync → rename（preflight.AtomicWrite）
+//   - 保留原文件的权限、换行风格（LF/CRLF，以多数为准）、UTF-8 BOM 与“末尾是否有换行”
+//   - 指令在内存中一律按“LF、无 BOM”处理；只有明确要求改变这些属性的指令（如 file.eol）才会改变它们
+//
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// fileFormat 文件的外观属性
+type fileFormat struct {
+	exists  bool
+	mode    os.FileMode
+	crlf    bool
+	bom     bool
+	finalNL bool // 末尾有换行（空文件 / 新文件视为 true）
+}
+
+// sniffFormat 读取 abs 的外观属性与原始内容；文件不存在时返回默认值（0644、LF、无 BOM、末尾换行）
+func sniffFormat(abs string) (fileFormat, []byte, error) {
+	ff := fileFormat{mode: 0o644, finalNL: true}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ff, nil, nil
+		}
+		return ff, nil, err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return ff, nil, err
+	}
+	ff.exists = true
+	ff.mode = fi.Mode().Perm()
+	ff.bom = bytes.HasPrefix(data, utf8BOM)
+	crlf := bytes.Count(data, []byte("\r\n"))
+	ff.crlf = crlf > 0 && crlf*2 >= bytes.Count(data, []byte("\n"))
+	ff.finalNL = len(data) == 0 || data[len(data)-1] == '\n'
+	return ff, data, nil
+}
+
+// readText 读取文本：去掉 BOM、统一为 LF；文件不存在返回错误
+func readText(abs string) (string, fileFormat, error) {
+	ff, data, err := sniffFormat(abs)
+	if err != nil {
+		return "", ff, err
+	}
+	if !ff.exists {
+		return "", ff, &os.PathError{Op: "open", Path: abs, Err: os.ErrNotExist}
+	}
+	return normalizeLF(string(bytes.TrimPrefix(data, utf8BOM))), ff, nil
+}
+
+// writeText 按 ff 记录的外观写回 LF 文本（恢复 CRLF 与 BOM；末尾换行保持 s 本身的状态）
+func writeText(abs, s string, ff fileFormat) error {
+	s = normalizeLF(s)
+	if ff.crlf {
+		s = toCRLF(s)
+	}
+	if ff.bom {
+		s = string(utf8BOM) + s
+	}
+	return WriteFileAtomic(abs, []byte(s), ff.mode)
+}
+
+// applyFinalNL 按原文件“末尾是否有换行”调整 s（行模型编辑后使用）
+func applyFinalNL(s string, ff fileFormat) string {
+	if !ff.finalNL {
+		return strings.TrimSuffix(s, "\n")
+	}
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return s
+}
+
+// WriteFileAtomic 自动建父目录后原子写入（fileops 与上层的三方合并、任务恢复共用）
+// abs 为符号链接时写入其指向的文件（rename 会把链接本身替换掉）；链接是否越出仓库由 PathGuard 把关
+func WriteFileAtomic(abs string, data []byte, mode os.FileMode) error {
+	if fi, err := os.Lstat(abs); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if target, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = target
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return err
+	}
+	return preflight.AtomicWrite(abs, data, mode, time.Time{})
+}
@@ -0,0 +1,80 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 编辑后保留原文件的换行风格、BOM、末尾换行与权限
+func TestWritePreservesFormat(t *testing.T) {
+	bom := string(utf8BOM)
+	appendX := func(repo string) error { return FileAppend(repo, "a.txt", []byte("x\n"), map[string]string{}, nil) }
+	prependX := func(repo string) error { return FilePrepend(repo, "a.txt", []byte("x\n"), map[string]string{}, nil) }
+	replaceB := func(repo string) error {
+		return LineReplace(repo, "a.txt", "B\n", map[string]string{"keys": "b", "match": "exact"}, nil)
+	}
+	writeAll := func(repo string) error { return FileWrite(repo, "a.txt", []byte("p\nq\n"), map[string]string{}, nil) }
+	cases := []struct {
+		name string
+		src  string
+		mode os.FileMode
+		edit func(string) error
+		want string
+	}{
+		{"CRLF 追加", "a\r\nb\r\n", 0o644, appendX, "a\r\nb\r\nx\r\n"},
+		{"CRLF 改行", "a\r\nb\r\nc\r\n", 0o644, replaceB, "a\r\nB\r\nc\r\n"},
+		{"CRLF 覆盖写", "a\r\n", 0o644, writeAll, "p\r\nq\r\n"},
+		{"BOM 仍在最前", bom + "a\nb\n", 0o644, prependX, bom + "x\na\nb\n"},
+		{"BOM + CRLF 改行", bom + "a\r\nb\r\n", 0o644, replaceB, bom + "a\r\nB\r\n"},
+		{"末尾无换行保持", "a\nb", 0o644, replaceB, "a\nB"},
+		{"可执行权限保留", "#!/bin/sh\nb\n", 0o755, replaceB, "#!/bin/sh\nB\n"},
+		{"多数为 LF 时按 LF", "a\r\nb\nc\nd\n", 0o644, appendX, "a\nb\nc\nd\nx\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := t.TempDir()
+			abs := filepath.Join(repo, "a.txt")
+			if err := os.WriteFile(abs, []byte(c.src), c.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(abs, c.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.edit(repo); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, repo, "a.txt"); got != c.want {
+				t.Fatalf("内容 = %q\n期望 = %q", got, c.want)
+			}
+			if fi, err := os.Stat(abs); err != nil || fi.Mode().Perm() != c.mode {
+				t.Fatalf("权限 = %v，期望 %v", fi.Mode().Perm(), c.mode)
+			}
+		})
+	}
+}
+
+// 经符号链接写入：改动落到链接目标，链接本身保留
+func TestWriteFileAtomicFollowsSymlink(t *testing.T) {
+	repo := t.TempDir()
+	target := filepath.Join(repo, "real.txt")
+	link := filepath.Join(repo, "link.txt")
+	if err := os.WriteFile(target, []byte("a\r\nb\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real.txt", link); err != nil {
+		t.Skip("不支持符号链接：", err)
+	}
+	if err := FileAppend(repo, "link.txt", []byte("c\n"), map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("符号链接被替换成了普通文件")
+	}
+	if got := readTestFile(t, repo, "real.txt"); got != "a\r\nb\r\nc\r\n" {
+		t.Fatalf("链接目标内容 = %q", got)
+	}
+	if fi, _ := os.Stat(target); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("链接目标权限 = %v", fi.Mode().Perm())
+	}
+}
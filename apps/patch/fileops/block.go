@@ -11,11 +11,11 @@ import (
 //   - start 多处 → 用 nthb 选择；end 多处 → 取第一处
 func BlockDelete(repo, rel string, args map[string]string, logger DualLogger) error {
 	abs := filepath.Join(repo, rel)
-	lines, err := readLines(abs)
+	lines, ff, err := readLines(abs)
 	if err != nil {
 		return err
 	}
-	sc, err := resolveScope(lines, args)
+	sc, err := resolveScope(rel, lines, args, logger)
 	if err != nil {
 		return fmt.Errorf("block.delete: %w", err)
 	}
@@ -25,38 +25,43 @@ func BlockDelete(repo, rel string, args map[string]string, logger DualLogger) er
 	delN := sc.end - sc.start + 1
 	lines = splice(lines, sc.start-1, delN, nil)
 	lines = ensureTrailingNL(lines)
-	if err := writeLines(abs, lines); err != nil {
+	if err := writeLines(abs, lines, ff); err != nil {
 		return err
 	}
 	if logger != nil {
 		logger.Log("🗑️ block.delete  %s:[%d..%d] (-%d)", rel, sc.start, sc.end, delN)
 	}
-	return stageAndPreflight(repo, rel, logger)
+	return nil
 }
 
 // block.replace —— 用正文替换一个作用域内的整段
 func BlockReplace(repo, rel, body string, args map[string]string, logger DualLogger) error {
 	abs := filepath.Join(repo, rel)
-	lines, err := readLines(abs)
+	lines, ff, err := readLines(abs)
 	if err != nil {
 		return err
 	}
-	sc, err := resolveScope(lines, args)
+	newLines := splitPayload(body)
+	sc, err := resolveScope(rel, lines, args, logger)
 	if err != nil {
 		return fmt.Errorf("block.replace: %w", err)
 	}
 	if sc.start < 1 || sc.end < sc.start || sc.end > len(lines) {
 		return fmt.Errorf("block.replace: 非法范围 [%d..%d]", sc.start, sc.end)
 	}
-	newLines := splitPayload(body)
 	delN := sc.end - sc.start + 1
+	if delN == len(newLines) && matchExact(lines, sc.start-1, newLines) {
+		if skip, err := idemSkip("block.replace", rel, stateApplied, args, logger); err != nil || skip {
+			return err
+		}
+	}
 	lines = splice(lines, sc.start-1, delN, newLines)
 	lines = ensureTrailingNL(lines)
-	if err := writeLines(abs, lines); err != nil {
+	if err := writeLines(abs, lines, ff); err != nil {
 		return err
 	}
 	if logger != nil {
 		logger.Log("✏️ block.replace %s:[%d..%d] (%d→%d)", rel, sc.start, sc.end, delN, len(newLines))
 	}
-	return stageAndPreflight(repo, rel, logger)
+	return nil
 }
@@ -0,0 +1,194 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"xgit/apps/patch/preflight"
+)
+
+//
+// end=auto：从起始行开始识别“构造”的结束行，替代容易选错的 end-keys=}
+//   - 花括号语言（Go / C / Java / JS / Rust / CSS ...）：括号配平，跳过字符串与注释
+//   - 缩进语言（Python / YAML）：以起始行缩进为基准，取其后缩进更深的连续内容
+// 语言按扩展名识别（preflight.DetectLangByExt）。
+//
+
+// braceSyntax 花括号语言的字符串/注释规则
+type braceSyntax struct {
+	lineComment bool // 支持 // 注释
+	singleStr   bool // '...' 为普通字符串（否则按字符字面量处理，如 Go/C/Rust）
+	backtick    bool // `...` 为（可跨行）字符串
+}
+
+func blockSyntax(rel string) (string, braceSyntax) {
+	switch lang := preflight.DetectLangByExt(rel); lang {
+	case "go":
+		return "brace", braceSyntax{lineComment: true, backtick: true}
+	case "js", "ts":
+		return "brace", braceSyntax{lineComment: true, singleStr: true, backtick: true}
+	case "css":
+		return "brace", braceSyntax{singleStr: true}
+	case "c", "cpp", "java", "kotlin", "csharp", "swift", "rust", "json":
+		return "brace", braceSyntax{lineComment: true}
+	case "python", "yaml":
+		return lang, braceSyntax{}
+	}
+	return "", braceSyntax{}
+}
+
+// autoEnd 返回从 start（1-based）开始的构造的结束行号
+func autoEnd(rel string, lines []string, start int) (int, error) {
+	style, syn := blockSyntax(rel)
+	switch style {
+	case "brace":
+		return braceEnd(lines, start, syn)
+	case "python", "yaml":
+		return indentEnd(lines, start, style)
+	}
+	return 0, fmt.Errorf("end=auto 不支持该文件类型：%s", rel)
+}
+
+// braceEnd 括号配平：从起始行开始扫描，行末深度回到 0 且已进入过 { 时结束；
+// 行末深度为 0 但尚未遇到 {（多行签名 / Allman 风格）时，下一有效行以 { 开头则继续，否则在本行结束。
+func braceEnd(lines []string, start int, syn braceSyntax) (int, error) {
+	depth := 0
+	seenBrace := false
+	inBlock := false // /* ... */
+	inRaw := false   // `...`
+	for i := start - 1; i < len(lines); i++ {
+		s := strings.TrimRight(lines[i], "\r\n")
+		for j := 0; j < len(s); j++ {
+			c := s[j]
+			switch {
+			case inBlock:
+				if c == '*' && j+1 < len(s) && s[j+1] == '/' {
+					inBlock = false
+					j++
+				}
+			case inRaw:
+				if c == '`' {
+					inRaw = false
+				}
+			case c == '/' && j+1 < len(s) && s[j+1] == '*':
+				inBlock = true
+				j++
+			case c == '/' && j+1 < len(s) && s[j+1] == '/' && syn.lineComment:
+				j = len(s)
+			case c == '`' && syn.backtick:
+				inRaw = true
+			case c == '"':
+				j = skipQuoted(s, j, '"')
+			case c == '\'':
+				if syn.singleStr {
+					j = skipQuoted(s, j, '\'')
+				} else {
+					j = skipCharLit(s, j)
+				}
+			case c == '{' || c == '(' || c == '[':
+				depth++
+				if c == '{' {
+					seenBrace = true
+				}
+			case c == '}' || c == ')' || c == ']':
+				depth--
+				if depth < 0 {
+					return 0, fmt.Errorf("end=auto: L%d 括号不平衡（起始行可能不在构造开头）", i+1)
+				}
+			}
+		}
+		if depth != 0 || inBlock || inRaw {
+			continue
+		}
+		if seenBrace {
+			return i + 1, nil
+		}
+		if next := nextContent(lines, i+1); next < 0 || !strings.HasPrefix(strings.TrimSpace(lines[next]), "{") {
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("end=auto: 到文件末尾仍未找到匹配的闭合括号")
+}
+
+// skipQuoted 跳过以 q 包围的字符串（支持反斜杠转义），返回结束引号下标；未闭合则到行尾
+func skipQuoted(s string, j int, q byte) int {
+	for k := j + 1; k < len(s); k++ {
+		switch s[k] {
+		case '\\':
+			k++
+		case q:
+			return k
+		}
+	}
+	return len(s)
+}
+
+// skipCharLit 字符字面量 'x' / '\n' / '\u{1F600}'；不像字面量（如 Rust 生命周期 'a）时原样跳过引号
+func skipCharLit(s string, j int) int {
+	if j+2 < len(s) && s[j+1] != '\\' && s[j+2] == '\'' {
+		return j + 2
+	}
+	if j+2 < len(s) && s[j+1] == '\\' { // 转义：先跳过被转义的字符（'\'' 中的 '），再找结束引号
+		if k := strings.IndexByte(s[j+3:], '\''); k >= 0 && k < 11 {
+			return j + 3 + k
+		}
+	}
+	// 多字节 UTF-8 字符
+	if k := strings.IndexByte(s[j+1:], '\''); k > 1 && k <= 4 && s[j+1] >= 0x80 {
+		return j + 1 + k
+	}
+	return j
+}
+
+func nextContent(lines []string, from int) int {
+	for i := from; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// indentEnd 缩进语言：起始行之后缩进更深的连续内容属于该构造；空行与注释行不决定边界，也不计入结尾
+func indentEnd(lines []string, start int, style string) (int, error) {
+	if start < 1 || start > len(lines) {
+		return 0, fmt.Errorf("end=auto: 起始行 %d 超界", start)
+	}
+	base := indentOf(lines[start-1])
+	startItem := strings.HasPrefix(strings.TrimSpace(lines[start-1]), "- ")
+	end := start
+	inTriple := ""
+	for i := start; i < len(lines); i++ {
+		t := strings.TrimSpace(lines[i])
+		if inTriple != "" { // Python 三引号字符串内部：一律算作内容
+			if strings.Count(t, inTriple)%2 == 1 {
+				inTriple = ""
+			}
+			end = i + 1
+			continue
+		}
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		ind := indentOf(lines[i])
+		// YAML：与键同级的 "- " 列表项属于该键
+		yamlItem := style == "yaml" && ind == base && !startItem && strings.HasPrefix(t, "- ")
+		if ind <= base && !yamlItem {
+			break
+		}
+		end = i + 1
+		if style == "python" {
+			for _, q := range []string{`"""`, `'''`} {
+				if strings.Count(t, q)%2 == 1 {
+					inTriple = q
+				}
+			}
+		}
+	}
+	return end, nil
+}
+
+func indentOf(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
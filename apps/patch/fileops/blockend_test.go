@@ -0,0 +1,44 @@
+package fileops
+
+import "testing"
+
+func TestAutoEndBraces(t *testing.T) {
+	cases := []struct {
+		name, rel, src string
+		start, want    int
+	}{
+		{"escaped quote rune", "a.go", "func f() {\n\tc := '\\''\n\tif c == '{' {\n\t\treturn\n\t}\n}\nfunc g() {}\n", 1, 6},
+		{"escaped backslash rune", "a.go", "func f() {\n\tc := '\\\\'\n\t_ = c\n}\n", 1, 4},
+		{"braces in strings and comments", "a.go", "func f() {\n\ts := \"}\" // }\n\t/* } */\n\t_ = `}`\n}\n", 1, 5},
+		{"multi-line signature", "a.go", "func f(\n\ta int,\n) {\n\t_ = a\n}\n", 1, 5},
+		{"js single-quoted string", "a.js", "function f() {\n  const s = '}';\n}\n", 1, 3},
+		{"python indent", "a.py", "def f():\n    x = 1\n\n    return x\n\ny = 2\n", 1, 4},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := autoEnd(c.rel, splitKeepNL(c.src), c.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Fatalf("autoEnd = L%d，期望 L%d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSkipCharLit(t *testing.T) {
+	for lit, want := range map[string]string{
+		`'x'`:       `'x'`,
+		`'\''`:      `'\''`,
+		`'\\'`:      `'\\'`,
+		`'\n'`:      `'\n'`,
+		`'\u00e9'`:  `'\u00e9'`,
+		`'é'`:       `'é'`,
+		`'a: &'a T`: `'`, // Rust 生命周期：不是字面量
+	} {
+		if got := lit[:skipCharLit(lit, 0)+1]; got != want {
+			t.Errorf("skipCharLit(%s) 截到 %s，期望 %s", lit, got, want)
+		}
+	}
+}
@@ -1,9 +1,6 @@
 package fileops
 
 import (
-	"bytes"
-	"fmt"
-	"os/exec"
 	"strings"
 )
 
@@ -23,19 +20,6 @@ func toCRLF(s string) string {
 	return strings.ReplaceAll(s, "\n", "\r\n")
 }
 
-// runGit 执行 git 命令（自动 -C repo），返回合并输出（stdout+stderr）
-func runGit(repo string, logger DualLogger, args ...string) (string, error) {
-	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err := cmd.Run()
-	if err != nil {
-		return out.String(), fmt.Errorf("git %s 失败：%v\n%s", strings.Join(args, " "), err, out.String())
-	}
-	return out.String(), nil
-}
-
 // ensureNL: 判断参数或默认值
 func ensureNL(args map[string]string, def bool) bool {
 	v := strings.ToLower(strings.TrimSpace(args["ensure_nl"]))
@@ -0,0 +1,187 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+	"xgit/apps/patch/preflight"
+)
+
+// yaml.set/delete、toml.set/delete、ini.set/delete —— 按键路径修改配置文件
+//   - key=：键路径，点分（jobs.build.runs-on）或方括号（tool["dev-dependencies"]、steps[0].run）
+//   - value= 或正文：写入的值，按该格式的字面量原样写入（字符串是否加引号由作者决定）
+//   - create=（默认 true）：路径不存在时补齐；create=false 时路径不存在即报错
+//   - 只改动目标键所在的行，注释、空行与其余内容原样保留；写入后由对应格式的预检校验语法
+func ConfigEdit(op, repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	family, action, _ := strings.Cut(op, ".")
+	var ed confEditor
+	switch family {
+	case "yaml":
+		ed = yamlEditor{}
+	case "toml":
+		ed = tomlEditor{}
+	case "ini":
+		ed = iniEditor{env: preflight.IsEnvFile(rel)}
+	default:
+		return errors.New("未知指令: " + op)
+	}
+	raw := strings.TrimSpace(args["key"])
+	if raw == "" {
+		return fmt.Errorf("%s: 缺少 key=（键路径，如 a.b[0].c）", op)
+	}
+	path, err := parseKeyPath(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	abs := filepath.Join(repo, rel)
+	text, ff, err := readText(abs)
+	if err != nil {
+		return err
+	}
+	var out string
+	var st applyState
+	switch action {
+	case "set":
+		value, ok := args["value"]
+		if !ok {
+			value = strings.TrimRight(normalizeLF(body), "\n")
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s: 缺少要写入的值（value= 或正文）", op)
+		}
+		out, st, err = ed.set(text, path, value, !argOff(args, "create"))
+	case "delete":
+		out, st, err = ed.delete(text, path)
+	default:
+		return errors.New("未知指令: " + op)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s %s %w", op, rel, raw, err)
+	}
+	if st == stateApplied || out == text {
+		if skip, err := idemSkip(op, rel+" "+raw, stateApplied, args, logger); err != nil || skip {
+			return err
+		}
+		if out == text {
+			return nil
+		}
+	}
+	if err := writeText(abs, out, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ %s: %s %s", op, rel, raw)
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+// confEditor 一种配置格式的编辑实现（输入输出均为 LF 文本）
+type confEditor interface {
+	set(text string, path []keySeg, value string, create bool) (string, applyState, error)
+	delete(text string, path []keySeg) (string, applyState, error)
+}
+
+// keySeg 键路径的一段：键名，或数组 / 序列下标
+type keySeg struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parseKeyPath 解析 a.b["c.d"][0].e；键可加引号（"..." 或 '...'）以包含 . [ ] 等字符
+func parseKeyPath(s string) ([]keySeg, error) {
+	var out []keySeg
+	i := 0
+	quoted := func() (string, error) {
+		q := s[i]
+		end := strings.IndexByte(s[i+1:], q)
+		if end < 0 {
+			return "", fmt.Errorf("键路径中引号未闭合：%q", s)
+		}
+		k := s[i+1 : i+1+end]
+		if q == '"' {
+			if u, err := strconv.Unquote(s[i : i+2+end]); err == nil {
+				k = u
+			}
+		}
+		i += end + 2
+		return k, nil
+	}
+	for i < len(s) {
+		switch {
+		case s[i] == '[':
+			i++
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				k, err := quoted()
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, keySeg{key: k})
+			} else {
+				end := strings.IndexByte(s[i:], ']')
+				if end < 0 {
+					return nil, fmt.Errorf("键路径中 [ 未闭合：%q", s)
+				}
+				n, err := strconv.Atoi(strings.TrimSpace(s[i : i+end]))
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("键路径中下标无效：%q", s[i:i+end])
+				}
+				out = append(out, keySeg{index: n, isIdx: true})
+				i += end
+			}
+			if i >= len(s) || s[i] != ']' {
+				return nil, fmt.Errorf("键路径中 [ 未闭合：%q", s)
+			}
+			i++
+		case s[i] == '.':
+			i++
+			if i >= len(s) || s[i] == '.' || s[i] == '[' {
+				return nil, fmt.Errorf("键路径中有空段：%q", s)
+			}
+		case s[i] == '"' || s[i] == '\'':
+			k, err := quoted()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, keySeg{key: k})
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(".[]\"'", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("键路径无效：%q", s)
+			}
+			out = append(out, keySeg{key: strings.TrimSpace(s[i:j])})
+			i = j
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("键路径为空：%q", s)
+	}
+	return out, nil
+}
+
+// keyPathString 还原键路径（用于提示）
+func keyPathString(path []keySeg) string {
+	var sb strings.Builder
+	for i, s := range path {
+		switch {
+		case s.isIdx:
+			fmt.Fprintf(&sb, "[%d]", s.index)
+		case strings.ContainsAny(s.key, ".[]\"' "):
+			fmt.Fprintf(&sb, "[%q]", s.key)
+		default:
+			if i > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(s.key)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package fileops
+
+import "testing"
+
+const gitConfig = "[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = false\n"
+
+func TestConfigEdit(t *testing.T) {
+	cases := []struct {
+		name, op, rel, src string
+		args               map[string]string
+		want               string
+	}{
+		{"yaml 改值保留注释", "yaml.set", "a.yml", "a:\n  b: 1 # c\n  d: [x]\n",
+			map[string]string{"key": "a.b", "value": "2"}, "a:\n  b: 2 # c\n  d: [x]\n"},
+		{"yaml 补齐路径", "yaml.set", "a.yml", "a:\n  b: 1\n",
+			map[string]string{"key": "a.e.f", "value": "3"}, "a:\n  b: 1\n  e:\n    f: 3\n"},
+		{"yaml 序列下标", "yaml.set", "a.yml", "steps:\n  - run: x\n  - run: y\n",
+			map[string]string{"key": "steps[1].run", "value": "z"}, "steps:\n  - run: x\n  - run: z\n"},
+		{"yaml 删除", "yaml.delete", "a.yml", "a:\n  b: 1 # c\n  d: [x]\n",
+			map[string]string{"key": "a.b"}, "a:\n  d: [x]\n"},
+		{"toml 改值保留注释", "toml.set", "a.toml", "[tool]\nname = \"a\" # c\n",
+			map[string]string{"key": "tool.name", "value": `"b"`}, "[tool]\nname = \"b\" # c\n"},
+		{"toml 数组表下标", "toml.set", "a.toml", "[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"b\"\n",
+			map[string]string{"key": "bin[1].name", "value": `"c"`}, "[[bin]]\nname = \"a\"\n\n[[bin]]\nname = \"c\"\n"},
+		{"toml 新键插在表末尾", "toml.set", "a.toml", "[tool]\nname = \"a\"\n\n[other]\nx = 1\n",
+			map[string]string{"key": "tool.ver", "value": "2"}, "[tool]\nname = \"a\"\nver = 2\n\n[other]\nx = 1\n"},
+		{"toml 删除", "toml.delete", "a.toml", "[tool]\nname = \"a\"\nver = 1\n",
+			map[string]string{"key": "tool.name"}, "[tool]\nver = 1\n"},
+		{"ini 缩进键不是续行", "ini.set", "config", gitConfig,
+			map[string]string{"key": "core.repositoryformatversion", "value": "1"},
+			"[core]\n\trepositoryformatversion = 1\n\tfilemode = true\n\tbare = false\n"},
+		{"ini 找到缩进键", "ini.set", "config", gitConfig,
+			map[string]string{"key": "core.bare", "value": "true"},
+			"[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = true\n"},
+		{"ini 删除缩进键", "ini.delete", "config", gitConfig,
+			map[string]string{"key": "core.repositoryformatversion"}, "[core]\n\tfilemode = true\n\tbare = false\n"},
+		{"ini 保留行尾注释", "ini.set", "a.ini", "[a]\nx = 1 ; old\ny = 2 # keep\n",
+			map[string]string{"key": "a.x", "value": "2"}, "[a]\nx = 2 ; old\ny = 2 # keep\n"},
+		{"ini 引号内的 ; 不是注释", "ini.set", "a.ini", "[a]\nx = \"a ;b\"\n",
+			map[string]string{"key": "a.x", "value": `"c"`}, "[a]\nx = \"c\"\n"},
+		{"ini 续行随值一起替换", "ini.set", "a.ini", "[a]\nx = one\n  two\ny = 3\n",
+			map[string]string{"key": "a.x", "value": "1"}, "[a]\nx = 1\ny = 3\n"},
+		{"ini 新建节", "ini.set", "a.ini", "[core]\n\tbare = false\n",
+			map[string]string{"key": "user.name", "value": "x"}, "[core]\n\tbare = false\n\n[user]\nname = x\n"},
+		{"ini 删除整节", "ini.delete", "a.ini", "[a]\nx = 1\n\n[b]\ny = 2\n",
+			map[string]string{"key": "b"}, "[a]\nx = 1\n"},
+		{"dotenv export 前缀", "ini.set", ".env", "A=1 # c\nexport B=2\n",
+			map[string]string{"key": "B", "value": "3"}, "A=1 # c\nexport B=3\n"},
+		{"dotenv 保留 # 注释", "ini.set", ".env", "A=1 # c\n",
+			map[string]string{"key": "A", "value": "2"}, "A=2 # c\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{c.rel: c.src})
+			if err := ConfigEdit(c.op, repo, c.rel, "", c.args, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, repo, c.rel); got != c.want {
+				t.Fatalf("内容 = %q\n期望 = %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConfigEditErrors(t *testing.T) {
+	cases := []struct {
+		name, op, rel, src string
+		args               map[string]string
+	}{
+		{"create=false", "ini.set", "a.ini", "[a]\nx = 1\n", map[string]string{"key": "a.y", "value": "2", "create": "false"}},
+		{"dotenv 多层键", "ini.set", ".env", "A=1\n", map[string]string{"key": "a.b", "value": "2"}},
+		{"yaml 标量下不能建子键", "yaml.set", "a.yml", "a: 1\n", map[string]string{"key": "a.b", "value": "2", "create": "false"}},
+		{"toml 语法错误", "toml.set", "a.toml", "[tool\nx = 1\n", map[string]string{"key": "tool.x", "value": "2"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{c.rel: c.src})
+			if err := ConfigEdit(c.op, repo, c.rel, "", c.args, nil, nil); err == nil {
+				t.Fatalf("应报错，结果：%q", readTestFile(t, repo, c.rel))
+			}
+		})
+	}
+}
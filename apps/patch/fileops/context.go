@@ -0,0 +1,109 @@
+package fileops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// 多行片段锚点：context< / start-context< / end-context<
+// 片段是一段连续行，必须按顺序整体命中（类似 unified diff 的 hunk 上下文）：
+//   - 默认逐行比较，忽略行尾空白
+//   - context-ws=ignore：忽略全部空白差异（行首缩进、行内连续空白折叠为一个空格）
+//   - 片段首尾的空行会被忽略；中间的空行参与匹配
+//
+
+// contextLines 把多行参数拆成片段行（去掉首尾空行）
+func contextLines(raw string) []string {
+	ls := strings.Split(normalizeLF(raw), "\n")
+	for len(ls) > 0 && strings.TrimSpace(ls[0]) == "" {
+		ls = ls[1:]
+	}
+	for len(ls) > 0 && strings.TrimSpace(ls[len(ls)-1]) == "" {
+		ls = ls[:len(ls)-1]
+	}
+	return ls
+}
+
+func contextIgnoreWS(args map[string]string) bool {
+	return strings.EqualFold(strings.TrimSpace(args["context-ws"]), "ignore")
+}
+
+func ctxEqual(line, want string, ignoreWS bool) bool {
+	if ignoreWS {
+		return strings.Join(strings.Fields(line), " ") == strings.Join(strings.Fields(want), " ")
+	}
+	return sameLine(line, want)
+}
+
+// findContext 在 [from..to]（1-based 闭区间）内查找片段的全部起始行号；片段必须完整落在区间内
+func findContext(lines, ctx []string, from, to int, ignoreWS bool) []int {
+	var out []int
+	for s := from; s+len(ctx)-1 <= to; s++ {
+		ok := true
+		for i, c := range ctx {
+			if !ctxEqual(lines[s-1+i], c, ignoreWS) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// pickContext 在 [from..to] 内定位唯一片段；多处命中时用 nth 选择；未命中且 fuzzy=true 时模糊定位。
+// 返回片段起始行号（1-based）与片段行数
+func pickContext(lines []string, name, raw string, args map[string]string, from, to, nth int, logger DualLogger) (int, int, error) {
+	ctx := contextLines(raw)
+	if len(ctx) == 0 {
+		return 0, 0, fmt.Errorf("%s 为空", name)
+	}
+	if from < 1 {
+		from = 1
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	hits := findContext(lines, ctx, from, to, contextIgnoreWS(args))
+	switch {
+	case len(hits) == 0 && fuzzyOn(args):
+		h, err := fuzzyFind(lines, ctx, from, to, args)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s %v", name, err)
+		}
+		if logger != nil {
+			logger.Log("🔍 %s 模糊命中 %s", name, h)
+		}
+		return h.start, h.n, nil
+	case len(hits) == 0:
+		return 0, 0, fmt.Errorf("%s 未命中（%d 行片段，范围 [%d..%d]）", name, len(ctx), from, to)
+	case len(hits) == 1:
+		return hits[0], len(ctx), nil
+	case nth > 0 && nth <= len(hits):
+		return hits[nth-1], len(ctx), nil
+	}
+	return 0, 0, fmt.Errorf("%s 多处命中 %v（可用 nth=1..%d 选择）", name, hits, len(hits))
+}
+
+// contextAt 解析 context-at=（片段内第几行作为目标行；负数从末尾数，默认 1）
+func contextAt(args map[string]string, n int) (int, error) {
+	raw := strings.TrimSpace(args["context-at"])
+	if raw == "" {
+		return 0, nil
+	}
+	if strings.EqualFold(raw, "last") {
+		return n - 1, nil
+	}
+	at, err := strconv.Atoi(raw)
+	if err != nil || at == 0 || at > n || -at > n {
+		return 0, fmt.Errorf("context-at=%q 超出片段范围（1..%d 或 -1..-%d）", raw, n, n)
+	}
+	if at < 0 {
+		return n + at, nil
+	}
+	return at - 1, nil
+}
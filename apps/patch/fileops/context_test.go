@@ -0,0 +1,68 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPickContextUniqueness(t *testing.T) {
+	lines := []string{
+		"if err != nil {",
+		"\treturn err",
+		"}",
+		"x := 1",
+		"if err != nil {",
+		"    return   err  ",
+		"}",
+		"y := 2",
+	}
+	cases := []struct {
+		name, ctx string
+		args      map[string]string
+		from, to  int
+		nth       int
+		want      int
+		err       string
+	}{
+		{"多行片段唯一", "}\nx := 1", nil, 1, 8, 0, 3, ""},
+		{"首尾空行忽略", "\n\n}\ny := 2\n\n", nil, 1, 8, 0, 7, ""},
+		{"行尾空白忽略、缩进不忽略", "if err != nil {\n\treturn err", nil, 1, 8, 0, 1, ""},
+		{"context-ws=ignore 后两处命中", "if err != nil {\nreturn err", map[string]string{"context-ws": "ignore"}, 1, 8, 0, 0, "多处命中 [1 5]"},
+		{"nth 选择", "if err != nil {\nreturn err", map[string]string{"context-ws": "ignore"}, 1, 8, 2, 5, ""},
+		{"单行片段多处", "}", nil, 1, 8, 0, 0, "多处命中 [3 7]"},
+		{"范围缩小后唯一", "}", nil, 4, 8, 0, 7, ""},
+		{"片段必须完整落在范围内", "}\ny := 2", nil, 1, 7, 0, 0, "未命中"},
+		{"空片段", "\n\n", nil, 1, 8, 0, 0, "为空"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args := c.args
+			if args == nil {
+				args = map[string]string{}
+			}
+			got, _, err := pickContext(lines, "context", c.ctx, args, c.from, c.to, c.nth, nil)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v，期望包含 %q", err, c.err)
+				}
+				return
+			}
+			if err != nil || got != c.want {
+				t.Fatalf("got L%d err=%v，期望 L%d", got, err, c.want)
+			}
+		})
+	}
+}
+
+func TestContextAt(t *testing.T) {
+	for raw, want := range map[string]int{"": 0, "1": 0, "3": 2, "-1": 2, "last": 2, "-3": 0} {
+		if got, err := contextAt(map[string]string{"context-at": raw}, 3); err != nil || got != want {
+			t.Errorf("context-at=%q：got %d err=%v，期望 %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"0", "4", "-4", "x"} {
+		if _, err := contextAt(map[string]string{"context-at": raw}, 3); err == nil {
+			t.Errorf("context-at=%q 应报错", raw)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+)
+
+// file.ensure-line / file.ensure-block —— 声明式地保证一行 / 一段内容存在或不存在（类似 Ansible lineinfile）
+//   - state=present（默认）| absent；文件已满足要求时不做改动（记为已应用）；present 时文件不存在则新建
+//   - 比较时忽略每行首尾空白；作用域参数（start-keys/end-keys/start-context/end-context/end=auto/scope=）把查找与插入限制在范围内
+//   - 插入位置：缺省为作用域末尾（结束行之前；无作用域即 EOF）；after= / before= 锚点行（按 match= 模式，多处命中用 nthl 选择）；
+//     sorted=true 按字典序插入作用域内部（跳过空行与 # // 注释行，仅 ensure-line）
+//   - 插入内容本身无缩进时，沿用锚点行 / 相邻行的缩进（无作用域的 EOF 追加除外）
+//
+// file.ensure-line：line= 或单行正文
+//   - regexp=：RE2 正则；命中时用 line 替换该行（多处命中用 nthl 选择），未命中但整行已存在视为已应用；
+//     state=absent 时删除所有命中行（无 regexp= 时删除所有与 line 相同的行）
+//
+// file.ensure-block：正文为整段内容，按连续行整体比较；absent 删除所有出现处。内容会变化的托管区域请用 anchor.*
+func Ensure(op, repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	lines, ff, err := readLines(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	state := strings.ToLower(strings.TrimSpace(args["state"]))
+	switch state {
+	case "":
+		state = "present"
+	case "present", "absent":
+	default:
+		return fmt.Errorf("%s: 未知 state=%q（支持 present|absent）", op, state)
+	}
+
+	e := &ensurer{lines: lines, args: args}
+	var where string
+	switch op {
+	case "file.ensure-line":
+		line, err := ensureLineArg(op, body, args)
+		if err != nil {
+			return err
+		}
+		where = fmt.Sprintf("%s %q", rel, strings.TrimSpace(line))
+		if !ff.exists && state == "absent" {
+			_, err := idemSkip(op, where, stateApplied, args, logger)
+			return err
+		}
+		if e.sc, err = resolveScope(rel, lines, args, logger); err != nil {
+			return fmt.Errorf("%s: %s %w", op, where, err)
+		}
+		err = e.line(line, state)
+		if err != nil {
+			return fmt.Errorf("%s: %s %w", op, where, err)
+		}
+	case "file.ensure-block":
+		block := strings.Trim(normalizeLF(body), "\n")
+		if strings.TrimSpace(block) == "" {
+			return fmt.Errorf("%s: 缺少正文（要保证存在 / 不存在的内容）", op)
+		}
+		bl := strings.Split(block, "\n")
+		where = fmt.Sprintf("%s（%d 行）", rel, len(bl))
+		if !ff.exists && state == "absent" {
+			_, err := idemSkip(op, where, stateApplied, args, logger)
+			return err
+		}
+		if e.sc, err = resolveScope(rel, lines, args, logger); err != nil {
+			return fmt.Errorf("%s: %s %w", op, where, err)
+		}
+		if err := e.block(bl, state); err != nil {
+			return fmt.Errorf("%s: %s %w", op, where, err)
+		}
+	default:
+		return errors.New("未知指令: " + op)
+	}
+
+	if e.done == "" {
+		_, err := idemSkip(op, where, stateApplied, args, logger)
+		return err
+	}
+	if err := writeLines(abs, e.lines, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ %s: %s %s", op, where, e.done)
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+// ensureLineArg 取 line=，缺省取正文；只允许一行
+func ensureLineArg(op, body string, args map[string]string) (string, error) {
+	line, has := args["line"]
+	content := strings.Trim(normalizeLF(body), "\n")
+	switch {
+	case has && strings.TrimSpace(content) != "":
+		return "", fmt.Errorf("%s: line= 与正文只能使用其一", op)
+	case !has:
+		line = content
+	}
+	if strings.Contains(line, "\n") {
+		return "", fmt.Errorf("%s: 只能指定一行（多行内容请用 file.ensure-block）", op)
+	}
+	if strings.TrimSpace(line) == "" {
+		return "", fmt.Errorf("%s: 缺少 line=（或单行正文）", op)
+	}
+	return line, nil
+}
+
+type ensurer struct {
+	lines []string // 行模型：每行带 '\n'
+	args  map[string]string
+	sc    scope
+	done  string // 改动描述；为空表示已满足
+}
+
+func (e *ensurer) text(i int) string { return strings.TrimSpace(e.lines[i]) }
+
+func (e *ensurer) line(line, state string) error {
+	key := strings.TrimSpace(line)
+	var re *regexp.Regexp
+	if raw := e.args["regexp"]; strings.TrimSpace(raw) != "" {
+		var err error
+		if re, err = regexp.Compile(raw); err != nil {
+			return fmt.Errorf("regexp 无效 %q：%v", raw, err)
+		}
+	}
+	var hits []int
+	for i := e.sc.start - 1; i < e.sc.end; i++ {
+		if re != nil && re.MatchString(strings.TrimRight(e.lines[i], "\n")) || re == nil && e.text(i) == key {
+			hits = append(hits, i)
+		}
+	}
+
+	if state == "absent" {
+		for k := len(hits) - 1; k >= 0; k-- {
+			e.lines = splice(e.lines, hits[k], 1, nil)
+		}
+		if len(hits) > 0 {
+			e.done = "删除 " + lineNums(hits)
+		}
+		return nil
+	}
+
+	if re != nil && len(hits) > 0 {
+		i := hits[len(hits)-1]
+		if len(hits) > 1 {
+			nthl := parseInt(e.args["nthl"])
+			if nthl < 1 || nthl > len(hits) {
+				return fmt.Errorf("regexp %q 多处命中 %s（可用 nthl=1..%d 选择）", re, lineNums(hits), len(hits))
+			}
+			i = hits[nthl-1]
+		}
+		nl := withIndent(line, leadingWS(e.lines[i])) + "\n"
+		if e.lines[i] != nl {
+			e.lines[i] = nl
+			e.done = fmt.Sprintf("替换 L%d", i+1)
+		}
+		return nil
+	}
+	if re != nil {
+		for i := e.sc.start - 1; i < e.sc.end; i++ {
+			if e.text(i) == key {
+				return nil
+			}
+		}
+	} else if len(hits) > 0 {
+		return nil
+	}
+	return e.insert([]string{line}, true)
+}
+
+func (e *ensurer) block(block []string, state string) error {
+	var hits []int
+	for i := e.sc.start - 1; i+len(block) <= e.sc.end; i++ {
+		if e.blockAt(i, block) {
+			hits = append(hits, i)
+			i += len(block) - 1
+		}
+	}
+	if state == "absent" {
+		for k := len(hits) - 1; k >= 0; k-- {
+			e.lines = splice(e.lines, hits[k], len(block), nil)
+		}
+		if len(hits) > 0 {
+			e.done = fmt.Sprintf("删除 %s 起的 %d 行", lineNums(hits), len(block))
+		}
+		return nil
+	}
+	if len(hits) > 0 {
+		return nil
+	}
+	return e.insert(block, false)
+}
+
+func (e *ensurer) blockAt(i int, block []string) bool {
+	for k, b := range block {
+		if e.text(i+k) != strings.TrimSpace(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// insert 按 after= / before= / sorted=true / 作用域末尾确定位置后插入
+func (e *ensurer) insert(content []string, sortable bool) error {
+	after, before := strings.TrimSpace(e.args["after"]), strings.TrimSpace(e.args["before"])
+	sorted := argOn(e.args, "sorted")
+	if btoi(after != "")+btoi(before != "")+btoi(sorted) > 1 {
+		return errors.New("after= / before= / sorted=true 只能使用其一")
+	}
+	lo, hi := e.inner()
+	tail := hi // 作用域末尾（跳过结尾空行）
+	for tail > lo && e.text(tail-1) == "" {
+		tail--
+	}
+	idx, ref := tail, -1
+	switch {
+	case after != "" || before != "":
+		raw, name := after, "after"
+		if before != "" {
+			raw, name = before, "before"
+		}
+		k, _, err := pickKeys(e.lines, raw, e.args, e.sc.start, e.sc.end, parseInt(e.args["nthl"]))
+		if err != nil {
+			return fmt.Errorf("%s 定位失败：%v", name, err)
+		}
+		idx, ref = k, k-1
+		if before != "" {
+			idx = k - 1
+		}
+	case sorted:
+		if !sortable {
+			return errors.New("sorted=true 仅用于 file.ensure-line")
+		}
+		key := strings.TrimSpace(content[0])
+		for i := lo; i < hi; i++ {
+			t := e.text(i)
+			if t == "" || strings.HasPrefix(t, "#") || strings.HasPrefix(t, "//") {
+				continue
+			}
+			if t > key {
+				idx, ref = i, i
+				break
+			}
+			idx, ref = i+1, i
+		}
+	default:
+		if tail > lo && e.scoped() {
+			ref = tail - 1
+		}
+	}
+
+	indent := ""
+	if ref >= 0 && leadingWS(content[0]) == "" {
+		indent = leadingWS(e.lines[ref])
+	}
+	ins := make([]string, len(content))
+	for i, l := range content {
+		if strings.TrimSpace(l) != "" {
+			l = indent + l
+		}
+		ins[i] = l + "\n"
+	}
+	e.lines = insertAt(e.lines, idx, ins)
+	e.done = fmt.Sprintf("插入 L%d", idx+1)
+	return nil
+}
+
+// scoped 是否给出了作用域起点 / 终点参数
+func (e *ensurer) scoped() bool {
+	for _, k := range []string{"scope", "start-keys", "start-context", "end-keys", "end-context", "end"} {
+		if strings.TrimSpace(e.args[k]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// inner 作用域内部（0-based 半开区间）：有起始定位时跳过起始行，有结束定位时跳过结束行（如 go.mod 的 "require (" 与 ")"）
+func (e *ensurer) inner() (int, int) {
+	lo, hi := e.sc.start-1, e.sc.end
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if strings.TrimSpace(e.args[k]) != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if has("scope", "start-keys", "start-context") {
+		lo++
+	}
+	if has("scope", "end-keys", "end-context", "end") {
+		hi--
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// leadingWS 行首的空白（不含换行）
+func leadingWS(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+func withIndent(line, indent string) string {
+	if leadingWS(line) != "" {
+		return line
+	}
+	return indent + line
+}
+
+// lineNums 0-based 下标 → "L3 L9"
+func lineNums(idx []int) string {
+	parts := make([]string, len(idx))
+	for i, n := range idx {
+		parts[i] = fmt.Sprintf("L%d", n+1)
+	}
+	return strings.Join(parts, " ")
+}
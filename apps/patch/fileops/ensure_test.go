@@ -0,0 +1,105 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+const ensureGoMod = "module ex\n\nrequire (\n\tb.io/b v1\n\td.io/d v1\n)\n\nreplace x => ./x\n"
+
+func TestEnsureLine(t *testing.T) {
+	cases := []struct {
+		name, src string
+		args      map[string]string
+		want      string
+	}{
+		{"已存在不改动", "a\nb\n", map[string]string{"line": "  b  "}, "a\nb\n"},
+		{"缺失追加到末尾", "a\nb\n", map[string]string{"line": "c"}, "a\nb\nc\n"},
+		{"after 锚点", "a\nb\n", map[string]string{"line": "x", "after": "a"}, "a\nx\nb\n"},
+		{"before 锚点", "a\nb\n", map[string]string{"line": "x", "before": "b"}, "a\nx\nb\n"},
+		// sorted
+		{"sorted 插在中间", "a\nc\ne\n", map[string]string{"line": "d", "sorted": "true"}, "a\nc\nd\ne\n"},
+		{"sorted 插在开头", "b\nc\n", map[string]string{"line": "a", "sorted": "true"}, "a\nb\nc\n"},
+		{"sorted 插在末尾", "a\nb\n", map[string]string{"line": "c", "sorted": "true"}, "a\nb\nc\n"},
+		{"sorted 跳过注释与空行", "# z\na\n\n// y\nc\n", map[string]string{"line": "b", "sorted": "true"}, "# z\na\n\n// y\nb\nc\n"},
+		{"sorted 限定在作用域内并沿用缩进", ensureGoMod,
+			map[string]string{"line": "c.io/c v1", "sorted": "true", "start-keys": "require (", "end-keys": ")"},
+			"module ex\n\nrequire (\n\tb.io/b v1\n\tc.io/c v1\n\td.io/d v1\n)\n\nreplace x => ./x\n"},
+		{"sorted 作用域内排在最前", ensureGoMod,
+			map[string]string{"line": "a.io/a v1", "sorted": "true", "start-keys": "require (", "end-keys": ")"},
+			"module ex\n\nrequire (\n\ta.io/a v1\n\tb.io/b v1\n\td.io/d v1\n)\n\nreplace x => ./x\n"},
+		{"sorted 作用域内排在最后", ensureGoMod,
+			map[string]string{"line": "e.io/e v1", "sorted": "true", "start-keys": "require (", "end-keys": ")"},
+			"module ex\n\nrequire (\n\tb.io/b v1\n\td.io/d v1\n\te.io/e v1\n)\n\nreplace x => ./x\n"},
+		// regexp
+		{"regexp 命中则替换并保留缩进", ensureGoMod,
+			map[string]string{"line": "d.io/d v2", "regexp": `^\s*d\.io/d `},
+			"module ex\n\nrequire (\n\tb.io/b v1\n\td.io/d v2\n)\n\nreplace x => ./x\n"},
+		{"regexp 命中且已是目标行", "port = 80\n", map[string]string{"line": "port = 80", "regexp": `^port\s*=`}, "port = 80\n"},
+		{"regexp 未命中但整行已存在", "x = 1\n", map[string]string{"line": "x = 1", "regexp": `^y`}, "x = 1\n"},
+		{"regexp 未命中则插入", "a\n", map[string]string{"line": "port = 80", "regexp": `^port\s*=`}, "a\nport = 80\n"},
+		{"regexp 多处命中用 nthl 选择", "v=1\nv=2\n", map[string]string{"line": "v=3", "regexp": `^v=`, "nthl": "2"}, "v=1\nv=3\n"},
+		{"regexp 只在作用域内查找", ensureGoMod,
+			map[string]string{"line": "x.io/x v1", "regexp": `x`, "start-keys": "require (", "end-keys": ")"},
+			"module ex\n\nrequire (\n\tb.io/b v1\n\td.io/d v1\n\tx.io/x v1\n)\n\nreplace x => ./x\n"},
+		// absent
+		{"absent 删除所有相同行", "a\nb\na\n", map[string]string{"line": "a", "state": "absent"}, "b\n"},
+		{"absent 忽略首尾空白", "  a\nb\n", map[string]string{"line": "a", "state": "absent"}, "b\n"},
+		{"absent 不存在不改动", "b\n", map[string]string{"line": "a", "state": "absent"}, "b\n"},
+		{"absent 删除所有 regexp 命中行", "v=1\nw\nv=2\n", map[string]string{"line": "v=3", "regexp": `^v=`, "state": "absent"}, "w\n"},
+		{"absent 只删作用域内", ensureGoMod,
+			map[string]string{"line": "x", "regexp": `\.io/`, "state": "absent", "start-keys": "require (", "end-keys": ")"},
+			"module ex\n\nrequire (\n)\n\nreplace x => ./x\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.txt": c.src})
+			if err := Ensure("file.ensure-line", repo, "a.txt", "", c.args, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, repo, "a.txt"); got != c.want {
+				t.Fatalf("内容 = %q\n期望 = %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnsureLineAbsentMissingFile(t *testing.T) {
+	repo := t.TempDir()
+	if err := Ensure("file.ensure-line", repo, "none.txt", "", map[string]string{"line": "a", "state": "absent"}, nil, nil); err != nil {
+		t.Fatalf("文件不存在时 absent 视为已应用：%v", err)
+	}
+	if err := Ensure("file.ensure-line", repo, "new.txt", "", map[string]string{"line": "a"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, "new.txt"); got != "a\n" {
+		t.Fatalf("present 时应新建文件：%q", got)
+	}
+}
+
+func TestEnsureLineErrors(t *testing.T) {
+	cases := []struct {
+		name, body string
+		args       map[string]string
+		want       string
+	}{
+		{"regexp 多处命中未给 nthl", "", map[string]string{"line": "v=3", "regexp": `^v=`}, "多处命中 L1 L2"},
+		{"regexp 无效", "", map[string]string{"line": "v", "regexp": `(`}, "regexp 无效"},
+		{"sorted 与 after 同时使用", "", map[string]string{"line": "z", "sorted": "true", "after": "v=1"}, "只能使用其一"},
+		{"未知 state", "", map[string]string{"line": "z", "state": "gone"}, "未知 state"},
+		{"line 与正文同时给出", "z\n", map[string]string{"line": "z"}, "只能使用其一"},
+		{"多行正文", "y\nz\n", map[string]string{}, "file.ensure-block"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.txt": "v=1\nv=2\n"})
+			err := Ensure("file.ensure-line", repo, "a.txt", c.body, c.args, nil, nil)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("err = %v，期望包含 %q", err, c.want)
+			}
+			if got := readTestFile(t, repo, "a.txt"); got != "v=1\nv=2\n" {
+				t.Fatalf("报错时不应改动文件：%q", got)
+			}
+		})
+	}
+}
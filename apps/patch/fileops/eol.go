@@ -36,7 +36,11 @@ func FileEOL(repo, rel string, style string, ensureNL bool, logger DualLogger) e
 			s += "\n"
 		}
 	}
-	if err := os.WriteFile(abs, []byte(s), 0o644); err != nil {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(abs); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := WriteFileAtomic(abs, []byte(s), mode); err != nil {
 		return err
 	}
 	if logger != nil {
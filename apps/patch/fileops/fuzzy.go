@@ -0,0 +1,133 @@
+package fileops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// 模糊匹配（fuzzy=true）：AI 生成的片段常与文件在缩进、行尾空白或个别 token 上有出入。
+// 精确匹配失败后依次尝试：
+//   1) 空白归一：忽略缩进与行内空白差异，唯一命中即采用（相似度记为 1.00）
+//   2) 相似度窗口：在范围内滑动同样行数的窗口，按字符编辑距离打分，取最高分；
+//      低于 fuzzy-threshold（默认 0.8）拒绝；第二名与最高分相差不足 0.02 视为近似平局，拒绝猜测
+// 选中的位置与得分会写入日志。
+//
+
+const (
+	defaultFuzzyThreshold = 0.8
+	fuzzyTieMargin        = 0.02
+)
+
+type fuzzyHit struct {
+	start, n int     // 起始行号（1-based）与行数
+	score    float64 // 相似度 0..1
+	how      string  // 命中方式
+}
+
+func fuzzyOn(args map[string]string) bool { return argOn(args, "fuzzy") }
+
+func fuzzyThreshold(args map[string]string) (float64, error) {
+	raw := strings.TrimSpace(args["fuzzy-threshold"])
+	if raw == "" {
+		return defaultFuzzyThreshold, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v <= 0 || v > 1 {
+		return 0, fmt.Errorf("fuzzy-threshold=%q 非法（取值 (0,1]）", raw)
+	}
+	return v, nil
+}
+
+// fuzzyFind 在 [from..to]（1-based 闭区间）内模糊定位片段 want
+func fuzzyFind(lines, want []string, from, to int, args map[string]string) (fuzzyHit, error) {
+	n := len(want)
+	if from < 1 {
+		from = 1
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	if n == 0 || to-from+1 < n {
+		return fuzzyHit{}, fmt.Errorf("fuzzy: 范围 [%d..%d] 不足 %d 行", from, to, n)
+	}
+	th, err := fuzzyThreshold(args)
+	if err != nil {
+		return fuzzyHit{}, err
+	}
+
+	// 1) 空白归一
+	switch hits := findContext(lines, want, from, to, true); {
+	case len(hits) == 1:
+		return fuzzyHit{start: hits[0], n: n, score: 1, how: "空白归一"}, nil
+	case len(hits) > 1:
+		return fuzzyHit{}, fmt.Errorf("fuzzy: 空白归一后多处命中 %v，拒绝猜测", hits)
+	}
+
+	// 2) 相似度窗口
+	normWant := make([]string, n)
+	for i, w := range want {
+		normWant[i] = normWS(w)
+	}
+	best, second := fuzzyHit{start: -1, score: -1}, fuzzyHit{start: -1, score: -1}
+	for s := from; s+n-1 <= to; s++ {
+		dist, total := 0, 0
+		for i := 0; i < n; i++ {
+			a, b := []rune(normWS(lines[s-1+i])), []rune(normWant[i])
+			dist += editDistance(a, b)
+			total += max(len(a), len(b))
+		}
+		score := 1.0
+		if total > 0 {
+			score = 1 - float64(dist)/float64(total)
+		}
+		h := fuzzyHit{start: s, n: n, score: score, how: "相似度"}
+		if score > best.score {
+			best, second = h, best
+		} else if score > second.score {
+			second = h
+		}
+	}
+	if best.score < th {
+		return fuzzyHit{}, fmt.Errorf("fuzzy: 最佳候选 L%d 相似度 %.2f 低于阈值 %.2f", best.start, best.score, th)
+	}
+	if second.start > 0 && best.score-second.score < fuzzyTieMargin {
+		return fuzzyHit{}, fmt.Errorf("fuzzy: 候选相似度接近（L%d=%.2f / L%d=%.2f），拒绝猜测", best.start, best.score, second.start, second.score)
+	}
+	return best, nil
+}
+
+func (h fuzzyHit) String() string {
+	return fmt.Sprintf("L%d-L%d %s %.2f", h.start, h.start+h.n-1, h.how, h.score)
+}
+
+// normWS 折叠全部空白（含行首缩进与行尾 \r）
+func normWS(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+// editDistance 字符级编辑距离（Levenshtein）
+func editDistance(a, b []rune) int {
+	if len(a) == 0 {
+		return len(b)
+	}
+	if len(b) == 0 {
+		return len(a)
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,51 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+// mutate 把 s 的前 k 个字符换成 '#'（k 次编辑）
+func mutate(s string, k int) string { return strings.Repeat("#", k) + s[k:] }
+
+func TestFuzzyFind(t *testing.T) {
+	const short = "alpha beta gamma delta"  // 22 字符：1 次编辑 ≈ 0.045
+	long := strings.Repeat("abcdefghij", 6) // 60 字符：1 次编辑 ≈ 0.017 < 平局边界 0.02
+	cases := []struct {
+		name  string
+		lines []string
+		want  string
+		args  map[string]string
+		start int
+		how   string
+		err   string
+	}{
+		{"空白归一", []string{"x", "  alpha   beta gamma delta  "}, short, nil, 2, "空白归一", ""},
+		{"空白归一后多处", []string{short + " ", "\t" + short}, short, nil, 0, "", "多处命中"},
+		{"相似度明显领先", []string{mutate(short, 1), "zzz"}, short, nil, 1, "相似度", ""},
+		{"完全平局", []string{mutate(short, 1), "x" + short[1:]}, short, nil, 0, "", "拒绝猜测"},
+		{"差距小于边界视为平局", []string{mutate(long, 2), mutate(long, 3)}, long, nil, 0, "", "候选相似度接近"},
+		{"差距大于边界可以选", []string{mutate(long, 3), mutate(long, 1)}, long, nil, 2, "相似度", ""},
+		{"低于阈值", []string{"something else entirely"}, short, nil, 0, "", "低于阈值"},
+		{"自定义阈值放宽", []string{mutate(short, 6), "zzz"}, short, map[string]string{"fuzzy-threshold": "0.7"}, 1, "相似度", ""},
+		{"阈值非法", []string{short}, "alpha", map[string]string{"fuzzy-threshold": "1.5"}, 0, "", "fuzzy-threshold"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args := c.args
+			if args == nil {
+				args = map[string]string{}
+			}
+			h, err := fuzzyFind(c.lines, []string{c.want}, 1, len(c.lines), args)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v，期望包含 %q", err, c.err)
+				}
+				return
+			}
+			if err != nil || h.start != c.start || h.how != c.how {
+				t.Fatalf("got %v err=%v，期望 L%d %s", h, err, c.start, c.how)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package fileops
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// 路径通配：按仓库相对路径（/ 分隔）匹配
+//   *   匹配一段路径中的任意字符（不跨 /）
+//   **  匹配任意层目录（含零层），如 apps/**/*.go 同时匹配 apps/a.go 与 apps/x/y/b.go
+//   ?   匹配单个字符（不含 /）
+//   [..] 字符类，同 path.Match
+// 不含 / 的模式（如 *.pem）匹配任意层级下的同名文件
+//
+
+// Glob 编译后的通配模式
+type Glob struct {
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// HasGlobMeta 路径是否包含通配符
+func HasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// CompileGlob 编译通配模式
+func CompileGlob(pat string) (*Glob, error) {
+	p := strings.Trim(filepath.ToSlash(strings.TrimSpace(pat)), "/")
+	if p == "" {
+		return nil, fmt.Errorf("通配模式为空")
+	}
+	if !strings.Contains(p, "/") {
+		p = "**/" + p
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '*' && strings.HasPrefix(p[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(p[i+1:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("通配模式 %q 的 [ 未闭合", pat)
+			}
+			class := p[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += j + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("通配模式 %q 无效：%v", pat, err)
+	}
+	return &Glob{Pattern: pat, re: re}, nil
+}
+
+// Match rel 为仓库相对路径（/ 或系统分隔符均可）
+func (g *Glob) Match(rel string) bool {
+	return g.re.MatchString(filepath.ToSlash(rel))
+}
+
+// CompileGlobs 编译逗号/空白分隔的模式列表（exclude= / include= 等参数）
+func CompileGlobs(list string) ([]*Glob, error) {
+	var out []*Glob
+	for _, p := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' }) {
+		g, err := CompileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// MatchAny 是否命中任一模式
+func MatchAny(globs []*Glob, rel string) bool {
+	for _, g := range globs {
+		if g.Match(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// WalkGlob 遍历工作区中匹配 g 的普通文件（跳过 .git 与 .xgit_ 开头的内部目录，不跟随符号链接目录），按路径排序
+func WalkGlob(repo string, g *Glob) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(repo, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(repo, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.EqualFold(d.Name(), ".git") || strings.HasPrefix(d.Name(), ".xgit_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && g.Match(rel) {
+			out = append(out, rel)
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out, err
+}
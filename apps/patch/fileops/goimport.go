@@ -0,0 +1,97 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+	"xgit/apps/patch/preflight"
+)
+
+// importSpec 一条 import：path 与可选别名
+type importSpec struct{ name, path string }
+
+// parseImportSpecs 参数 import=（可配 name=）与正文逐行（"path" 或 alias "path"，引号可省略）
+func parseImportSpecs(body string, args map[string]string) ([]importSpec, error) {
+	var out []importSpec
+	if p := strings.Trim(strings.TrimSpace(args["import"]), "\"`"); p != "" {
+		out = append(out, importSpec{name: strings.TrimSpace(args["name"]), path: p})
+	}
+	for _, l := range strings.Split(normalizeLF(body), "\n") {
+		fs := strings.Fields(strings.TrimSpace(l))
+		switch len(fs) {
+		case 0:
+		case 1:
+			out = append(out, importSpec{path: strings.Trim(fs[0], "\"`")})
+		case 2:
+			out = append(out, importSpec{name: fs[0], path: strings.Trim(fs[1], "\"`")})
+		default:
+			return nil, fmt.Errorf("无法解析 import 行：%q", l)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("缺少 import（参数 import= 或正文逐行列出）")
+	}
+	return out, nil
+}
+
+// go.import.add —— 向 Go 文件添加 import（已存在则跳过；排序/分组交给 gofmt 预检）
+func GoImportAdd(repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	return goImportEdit("go.import.add", repo, rel, body, args, git, logger, func(src []byte, sp importSpec) ([]byte, bool, error) {
+		return preflight.AddImport(src, sp.path, sp.name)
+	})
+}
+
+// go.import.remove —— 从 Go 文件移除 import（不存在则跳过）
+func GoImportRemove(repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	return goImportEdit("go.import.remove", repo, rel, body, args, git, logger, func(src []byte, sp importSpec) ([]byte, bool, error) {
+		return preflight.RemoveImport(src, sp.path)
+	})
+}
+
+func goImportEdit(op, repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger,
+	edit func([]byte, importSpec) ([]byte, bool, error)) error {
+	if !strings.HasSuffix(rel, ".go") {
+		return fmt.Errorf("%s: 仅适用于 .go 文件：%s", op, rel)
+	}
+	specs, err := parseImportSpecs(body, args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	abs := filepath.Join(repo, rel)
+	orig, ff, err := readText(abs)
+	if err != nil {
+		return err
+	}
+	src := []byte(orig)
+
+	var done []string
+	for _, sp := range specs {
+		out, changed, err := edit(src, sp)
+		if err != nil {
+			return fmt.Errorf("%s: %s %w", op, rel, err)
+		}
+		if !changed {
+			if skip, err := idemSkip(op, rel+" "+sp.path, stateApplied, args, logger); err != nil {
+				return err
+			} else if !skip {
+				return fmt.Errorf("%s: %s 无需修改 %q", op, rel, sp.path)
+			}
+			continue
+		}
+		src = out
+		done = append(done, sp.path)
+	}
+	if len(done) == 0 {
+		return nil
+	}
+	if err := writeText(abs, string(src), ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("📦 %s: %s %v", op, rel, done)
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
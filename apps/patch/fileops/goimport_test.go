@@ -0,0 +1,66 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+const importSrc = "package a\n\nimport (\n\t\"fmt\"\n\tstr \"strings\"\n)\n\nvar _ = fmt.Sprint\nvar _ = str.ToUpper\n"
+
+func TestGoImportEdit(t *testing.T) {
+	cases := []struct {
+		name, op, src, body string
+		args                map[string]string
+		has, lacks          []string
+		err                 string
+	}{
+		{"添加", "go.import.add", importSrc, "\"os\"\n", nil, []string{"\t\"os\"\n", "str \"strings\""}, nil, ""},
+		{"添加带别名", "go.import.add", importSrc, "pf \"path/filepath\"\n", nil, []string{"\tpf \"path/filepath\"\n"}, nil, ""},
+		{"import= 与 name=", "go.import.add", importSrc, "", map[string]string{"import": "path/filepath", "name": "fp"}, []string{"\tfp \"path/filepath\"\n"}, nil, ""},
+		{"空白导入", "go.import.add", importSrc, "_ embed\n", nil, []string{"\t_ \"embed\"\n"}, nil, ""},
+		{"同路径同别名已存在", "go.import.add", importSrc, "str \"strings\"\n", nil, []string{"\tstr \"strings\"\n"}, nil, ""},
+		{"同路径不同别名", "go.import.add", importSrc, "\"strings\"\n", nil, nil, nil, "已以不同别名导入（str）"},
+		{"单行 import 改为分组", "go.import.add", "package a\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n", "\"os\"\n", nil,
+			[]string{"import (\n\t\"fmt\"\n\t\"os\"\n)\n"}, nil, ""},
+		{"移除带别名的 import", "go.import.remove", importSrc, "strings\n", nil, []string{"\t\"fmt\"\n"}, []string{"strings"}, ""},
+		{"移除唯一一条时删掉整个声明", "go.import.remove", "package a\n\nimport \"fmt\"\n\nvar x = 1\n", "\"fmt\"\n", nil,
+			[]string{"package a\n\nvar x = 1\n"}, []string{"import"}, ""},
+		{"移除不存在的 import", "go.import.remove", importSrc, "\"os\"\n", nil, []string{"\t\"fmt\"\n", "str \"strings\""}, nil, ""},
+		{"无法解析的 import 行", "go.import.add", importSrc, "a b c\n", nil, nil, nil, "无法解析"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.go": c.src})
+			args := c.args
+			if args == nil {
+				args = map[string]string{}
+			}
+			var err error
+			if c.op == "go.import.add" {
+				err = GoImportAdd(repo, "a.go", c.body, args, nil, nil)
+			} else {
+				err = GoImportRemove(repo, "a.go", c.body, args, nil, nil)
+			}
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v，期望包含 %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := readTestFile(t, repo, "a.go")
+			for _, s := range c.has {
+				if !strings.Contains(got, s) {
+					t.Errorf("缺少 %q：\n%s", s, got)
+				}
+			}
+			for _, s := range c.lacks {
+				if strings.Contains(got, s) {
+					t.Errorf("不应包含 %q：\n%s", s, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package fileops
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// Go 模块加载：go.rename / go.move-package 共用
+//   - 从包目录向上找到最近的 go.mod（不越出仓库），枚举模块内全部包（跳过 vendor、testdata、. 与 _ 开头的目录、嵌套模块）
+//   - 按当前平台的构建约束筛选文件；被约束排除的文件单独记录（无法做类型解析，只能提示）
+//   - 类型检查：模块内的包从源码检查，其余（标准库等）交给 go/importer 的 source 模式
+//
+
+// goModule 一个 Go 模块
+type goModule struct {
+	root string // 绝对路径
+	rel  string // 相对仓库根（/ 分隔，根目录为 ""）
+	path string // module 路径
+}
+
+// goPkgDir 模块内的一个包目录
+type goPkgDir struct {
+	dir        string // 绝对路径
+	rel        string // 相对仓库根（/ 分隔）
+	importPath string
+	files      []string // 参与构建的非测试文件（绝对路径）
+	tests      []string // 同包测试文件
+	xtests     []string // 外部测试包（package x_test）文件
+	ignored    []string // 被构建约束排除的文件
+}
+
+// findGoModule 从 repo/dirRel 向上查找 go.mod
+func findGoModule(repo, dirRel string) (*goModule, error) {
+	cur := filepath.Join(repo, filepath.FromSlash(dirRel))
+	for {
+		if mp, err := readModulePath(filepath.Join(cur, "go.mod")); err == nil {
+			rel, _ := filepath.Rel(repo, cur)
+			rel = filepath.ToSlash(rel)
+			if rel == "." {
+				rel = ""
+			}
+			return &goModule{root: cur, rel: rel, path: mp}, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if r, _ := filepath.Rel(repo, cur); r == "." || strings.HasPrefix(r, "..") {
+			return nil, fmt.Errorf("%s 不在任何 Go 模块内（仓库内未找到 go.mod）", dirRel)
+		}
+		cur = filepath.Dir(cur)
+	}
+}
+
+func readModulePath(gomod string) (string, error) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if fs := strings.Fields(sc.Text()); len(fs) >= 2 && fs[0] == "module" {
+			return strings.Trim(fs[1], "\"`"), nil
+		}
+	}
+	return "", fmt.Errorf("%s 缺少 module 声明", gomod)
+}
+
+// importPathOf 模块内目录的导入路径
+func (m *goModule) importPathOf(abs string) string {
+	r, _ := filepath.Rel(m.root, abs)
+	if r == "." {
+		return m.path
+	}
+	return path.Join(m.path, filepath.ToSlash(r))
+}
+
+// dirOf 模块内导入路径对应的目录；不属于本模块返回 ""
+func (m *goModule) dirOf(importPath string) string {
+	if importPath == m.path {
+		return m.root
+	}
+	if strings.HasPrefix(importPath, m.path+"/") {
+		return filepath.Join(m.root, filepath.FromSlash(strings.TrimPrefix(importPath, m.path+"/")))
+	}
+	return ""
+}
+
+// packages 枚举模块内全部包目录（按导入路径排序）
+func (m *goModule) packages(repo string) ([]*goPkgDir, error) {
+	var out []*goPkgDir
+	err := filepath.WalkDir(m.root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if p != m.root {
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir // 嵌套模块
+			}
+		}
+		pd, err := m.scanDir(repo, p)
+		if err != nil {
+			return err
+		}
+		if pd != nil {
+			out = append(out, pd)
+		}
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].importPath < out[j].importPath })
+	return out, err
+}
+
+// scanDir 按构建约束对目录中的 .go 文件分类；没有 .go 文件返回 nil
+func (m *goModule) scanDir(repo, dir string) (*goPkgDir, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, _ := filepath.Rel(repo, dir)
+	pd := &goPkgDir{dir: dir, rel: filepath.ToSlash(rel), importPath: m.importPathOf(dir)}
+	ctx := build.Default
+	for _, e := range ents {
+		n := e.Name()
+		if e.IsDir() || !strings.HasSuffix(n, ".go") {
+			continue
+		}
+		abs := filepath.Join(dir, n)
+		if ok, err := ctx.MatchFile(dir, n); err != nil || !ok {
+			pd.ignored = append(pd.ignored, abs)
+			continue
+		}
+		if !strings.HasSuffix(n, "_test.go") {
+			pd.files = append(pd.files, abs)
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), abs, nil, parser.PackageClauseOnly)
+		if err == nil && strings.HasSuffix(f.Name.Name, "_test") {
+			pd.xtests = append(pd.xtests, abs)
+		} else {
+			pd.tests = append(pd.tests, abs)
+		}
+	}
+	if len(pd.files)+len(pd.tests)+len(pd.xtests)+len(pd.ignored) == 0 {
+		return nil, nil
+	}
+	return pd, nil
+}
+
+// goUnit 一次类型检查的结果（一个包，或包 + 同包测试，或外部测试包）
+type goUnit struct {
+	dir   *goPkgDir
+	files []*ast.File
+	pkg   *types.Package
+	info  *types.Info
+	errs  []error
+}
+
+// goLoader 模块内的包按源码检查（带缓存），同时作为 types.Importer 供相互导入
+type goLoader struct {
+	fset   *token.FileSet
+	mod    *goModule
+	dirs   map[string]*goPkgDir // importPath → 包目录
+	parsed map[string]*ast.File // 绝对路径 → 语法树（同一文件在多个单元间共用，位置一致）
+	units  map[string]*goUnit   // importPath → 非测试单元
+	busy   map[string]bool
+	std    types.Importer
+}
+
+func newGoLoader(mod *goModule, dirs []*goPkgDir) *goLoader {
+	fset := token.NewFileSet()
+	l := &goLoader{
+		fset:   fset,
+		mod:    mod,
+		dirs:   map[string]*goPkgDir{},
+		parsed: map[string]*ast.File{},
+		units:  map[string]*goUnit{},
+		busy:   map[string]bool{},
+		std:    importer.ForCompiler(fset, "source", nil),
+	}
+	for _, d := range dirs {
+		l.dirs[d.importPath] = d
+	}
+	return l
+}
+
+// Import 实现 types.Importer
+func (l *goLoader) Import(p string) (*types.Package, error) {
+	if _, ok := l.dirs[p]; !ok {
+		return l.std.Import(p)
+	}
+	u, err := l.unit(p)
+	if err != nil {
+		return nil, err
+	}
+	return u.pkg, nil
+}
+
+// unit 模块内包的非测试单元
+func (l *goLoader) unit(p string) (*goUnit, error) {
+	if u := l.units[p]; u != nil {
+		return u, nil
+	}
+	if l.busy[p] {
+		return nil, fmt.Errorf("导入循环：%s", p)
+	}
+	l.busy[p] = true
+	defer delete(l.busy, p)
+	d := l.dirs[p]
+	u, err := l.check(d, p, d.files)
+	if err != nil {
+		return nil, err
+	}
+	l.units[p] = u
+	return u, nil
+}
+
+// check 对一组文件做类型检查；类型错误收集在 unit.errs 中（不中断）
+func (l *goLoader) check(d *goPkgDir, importPath string, paths []string) (*goUnit, error) {
+	u := &goUnit{dir: d, info: &types.Info{
+		Defs:   map[*ast.Ident]types.Object{},
+		Uses:   map[*ast.Ident]types.Object{},
+		Scopes: map[ast.Node]*types.Scope{},
+	}}
+	for _, p := range paths {
+		f, err := l.parse(p)
+		if err != nil {
+			return nil, err
+		}
+		u.files = append(u.files, f)
+	}
+	if len(u.files) == 0 {
+		return nil, errors.New("没有可检查的 Go 文件：" + d.rel)
+	}
+	conf := types.Config{
+		Importer:    l,
+		FakeImportC: true,
+		Error:       func(err error) { u.errs = append(u.errs, err) },
+	}
+	u.pkg, _ = conf.Check(importPath, l.fset, u.files, u.info)
+	return u, nil
+}
+
+func (l *goLoader) parse(p string) (*ast.File, error) {
+	if f := l.parsed[p]; f != nil {
+		return f, nil
+	}
+	f, err := parser.ParseFile(l.fset, p, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	l.parsed[p] = f
+	return f, nil
+}
+
+// allUnits 模块内全部检查单元：有同包测试时用“包 + 测试”单元代替非测试单元，另加外部测试包
+func (l *goLoader) allUnits(dirs []*goPkgDir) ([]*goUnit, error) {
+	var out []*goUnit
+	for _, d := range dirs {
+		if len(d.files) > 0 {
+			if _, err := l.unit(d.importPath); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case len(d.tests) > 0:
+			u, err := l.check(d, d.importPath, append(append([]string(nil), d.files...), d.tests...))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, u)
+		case len(d.files) > 0:
+			out = append(out, l.units[d.importPath])
+		}
+		if len(d.xtests) > 0 {
+			u, err := l.check(d, d.importPath+"_test", d.xtests)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+// posKey 对象声明位置（跨单元识别同一对象：同一文件的语法树共用，位置一致）
+func (l *goLoader) posKey(obj types.Object) string {
+	if obj == nil || !obj.Pos().IsValid() {
+		return ""
+	}
+	p := l.fset.Position(obj.Pos())
+	return fmt.Sprintf("%s:%d", p.Filename, p.Offset)
+}
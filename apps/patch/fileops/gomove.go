@@ -0,0 +1,346 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+)
+
+// go.move-package —— 把 Go 包目录（连同子包）移动到模块内的新位置，并改写模块内全部 import
+//   - to=：目标目录（相对仓库根，必须在同一模块内且尚不存在）
+//   - 目录名变化时同步修改包名（package 子句，含外部测试包 x_test）；name= 可显式指定新包名
+//   - 导入方未使用别名时，把 旧包名.X 改为 新包名.X；新包名与导入方包内（任一文件）的包级标识符
+//     或本文件中的标识符冲突时，改为保留旧名的别名导入（被构建约束排除的文件无类型信息，一律用别名）
+//   - 移动与改写涉及的每个文件先做路径安全校验（.xgit/protected 等），任一被拒绝则不做任何改动
+//   - 改写后对整个模块重新做类型检查；类型错误增多时移回原位、恢复文件并报错
+//   - 改动过的文件逐个 gofmt 预检；整个过程在同一事务内，失败整体回滚
+func GoMovePackage(repo, rel string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	fromRel := strings.Trim(path.Clean(filepath.ToSlash(strings.TrimSpace(rel))), "/")
+	toRel := strings.Trim(path.Clean(filepath.ToSlash(strings.TrimSpace(args["to"]))), "/")
+	if toRel == "" || toRel == "." {
+		return errors.New("go.move-package: 缺少目标目录 to=")
+	}
+	if toRel == fromRel || strings.HasPrefix(toRel+"/", fromRel+"/") {
+		return fmt.Errorf("go.move-package: 目标 %s 不能是源目录或其子目录", toRel)
+	}
+	from, to := filepath.Join(repo, filepath.FromSlash(fromRel)), filepath.Join(repo, filepath.FromSlash(toRel))
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		st := stateAbsent
+		if _, err := os.Stat(to); err == nil {
+			st = stateApplied
+		}
+		if skip, err := idemSkip("go.move-package", fromRel+" -> "+toRel, st, args, logger); err != nil || skip {
+			return err
+		}
+		return fmt.Errorf("go.move-package: 源目录不存在：%s", fromRel)
+	}
+	if _, err := os.Stat(to); err == nil {
+		return fmt.Errorf("go.move-package: 目标已存在：%s", toRel)
+	}
+
+	mod, pd, err := locateGoPackage(repo, fromRel, "")
+	if err != nil {
+		return fmt.Errorf("go.move-package: %w", err)
+	}
+	if r, err := filepath.Rel(mod.root, to); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("go.move-package: 目标 %s 不在模块 %s 内", toRel, mod.path)
+	}
+	if nested, err := findNestedModule(from); err != nil || nested != "" {
+		if err != nil {
+			return fmt.Errorf("go.move-package: %w", err)
+		}
+		return fmt.Errorf("go.move-package: %s 内含独立模块 %s，不能整体移动", fromRel, nested)
+	}
+	oldPath, newPath := pd.importPath, mod.importPathOf(to)
+
+	// 包名：目录名变化时随之改名（main 包与不是合法标识符的目录名除外）
+	oldName, err := packageName(pd)
+	if err != nil {
+		return fmt.Errorf("go.move-package: %w", err)
+	}
+	newName := oldName
+	if n := strings.TrimSpace(args["name"]); n != "" {
+		if !token.IsIdentifier(n) || n == "_" {
+			return fmt.Errorf("go.move-package: name=%q 不是合法的包名", n)
+		}
+		newName = n
+	} else if base := path.Base(toRel); oldName != "main" && path.Base(fromRel) != base && oldName == path.Base(fromRel) {
+		if token.IsIdentifier(base) {
+			newName = base
+		} else if logger != nil {
+			logger.Log("ℹ️ go.move-package: 目录名 %s 不是合法包名，保留包名 %s（可用 name= 指定）", base, oldName)
+		}
+	}
+
+	// 先在原位置算出全部改写，并对移动与改写涉及的每个文件做路径安全校验；全部通过后才移动、写入
+	dirs, err := mod.packages(repo)
+	if err != nil {
+		return fmt.Errorf("go.move-package: %w", err)
+	}
+	l := newGoLoader(mod, dirs)
+	units, err := l.allUnits(dirs)
+	if err != nil {
+		return fmt.Errorf("go.move-package: %w", err)
+	}
+	typed := map[string]*goFileTypes{} // 绝对路径 → 类型信息（被构建约束排除的文件没有）
+	for _, u := range units {
+		for _, f := range u.files {
+			if p := l.fset.Position(f.Pos()).Filename; typed[p] == nil {
+				typed[p] = &goFileTypes{l.fset, f, u.info, u.pkg}
+			}
+		}
+	}
+	dest := func(f string) string {
+		if r, err := filepath.Rel(from, f); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return filepath.Join(to, r)
+		}
+		return f
+	}
+	type rewrite struct {
+		file string // 移动后的绝对路径
+		src  []byte
+		orig []byte
+	}
+	var rewrites []rewrite
+	for _, d := range dirs {
+		for _, f := range append(append(append(append([]string(nil), d.files...), d.tests...), d.xtests...), d.ignored...) {
+			clause := d.importPath == oldPath && newName != oldName
+			orig, err := os.ReadFile(f)
+			if err != nil {
+				return fmt.Errorf("go.move-package: %w", err)
+			}
+			src, changed, err := rewriteGoFile(f, orig, oldPath, newPath, oldName, newName, clause, typed[f])
+			if err != nil {
+				return fmt.Errorf("go.move-package: %w", err)
+			}
+			if changed {
+				rewrites = append(rewrites, rewrite{dest(f), src, orig})
+			}
+		}
+	}
+	guard, err := NewPathGuard(repo)
+	if err != nil {
+		return fmt.Errorf("go.move-package: %w", err)
+	}
+	check := func(abs string) error {
+		r, _ := filepath.Rel(repo, abs)
+		if _, err := guard.Resolve(filepath.ToSlash(r)); err != nil {
+			return fmt.Errorf("go.move-package: %s 被拒绝：%v", filepath.ToSlash(r), err)
+		}
+		return nil
+	}
+	err = filepath.WalkDir(from, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if err := check(p); err != nil {
+			return err
+		}
+		return check(dest(p))
+	})
+	if err != nil {
+		return err
+	}
+	for _, rw := range rewrites {
+		if err := check(rw.file); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("go.move-package: 移动失败：%w", err)
+	}
+	if logger != nil {
+		logger.Log("📦 go.move-package: %s → %s（%s → %s）", fromRel, toRel, oldPath, newPath)
+	}
+	origs := map[string][]byte{}
+	undo := func() {
+		restoreFiles(origs)
+		_ = os.Rename(to, from)
+	}
+	var touched []string
+	for _, rw := range rewrites {
+		fi, err := os.Stat(rw.file)
+		if err == nil {
+			err = WriteFileAtomic(rw.file, rw.src, fi.Mode().Perm())
+		}
+		if err != nil {
+			undo()
+			return fmt.Errorf("go.move-package: %w", err)
+		}
+		origs[rw.file] = rw.orig
+		r, _ := filepath.Rel(repo, rw.file)
+		touched = append(touched, filepath.ToSlash(r))
+	}
+	sort.Strings(touched)
+
+	// 改写后重新类型检查（单元按移动后的目录对应）：出现新的类型错误说明改写不完整或引入了冲突
+	key := func(dir string, u *goUnit) string {
+		return dir + "|" + strconv.FormatBool(u.pkg.Path() == u.dir.importPath+"_test")
+	}
+	before := map[string]int{}
+	for _, u := range units {
+		before[key(dest(u.dir.dir), u)] = len(u.errs)
+	}
+	movedDirs, err := mod.packages(repo)
+	if err == nil {
+		var after []*goUnit
+		if after, err = newGoLoader(mod, movedDirs).allUnits(movedDirs); err == nil {
+			for _, u := range after {
+				if n := before[key(u.dir.dir, u)]; len(u.errs) > n {
+					undo()
+					return fmt.Errorf("go.move-package: 改写后 %s 的类型错误由 %d 个增至 %d 个，已恢复：%v", u.pkg.Path(), n, len(u.errs), u.errs[n])
+				}
+			}
+		}
+	}
+	if err != nil {
+		undo()
+		return fmt.Errorf("go.move-package: 改写后重新检查失败，已恢复：%w", err)
+	}
+	if logger != nil {
+		logger.Log("✏️ go.move-package: 改写 %d 个文件%s", len(touched), renameNote(oldName, newName))
+	}
+	if git != nil {
+		if err := git.Add(repo, fromRel, toRel); err != nil {
+			return fmt.Errorf("go.move-package: %w", err)
+		}
+	}
+	for _, r := range touched {
+		if err := stageAndPreflight(repo, r, git, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renameNote(oldName, newName string) string {
+	if oldName == newName {
+		return ""
+	}
+	return fmt.Sprintf("（包名 %s → %s）", oldName, newName)
+}
+
+// packageName 包目录的包名（取第一个非外部测试文件的 package 子句）
+func packageName(pd *goPkgDir) (string, error) {
+	for _, f := range append(append(append([]string(nil), pd.files...), pd.tests...), pd.ignored...) {
+		af, err := parser.ParseFile(token.NewFileSet(), f, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasSuffix(af.Name.Name, "_test") {
+			return af.Name.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%s 中没有确定包名的文件", pd.rel)
+}
+
+// findNestedModule 目录内（不含自身）是否有 go.mod
+func findNestedModule(dir string) (string, error) {
+	found := ""
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || found != "" {
+			return err
+		}
+		if !d.IsDir() && d.Name() == "go.mod" {
+			found = p
+		}
+		return nil
+	})
+	return found, err
+}
+
+// goFileTypes 文件的语法树与所在检查单元的类型信息
+type goFileTypes struct {
+	fset *token.FileSet
+	file *ast.File
+	info *types.Info
+	pkg  *types.Package
+}
+
+// rewriteGoFile 计算一个文件改写后的内容（不写入）：import 路径（含子包）、导入方的包名限定符、（可选）package 子句；
+// tc 为 nil（文件被构建约束排除）时不改限定符，包名变化时改为保留旧名的别名导入
+func rewriteGoFile(file string, src []byte, oldPath, newPath, oldName, newName string, clause bool, tc *goFileTypes) ([]byte, bool, error) {
+	var fset *token.FileSet
+	var f *ast.File
+	if tc != nil {
+		fset, f = tc.fset, tc.file
+	} else {
+		fset = token.NewFileSet()
+		var err error
+		if f, err = parser.ParseFile(fset, file, src, parser.ParseComments); err != nil {
+			return nil, false, err
+		}
+	}
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+	type edit struct {
+		from, to int
+		text     string
+	}
+	var edits []edit
+
+	if clause {
+		name := newName
+		if strings.HasSuffix(f.Name.Name, "_test") {
+			name += "_test"
+		}
+		edits = append(edits, edit{off(f.Name.Pos()), off(f.Name.End()), name})
+	}
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || (p != oldPath && !strings.HasPrefix(p, oldPath+"/")) {
+			continue
+		}
+		np := newPath + strings.TrimPrefix(p, oldPath)
+		lit := strconv.Quote(np)
+		if p == oldPath && imp.Name == nil && newName != oldName {
+			if tc == nil || tc.pkg.Scope().Lookup(newName) != nil || mentionsIdent([]*ast.File{f}, newName) {
+				lit = oldName + " " + lit // 新包名与包内 / 文件内标识符冲突：保留旧名作别名
+			} else {
+				for _, id := range packageRefs(f, tc.info, oldPath) {
+					edits = append(edits, edit{off(id.Pos()), off(id.End()), newName})
+				}
+			}
+		}
+		edits = append(edits, edit{off(imp.Path.Pos()), off(imp.Path.End()), lit})
+	}
+	if len(edits) == 0 {
+		return nil, false, nil
+	}
+	out := append([]byte(nil), src...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].from > edits[j].from })
+	for _, e := range edits {
+		out = append(out[:e.from], append([]byte(e.text), out[e.to:]...)...)
+	}
+	return out, true, nil
+}
+
+// packageRefs 文件中解析到 importPath 这一导入的包名限定符（name.X 中的 name）
+func packageRefs(f *ast.File, info *types.Info, importPath string) []*ast.Ident {
+	var out []*ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if pn, ok := info.Uses[id].(*types.PkgName); ok && pn.Imported().Path() == importPath {
+					out = append(out, id)
+				}
+			}
+		}
+		return true
+	})
+	return out
+}
@@ -0,0 +1,83 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoMovePackageRewritesImporters(t *testing.T) {
+	repo := writeTestTree(t, map[string]string{
+		"go.mod":    renameGoMod,
+		"old/o.go":  "package old\n\nfunc F() {}\n",
+		"main/m.go": "package main\n\nimport \"ex/old\"\n\nfunc main() { old.F() }\n",
+	})
+	if err := GoMovePackage(repo, "old", map[string]string{"to": "pkg/fresh"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, "main/m.go"); !strings.Contains(got, "\"ex/pkg/fresh\"") || !strings.Contains(got, "fresh.F()") {
+		t.Fatalf("main/m.go = %q", got)
+	}
+	if got := readTestFile(t, repo, "pkg/fresh/o.go"); !strings.HasPrefix(got, "package fresh\n") {
+		t.Fatalf("pkg/fresh/o.go = %q", got)
+	}
+}
+
+// 需要改写的导入方是受保护路径：整体拒绝，不移动也不改写
+func TestGoMovePackageRespectsProtectedImporters(t *testing.T) {
+	m := "package main\n\nimport \"ex/old\"\n\nfunc main() { old.F() }\n"
+	repo := writeTestTree(t, map[string]string{
+		"go.mod":          renameGoMod,
+		".xgit/protected": "main/**\n",
+		"old/o.go":        "package old\n\nfunc F() {}\n",
+		"main/m.go":       m,
+	})
+	err := GoMovePackage(repo, "old", map[string]string{"to": "fresh"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "main/m.go") {
+		t.Fatalf("改写受保护文件应被拒绝：%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "old/o.go")); err != nil {
+		t.Fatal("拒绝时不应移动源目录")
+	}
+	if readTestFile(t, repo, "main/m.go") != m {
+		t.Fatal("拒绝时不应改写导入方")
+	}
+}
+
+// 新包名与导入方包内其它文件的包级标识符同名：改为保留旧名的别名导入
+func TestGoMovePackageAliasesOnPackageScopeConflict(t *testing.T) {
+	repo := writeTestTree(t, map[string]string{
+		"go.mod":    renameGoMod,
+		"old/o.go":  "package old\n\nfunc F() int { return 1 }\n",
+		"main/m.go": "package main\n\nimport \"ex/old\"\n\nfunc main() { _ = old.F() + fresh }\n",
+		"main/v.go": "package main\n\nvar fresh = 1\n",
+	})
+	if err := GoMovePackage(repo, "old", map[string]string{"to": "fresh"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, "main/m.go"); !strings.Contains(got, "old \"ex/fresh\"") || !strings.Contains(got, "old.F()") {
+		t.Fatalf("main/m.go = %q", got)
+	}
+}
+
+// 改写后出现新的类型错误（点导入已引入同名标识符）：移回原位并恢复导入方
+func TestGoMovePackageRestoresOnNewTypeErrors(t *testing.T) {
+	m := "package main\n\nimport (\n\t. \"ex/other\"\n\t\"ex/old\"\n)\n\nfunc main() { old.F(); G() }\n"
+	repo := writeTestTree(t, map[string]string{
+		"go.mod":         renameGoMod,
+		"old/o.go":       "package old\n\nfunc F() {}\n",
+		"other/other.go": "package other\n\nfunc G() {}\n\nfunc Fresh() {}\n",
+		"main/m.go":      m,
+	})
+	err := GoMovePackage(repo, "old", map[string]string{"to": "fresh", "name": "Fresh"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "已恢复") {
+		t.Fatalf("新增类型错误应报错并恢复：%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "old/o.go")); err != nil {
+		t.Fatal("源目录未移回")
+	}
+	if readTestFile(t, repo, "main/m.go") != m {
+		t.Fatal("导入方未恢复")
+	}
+}
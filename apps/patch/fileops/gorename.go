@@ -0,0 +1,490 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+)
+
+// go.rename —— 按类型信息重命名 Go 标识符：改写声明与模块内全部引用，随后逐文件 gofmt
+//   - 头部路径为包目录；也可用 pkg= 给出模块内的导入路径
+//   - old=Name（包级函数 / 类型 / 变量 / 常量）或 old=Type.Member（方法或字段），new=新名字
+//   - 新名字与已有声明、引用处可见的同名标识符或内置标识符冲突时拒绝
+//   - 目标包或引用到该名字的包存在类型错误时拒绝（引用可能解析不全）；allow-errors=true 可强制执行
+//   - 方法改名牵涉接口满足关系（目标是接口方法，或接收者实现了含同名方法的接口）时拒绝
+//   - 改写的文件逐个经过路径安全校验（.xgit/protected 等）；改写后重新类型检查，出现新的类型错误则恢复原文件并报错
+func GoRename(repo, rel string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	oldName, newName := strings.TrimSpace(args["old"]), strings.TrimSpace(args["new"])
+	if oldName == "" || newName == "" {
+		return errors.New("go.rename: 需要 old= 与 new=")
+	}
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return fmt.Errorf("go.rename: new=%q 不是合法的 Go 标识符", newName)
+	}
+	typeName, member, isMember := strings.Cut(oldName, ".")
+	leaf := typeName
+	if isMember {
+		leaf = member
+	}
+	if leaf == newName {
+		return fmt.Errorf("go.rename: 新旧名字相同：%s", newName)
+	}
+
+	mod, pd, err := locateGoPackage(repo, rel, args["pkg"])
+	if err != nil {
+		return fmt.Errorf("go.rename: %w", err)
+	}
+	dirs, err := mod.packages(repo)
+	if err != nil {
+		return fmt.Errorf("go.rename: %w", err)
+	}
+	l := newGoLoader(mod, dirs)
+	units, err := l.allUnits(dirs)
+	if err != nil {
+		return fmt.Errorf("go.rename: %w", err)
+	}
+	var tu *goUnit
+	for _, u := range units {
+		if u.dir.importPath == pd.importPath && u.pkg.Path() == pd.importPath {
+			tu = u
+		}
+	}
+	if tu == nil {
+		return fmt.Errorf("go.rename: %s 没有可构建的 Go 文件", pd.rel)
+	}
+	pkg := tu.pkg
+	where := func(p token.Pos) string {
+		pos := l.fset.Position(p)
+		r, _ := filepath.Rel(repo, pos.Filename)
+		return fmt.Sprintf("%s:%d:%d", filepath.ToSlash(r), pos.Line, pos.Column)
+	}
+
+	// 定位目标对象
+	var obj types.Object
+	if isMember {
+		tn, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+		if !ok {
+			return fmt.Errorf("go.rename: %s 中没有类型 %s", pd.importPath, typeName)
+		}
+		if o, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg, member); o != nil && o.Pkg() == pkg {
+			obj = o
+		}
+	} else {
+		obj = pkg.Scope().Lookup(typeName)
+	}
+	if obj == nil {
+		st := stateAbsent
+		if isMember && lookupMember(pkg, typeName, newName) != nil || !isMember && pkg.Scope().Lookup(newName) != nil {
+			st = stateApplied
+		}
+		if skip, err := idemSkip("go.rename", pd.importPath+"."+oldName, st, args, logger); err != nil || skip {
+			return err
+		}
+		return fmt.Errorf("go.rename: %s 中没有 %s", pd.importPath, oldName)
+	}
+
+	// 冲突检查：接口满足关系（只改方法本身会让实现方 / 调用方悄悄失配）
+	if fn, ok := obj.(*types.Func); ok {
+		if tie := interfaceTie(units, fn); tie != "" {
+			return fmt.Errorf("go.rename: %s %s，暂不支持联动改名", oldName, tie)
+		}
+	}
+
+	// 冲突检查：声明层面
+	if isMember {
+		if o := lookupMember(pkg, typeName, newName); o != nil {
+			return fmt.Errorf("go.rename: %s 已有成员 %s（%s）", typeName, newName, where(o.Pos()))
+		}
+		// 嵌入了该类型的外层类型：提升后的新名字会被外层同名成员遮蔽，或与之产生歧义
+		if outer, o := promotedClash(units, obj, newName); outer != nil {
+			if o != nil {
+				return fmt.Errorf("go.rename: %s 嵌入了 %s，其成员 %s（%s）会遮蔽改名后的 %s", outer.Name(), typeName, newName, where(o.Pos()), newName)
+			}
+			return fmt.Errorf("go.rename: %s 嵌入了 %s，改名后 %s.%s 会产生歧义", outer.Name(), typeName, outer.Name(), newName)
+		}
+	} else {
+		if o := pkg.Scope().Lookup(newName); o != nil {
+			return fmt.Errorf("go.rename: 包 %s 中已存在 %s（%s）", pkg.Name(), newName, where(o.Pos()))
+		}
+		if types.Universe.Lookup(newName) != nil {
+			return fmt.Errorf("go.rename: %s 是内置标识符，改名后会遮蔽它", newName)
+		}
+	}
+
+	// 目标对象 + 以该类型为嵌入字段的字段（s.T 形式的引用随类型一起改名）
+	keys := map[string]bool{l.posKey(obj): true}
+	if _, ok := obj.(*types.TypeName); ok {
+		for _, u := range units {
+			for id, d := range u.info.Defs {
+				if v, ok := d.(*types.Var); ok && v.Embedded() && keys[l.posKey(u.info.Uses[id])] {
+					keys[l.posKey(v)] = true
+				}
+			}
+		}
+	}
+
+	type hit struct {
+		file string
+		off  int
+	}
+	seen := map[hit]bool{}
+	var hits []hit
+	outside := 0
+	for _, u := range units {
+		sels := selectorIdents(u.files)
+		for _, m := range []map[*ast.Ident]types.Object{u.info.Defs, u.info.Uses} {
+			for id, o := range m {
+				if o == nil || !keys[l.posKey(o)] || id.Name != leaf {
+					continue
+				}
+				pos := l.fset.Position(id.Pos())
+				h := hit{pos.Filename, pos.Offset}
+				if seen[h] {
+					continue
+				}
+				// 冲突检查：非限定引用处若能看到同名的其它对象，改名后会被遮蔽
+				if !isMember && !sels[id] && u.pkg.Path() == pd.importPath {
+					if s := u.pkg.Scope().Innermost(id.Pos()); s != nil {
+						if _, other := s.LookupParent(newName, id.Pos()); other != nil && other.Parent() != types.Universe {
+							return fmt.Errorf("go.rename: %s 处的引用会被 %s（%s）遮蔽", where(id.Pos()), newName, where(other.Pos()))
+						}
+					}
+				}
+				if u.dir.importPath != pd.importPath {
+					outside++
+				}
+				seen[h] = true
+				hits = append(hits, h)
+			}
+		}
+	}
+	if ast.IsExported(leaf) && !ast.IsExported(newName) && outside > 0 {
+		return fmt.Errorf("go.rename: %s 在包外有 %d 处引用，不能改为未导出的 %s", oldName, outside, newName)
+	}
+
+	// 类型错误：引用可能解析不全
+	if !argOn(args, "allow-errors") {
+		for _, u := range units {
+			if len(u.errs) > 0 && (u == tu || mentionsIdent(u.files, leaf)) {
+				return fmt.Errorf("go.rename: %s 类型检查失败（%d 个错误，引用可能解析不全）：%v；可加 allow-errors=true 强制执行", u.pkg.Path(), len(u.errs), u.errs[0])
+			}
+		}
+	}
+
+	// 改写
+	byFile := map[string][]int{}
+	for _, h := range hits {
+		byFile[h.file] = append(byFile[h.file], h.off)
+	}
+	files := make([]string, 0, len(byFile))
+	for f := range byFile {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	guard, err := NewPathGuard(repo)
+	if err != nil {
+		return fmt.Errorf("go.rename: %w", err)
+	}
+	for _, f := range files {
+		r, _ := filepath.Rel(repo, f)
+		if _, err := guard.Resolve(filepath.ToSlash(r)); err != nil {
+			return fmt.Errorf("go.rename: %s 需要改写但被拒绝：%v", filepath.ToSlash(r), err)
+		}
+	}
+	before := map[string]int{}
+	for _, u := range units {
+		before[unitKey(u)] = len(u.errs)
+	}
+	origs := map[string][]byte{}
+	for _, f := range files {
+		orig, err := renameAt(f, byFile[f], leaf, newName)
+		if err != nil {
+			restoreFiles(origs)
+			return fmt.Errorf("go.rename: %w", err)
+		}
+		origs[f] = orig
+	}
+
+	// 改写后重新类型检查：出现新的类型错误说明改名不完整或引入了冲突
+	after, err := newGoLoader(mod, dirs).allUnits(dirs)
+	if err != nil {
+		restoreFiles(origs)
+		return fmt.Errorf("go.rename: 改写后重新检查失败，已恢复：%w", err)
+	}
+	for _, u := range after {
+		if n := before[unitKey(u)]; len(u.errs) > n {
+			restoreFiles(origs)
+			return fmt.Errorf("go.rename: 改写后 %s 的类型错误由 %d 个增至 %d 个，已恢复：%v", u.pkg.Path(), n, len(u.errs), u.errs[n])
+		}
+	}
+	if logger != nil {
+		logger.Log("🏷️ go.rename: %s.%s → %s：%d 处 / %d 个文件", pd.importPath, oldName, newName, len(hits), len(files))
+	}
+	warnIgnoredMentions(repo, dirs, leaf, logger)
+	for _, f := range files {
+		r, _ := filepath.Rel(repo, f)
+		if err := stageAndPreflight(repo, filepath.ToSlash(r), git, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locateGoPackage 由头部目录或 pkg= 导入路径确定模块与包目录
+func locateGoPackage(repo, rel, importPath string) (*goModule, *goPkgDir, error) {
+	importPath = strings.Trim(strings.TrimSpace(importPath), "\"")
+	rel = strings.Trim(filepath.ToSlash(strings.TrimSpace(rel)), "/")
+	if importPath != "" && rel == "" {
+		mod, err := findModuleFor(repo, importPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, _ := filepath.Rel(repo, mod.dirOf(importPath))
+		rel = filepath.ToSlash(r)
+	}
+	if rel == "" {
+		return nil, nil, errors.New("需要包目录（头部路径）或 pkg=")
+	}
+	mod, err := findGoModule(repo, rel)
+	if err != nil {
+		return nil, nil, err
+	}
+	pd, err := mod.scanDir(repo, filepath.Join(repo, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if pd == nil {
+		return nil, nil, fmt.Errorf("%s 不是 Go 包目录", rel)
+	}
+	if importPath != "" && pd.importPath != importPath {
+		return nil, nil, fmt.Errorf("pkg=%s 与目录 %s（%s）不一致", importPath, rel, pd.importPath)
+	}
+	return mod, pd, nil
+}
+
+// findModuleFor 在仓库内查找包含该导入路径的模块（module 路径最长前缀）
+func findModuleFor(repo, importPath string) (*goModule, error) {
+	var best *goModule
+	err := filepath.WalkDir(repo, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != repo && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || d.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Name() != "go.mod" {
+			return nil
+		}
+		mp, err := readModulePath(p)
+		if err != nil {
+			return nil
+		}
+		if (importPath == mp || strings.HasPrefix(importPath, mp+"/")) && (best == nil || len(mp) > len(best.path)) {
+			rel, _ := filepath.Rel(repo, filepath.Dir(p))
+			best = &goModule{root: filepath.Dir(p), rel: filepath.ToSlash(rel), path: mp}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if best == nil {
+		return nil, fmt.Errorf("仓库内没有包含 %s 的 Go 模块", importPath)
+	}
+	return best, nil
+}
+
+func lookupMember(pkg *types.Package, typeName, name string) types.Object {
+	tn, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	o, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg, name)
+	return o
+}
+
+// promotedClash 找出经嵌入提升到 obj 的外层命名类型中，newName 解析到其它对象（o）
+// 或有歧义（o 为 nil）且深度不超过 obj 的那一个；无冲突返回 (nil, nil)
+func promotedClash(units []*goUnit, obj types.Object, newName string) (*types.TypeName, types.Object) {
+	for _, u := range units {
+		for _, d := range u.info.Defs {
+			tn, ok := d.(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			o, idx, _ := types.LookupFieldOrMethod(tn.Type(), true, obj.Pkg(), obj.Name())
+			if o != obj || len(idx) < 2 {
+				continue // 未经嵌入到达 obj（声明类型本身已单独检查）
+			}
+			other, oidx, _ := types.LookupFieldOrMethod(tn.Type(), true, obj.Pkg(), newName)
+			if oidx != nil && other != obj && len(oidx) <= len(idx) {
+				return tn, other
+			}
+		}
+	}
+	return nil, nil
+}
+
+// selectorIdents x.Sel 中的 Sel（限定引用，不受局部作用域影响）
+func selectorIdents(files []*ast.File) map[*ast.Ident]bool {
+	out := map[*ast.Ident]bool{}
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if s, ok := n.(*ast.SelectorExpr); ok {
+				out[s.Sel] = true
+			}
+			return true
+		})
+	}
+	return out
+}
+
+func mentionsIdent(files []*ast.File, name string) bool {
+	found := false
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// renameAt 把文件中 offs 处长度为 len(old) 的标识符替换为 new（保留权限），返回改写前的内容
+func renameAt(file string, offs []int, old, new string) ([]byte, error) {
+	orig, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	data := append([]byte(nil), orig...)
+	sort.Sort(sort.Reverse(sort.IntSlice(offs)))
+	for _, off := range offs {
+		if off+len(old) > len(data) || string(data[off:off+len(old)]) != old {
+			return nil, fmt.Errorf("%s 偏移 %d 处不是 %s（文件已变化？）", file, off, old)
+		}
+		data = append(data[:off], append([]byte(new), data[off+len(old):]...)...)
+	}
+	return orig, WriteFileAtomic(file, data, fi.Mode().Perm())
+}
+
+// restoreFiles 把已改写的文件恢复为原内容（尽力而为，失败时由事务回滚兜底）
+func restoreFiles(origs map[string][]byte) {
+	for f, data := range origs {
+		if fi, err := os.Stat(f); err == nil {
+			_ = WriteFileAtomic(f, data, fi.Mode().Perm())
+		}
+	}
+}
+
+// unitKey 检查单元的标识（包目录 + 包路径，区分同包测试与外部测试包）
+func unitKey(u *goUnit) string {
+	return u.dir.rel + "|" + u.pkg.Path()
+}
+
+// interfaceTie 方法改名牵涉接口时返回说明：目标是接口方法，或接收者（T / *T）实现了含同名方法的接口
+// （模块内声明的接口、被导入包的包级接口与 error）；普通函数与字段返回 ""
+func interfaceTie(units []*goUnit, fn *types.Func) string {
+	sig, _ := fn.Type().(*types.Signature)
+	if sig == nil || sig.Recv() == nil {
+		return ""
+	}
+	rt := sig.Recv().Type()
+	if p, ok := rt.(*types.Pointer); ok {
+		rt = p.Elem()
+	}
+	if types.IsInterface(rt) {
+		return "是接口方法，改名需同步改写全部实现"
+	}
+	named, ok := rt.(*types.Named)
+	if !ok {
+		return ""
+	}
+
+	var cands []*types.TypeName
+	visited := map[*types.Package]bool{}
+	var visit func(p *types.Package)
+	visit = func(p *types.Package) {
+		if p == nil || visited[p] {
+			return
+		}
+		visited[p] = true
+		for _, n := range p.Scope().Names() {
+			if tn, ok := p.Scope().Lookup(n).(*types.TypeName); ok {
+				cands = append(cands, tn)
+			}
+		}
+		for _, ip := range p.Imports() {
+			visit(ip)
+		}
+	}
+	for _, u := range units {
+		for _, o := range u.info.Defs {
+			if tn, ok := o.(*types.TypeName); ok {
+				cands = append(cands, tn) // 含函数内声明的局部接口
+			}
+		}
+		visit(u.pkg)
+	}
+	cands = append(cands, types.Universe.Lookup("error").(*types.TypeName))
+
+	var ties []string
+	seen := map[*types.TypeName]bool{}
+	for _, tn := range cands {
+		it, ok := tn.Type().Underlying().(*types.Interface)
+		if !ok || seen[tn] {
+			continue
+		}
+		seen[tn] = true
+		if m, _, _ := types.LookupFieldOrMethod(it, false, fn.Pkg(), fn.Name()); m == nil {
+			continue
+		}
+		name := tn.Name()
+		if tn.Pkg() != nil {
+			name = tn.Pkg().Path() + "." + name
+		}
+		// 泛型类型无法可靠判定满足关系，按同名方法保守拒绝
+		generic := named.TypeParams().Len() > 0
+		if n, ok := tn.Type().(*types.Named); ok && n.TypeParams().Len() > 0 {
+			generic = true
+		}
+		if generic || types.Implements(named, it) || types.Implements(types.NewPointer(named), it) {
+			ties = append(ties, name)
+		}
+	}
+	if len(ties) == 0 {
+		return ""
+	}
+	sort.Strings(ties)
+	return "的接收者实现了接口 " + strings.Join(ties, ", ")
+}
+
+// warnIgnoredMentions 被构建约束排除的文件无法做类型解析：其中出现同名标识符时提示人工确认
+func warnIgnoredMentions(repo string, dirs []*goPkgDir, name string, logger DualLogger) {
+	if logger == nil {
+		return
+	}
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+	for _, d := range dirs {
+		for _, f := range d.ignored {
+			if b, err := os.ReadFile(f); err == nil && re.Match(b) {
+				r, _ := filepath.Rel(repo, f)
+				logger.Log("⚠️ go.rename: %s 被构建约束排除，未做改名，其中出现 %s，请人工确认", filepath.ToSlash(r), name)
+			}
+		}
+	}
+}
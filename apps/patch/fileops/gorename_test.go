@@ -0,0 +1,109 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for rel, body := range files {
+		abs := filepath.Join(repo, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+const renameGoMod = "module ex\n\ngo 1.22\n"
+
+func TestGoRenameAcrossPackages(t *testing.T) {
+	repo := writeTestTree(t, map[string]string{
+		"go.mod": renameGoMod,
+		"a/a.go": "package a\n\nfunc Old() int { return 1 }\n",
+		"b/b.go": "package b\n\nimport \"ex/a\"\n\nvar X = a.Old()\n",
+	})
+	if err := GoRename(repo, "a", map[string]string{"old": "Old", "new": "New"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, "b/b.go"); !strings.Contains(got, "a.New()") {
+		t.Fatalf("b/b.go = %q", got)
+	}
+}
+
+// 接收者实现了接口：只改方法会让类型不再满足接口
+func TestGoRenameRefusesInterfaceTies(t *testing.T) {
+	src := "package a\n\ntype Namer interface{ Name() string }\n\ntype T struct{}\n\nfunc (T) Name() string { return \"t\" }\n\ntype E struct{}\n\nfunc (*E) Error() string { return \"e\" }\n"
+	repo := writeTestTree(t, map[string]string{"go.mod": renameGoMod, "a/a.go": src})
+	for _, c := range []struct{ old, want string }{
+		{"T.Name", "Namer"},
+		{"Namer.Name", "接口方法"},
+		{"E.Error", "error"},
+	} {
+		err := GoRename(repo, "a", map[string]string{"old": c.old, "new": "Title"}, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s：err = %v，期望提及 %s", c.old, err, c.want)
+		}
+	}
+	if got := readTestFile(t, repo, "a/a.go"); got != src {
+		t.Fatalf("拒绝时不应修改文件：%q", got)
+	}
+}
+
+// 外层类型嵌入了目标类型：提升后的新名字会被外层成员遮蔽或产生歧义
+func TestGoRenameRefusesPromotedClashes(t *testing.T) {
+	for name, b := range map[string]string{
+		"遮蔽": "package b\n\nimport \"ex/a\"\n\ntype U struct {\n\ta.T\n\tG string\n}\n\nfunc f(u U) int { return u.F }\n",
+		"歧义": "package b\n\nimport \"ex/a\"\n\ntype W struct{ G int }\n\ntype U struct {\n\ta.T\n\tW\n}\n\nfunc f(u U) int { return u.F }\n",
+	} {
+		repo := writeTestTree(t, map[string]string{
+			"go.mod": renameGoMod,
+			"a/a.go": "package a\n\ntype T struct{ F int }\n",
+			"b/b.go": b,
+		})
+		err := GoRename(repo, "a", map[string]string{"old": "T.F", "new": "G"}, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "U 嵌入了 T") {
+			t.Errorf("%s：err = %v", name, err)
+		}
+		if got := readTestFile(t, repo, "b/b.go"); got != b {
+			t.Errorf("%s：拒绝时不应修改文件：%q", name, got)
+		}
+	}
+}
+
+func TestGoRenameRespectsProtectedPaths(t *testing.T) {
+	repo := writeTestTree(t, map[string]string{
+		"go.mod":          renameGoMod,
+		".xgit/protected": "vendored/**\n",
+		"a/a.go":          "package a\n\nfunc Old() int { return 1 }\n",
+		"vendored/b.go":   "package vendored\n\nimport \"ex/a\"\n\nvar X = a.Old()\n",
+	})
+	err := GoRename(repo, "a", map[string]string{"old": "Old", "new": "New"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "vendored/b.go") {
+		t.Fatalf("改写受保护文件应被拒绝：%v", err)
+	}
+	if got := readTestFile(t, repo, "a/a.go"); strings.Contains(got, "New") {
+		t.Fatalf("拒绝时不应改写任何文件：%q", got)
+	}
+}
+
+// 点导入方已声明同名标识符：声明层面的检查看不到，改写后出现新的类型错误，需恢复原文件
+func TestGoRenameRestoresOnNewTypeErrors(t *testing.T) {
+	a := "package a\n\nfunc Old() int { return 1 }\n"
+	b := "package b\n\nimport . \"ex/a\"\n\nfunc New() int { return Old() }\n"
+	repo := writeTestTree(t, map[string]string{"go.mod": renameGoMod, "a/a.go": a, "b/b.go": b})
+	err := GoRename(repo, "a", map[string]string{"old": "Old", "new": "New"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "已恢复") {
+		t.Fatalf("新增类型错误应报错并恢复：%v", err)
+	}
+	if readTestFile(t, repo, "a/a.go") != a || readTestFile(t, repo, "b/b.go") != b {
+		t.Fatal("文件未恢复")
+	}
+}
@@ -0,0 +1,190 @@
+package fileops
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+//
+// Go 语义作用域：scope=<kind>[:<name>]（仅 .go 文件）
+//   - func:ApplyOnce                 顶层函数（无接收者）
+//   - method:(*Watcher).StableAndEOF 方法；(*T).M 要求指针接收者，(T).M 要求值接收者，T.M 两者皆可
+//   - type:TxnOpts                   类型声明
+//   - const / var / import           整个声明组（多组时用 nthb 选择）
+//   - const:Name / var:Name          包含该名字的声明（分组声明中只取该条）
+// 范围包含 doc 注释，经 go/parser 精确解析为行区间。
+//
+
+func resolveGoScope(rel string, lines []string, sel string, nth int) (scope, error) {
+	if !strings.HasSuffix(strings.ToLower(rel), ".go") {
+		return scope{}, fmt.Errorf("scope=%s 仅适用于 .go 文件", sel)
+	}
+	kind, name, _ := strings.Cut(strings.TrimSpace(sel), ":")
+	kind, name = strings.ToLower(strings.TrimSpace(kind)), strings.TrimSpace(name)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, rel, strings.Join(lines, ""), parser.ParseComments)
+	if err != nil {
+		return scope{}, fmt.Errorf("scope=%s：Go 解析失败：%v", sel, err)
+	}
+	span := func(doc *ast.CommentGroup, n ast.Node) scope {
+		start := n.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return scope{start: fset.Position(start).Line, end: fset.Position(n.End()).Line}
+	}
+
+	var hits []scope
+	switch kind {
+	case "func", "method":
+		var recvName string
+		var wantPtr *bool
+		fname := name
+		if kind == "method" {
+			r, m, ok := splitMethodSel(name)
+			if !ok {
+				return scope{}, fmt.Errorf("scope=%s：方法写法应为 (*T).M / (T).M / T.M", sel)
+			}
+			recvName, fname = r.name, m
+			wantPtr = r.ptr
+		}
+		if fname == "" {
+			return scope{}, fmt.Errorf("scope=%s 缺少名字", sel)
+		}
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Name.Name != fname {
+				continue
+			}
+			if kind == "func" {
+				if fd.Recv != nil {
+					continue
+				}
+			} else {
+				if fd.Recv == nil || len(fd.Recv.List) == 0 {
+					continue
+				}
+				rn, ptr := recvType(fd.Recv.List[0].Type)
+				if rn != recvName || (wantPtr != nil && *wantPtr != ptr) {
+					continue
+				}
+			}
+			hits = append(hits, span(fd.Doc, fd))
+		}
+
+	case "type", "const", "var", "import":
+		tok := map[string]token.Token{"type": token.TYPE, "const": token.CONST, "var": token.VAR, "import": token.IMPORT}[kind]
+		if kind == "type" && name == "" {
+			return scope{}, fmt.Errorf("scope=%s 缺少类型名", sel)
+		}
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != tok {
+				continue
+			}
+			if name == "" {
+				hits = append(hits, span(gd.Doc, gd))
+				continue
+			}
+			for _, sp := range gd.Specs {
+				if !specHasName(sp, name) {
+					continue
+				}
+				if len(gd.Specs) == 1 {
+					hits = append(hits, span(gd.Doc, gd))
+				} else {
+					hits = append(hits, span(specDoc(sp), sp))
+				}
+			}
+		}
+
+	default:
+		return scope{}, fmt.Errorf("未知 scope=%s（支持 func:/method:/type:/const/var/import）", sel)
+	}
+
+	switch {
+	case len(hits) == 0:
+		return scope{}, fmt.Errorf("scope=%s 未找到", sel)
+	case len(hits) == 1:
+		return hits[0], nil
+	case nth > 0 && nth <= len(hits):
+		return hits[nth-1], nil
+	}
+	var at []string
+	for _, h := range hits {
+		at = append(at, fmt.Sprintf("L%d-L%d", h.start, h.end))
+	}
+	return scope{}, fmt.Errorf("scope=%s 多处命中 %s（可用 nthb=1..%d 选择）", sel, strings.Join(at, " "), len(hits))
+}
+
+type recvSel struct {
+	name string
+	ptr  *bool // nil 表示不限
+}
+
+// splitMethodSel 解析 "(*T).M" / "(T).M" / "T.M"
+func splitMethodSel(s string) (recvSel, string, bool) {
+	i := strings.LastIndex(s, ".")
+	if i <= 0 || i == len(s)-1 {
+		return recvSel{}, "", false
+	}
+	r, m := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	var sel recvSel
+	if strings.HasPrefix(r, "(") && strings.HasSuffix(r, ")") {
+		r = strings.TrimSpace(r[1 : len(r)-1])
+		ptr := strings.HasPrefix(r, "*")
+		sel.ptr = &ptr
+	}
+	sel.name = strings.TrimSpace(strings.TrimPrefix(r, "*"))
+	return sel, m, sel.name != ""
+}
+
+// recvType 接收者类型名与是否指针（泛型接收者 T[K] 取 T）
+func recvType(e ast.Expr) (string, bool) {
+	ptr := false
+	if st, ok := e.(*ast.StarExpr); ok {
+		ptr, e = true, st.X
+	}
+	switch t := e.(type) {
+	case *ast.IndexExpr:
+		e = t.X
+	case *ast.IndexListExpr:
+		e = t.X
+	}
+	if id, ok := e.(*ast.Ident); ok {
+		return id.Name, ptr
+	}
+	return "", ptr
+}
+
+func specHasName(sp ast.Spec, name string) bool {
+	switch s := sp.(type) {
+	case *ast.TypeSpec:
+		return s.Name.Name == name
+	case *ast.ValueSpec:
+		for _, n := range s.Names {
+			if n.Name == name {
+				return true
+			}
+		}
+	case *ast.ImportSpec:
+		return strings.Trim(s.Path.Value, "\"`") == name || (s.Name != nil && s.Name.Name == name)
+	}
+	return false
+}
+
+func specDoc(sp ast.Spec) *ast.CommentGroup {
+	switch s := sp.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	case *ast.ImportSpec:
+		return s.Doc
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+	"xgit/apps/patch/preflight"
+)
+
+// html.replace / html.insert / html.remove / html.set-attr —— 按 CSS 选择器定位元素并修改（.xml/.svg/.xhtml 按 XML 规则）
+//   - selector=：CSS 选择器（如 #login-form > button.primary）；默认必须恰好匹配一个元素，all=true 时作用于全部匹配
+//   - html.replace：正文替换整个元素；inner=true 只替换元素内容
+//   - html.insert：position=before|after|prepend|append（默认 append）插入正文
+//   - html.remove：删除元素（独占整行时连同所在行一起删除）
+//   - html.set-attr：name= 属性名，value= 属性值（省略时写成布尔属性），remove=true 删除该属性
+//   - 只改动目标元素（或其开始标签）的字节区间；多行正文按目标位置的缩进重新排版
+func HTMLEdit(op, repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	raw := strings.TrimSpace(args["selector"])
+	if raw == "" {
+		return fmt.Errorf("%s: 缺少 selector=（CSS 选择器，如 #login-form > button.primary）", op)
+	}
+	groups, err := parseSelector(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	content := strings.Trim(normalizeLF(body), "\n")
+
+	abs := filepath.Join(repo, rel)
+	text, ff, err := readText(abs)
+	if err != nil {
+		return err
+	}
+	xml := preflight.IsXMLPath(rel)
+	root, err := preflight.ParseHTMLDoc([]byte(text), xml)
+	if err != nil {
+		return fmt.Errorf("%s: %s 不是格式良好的 HTML/XML：%v", op, rel, err)
+	}
+	nodes := selectHTML(root, groups, xml)
+	if len(nodes) > 1 && !argOn(args, "all") {
+		var at []string
+		for _, n := range nodes {
+			at = append(at, fmt.Sprintf("%d", strings.Count(text[:n.Start], "\n")+1))
+		}
+		return fmt.Errorf("%s: %s 选择器 %q 匹配到 %d 个元素（第 %s 行），请收窄选择器或加 all=true", op, rel, raw, len(nodes), strings.Join(at, "、"))
+	}
+	e := &htmlEditor{text: text, unit: preflight.DetectIndent([]byte(text))}
+	if e.unit == "" {
+		e.unit = "  "
+	}
+	where := rel + " " + raw
+
+	var st applyState
+	switch op {
+	case "html.replace":
+		st, err = e.replace(outermost(nodes), content, argOn(args, "inner"), idemExplicit(args))
+	case "html.insert":
+		st, err = e.insert(nodes, content, strings.ToLower(strings.TrimSpace(args["position"])))
+	case "html.remove":
+		st, err = e.remove(outermost(nodes))
+	case "html.set-attr":
+		st, err = e.setAttr(nodes, args, xml)
+	default:
+		return errors.New("未知指令: " + op)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s %w", op, where, err)
+	}
+	out := e.apply()
+	if st == stateApplied || out == text {
+		if skip, err := idemSkip(op, where, stateApplied, args, logger); err != nil || skip {
+			return err
+		}
+		if out == text {
+			return nil
+		}
+	}
+	if err := writeText(abs, out, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ %s: %s（%d 处）", op, where, len(e.edits))
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+type htmlEdit struct {
+	from, to int
+	s        string
+}
+
+type htmlEditor struct {
+	text  string
+	unit  string
+	edits []htmlEdit
+}
+
+func (e *htmlEditor) edit(from, to int, s string) {
+	e.edits = append(e.edits, htmlEdit{from, to, s})
+}
+
+// apply 从后往前应用各处修改
+func (e *htmlEditor) apply() string {
+	sort.SliceStable(e.edits, func(i, j int) bool { return e.edits[i].from > e.edits[j].from })
+	out := e.text
+	for _, d := range e.edits {
+		out = out[:d.from] + d.s + out[d.to:]
+	}
+	return out
+}
+
+// outermost 去掉被其它匹配元素包含的元素（整体替换 / 删除时只处理外层）
+func outermost(nodes []*preflight.HTMLNode) []*preflight.HTMLNode {
+	var out []*preflight.HTMLNode
+	for _, n := range nodes {
+		if len(out) > 0 && n.Start < out[len(out)-1].End {
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// squash 折叠空白，用于忽略排版差异的比较
+func squash(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+func (e *htmlEditor) lineStart(pos int) int { return strings.LastIndexByte(e.text[:pos], '\n') + 1 }
+
+func (e *htmlEditor) lineEnd(pos int) int {
+	if k := strings.IndexByte(e.text[pos:], '\n'); k >= 0 {
+		return pos + k
+	}
+	return len(e.text)
+}
+
+func (e *htmlEditor) indentAt(pos int) string {
+	ls := e.lineStart(pos)
+	i := ls
+	for i < len(e.text) && (e.text[i] == ' ' || e.text[i] == '\t') {
+		i++
+	}
+	return e.text[ls:i]
+}
+
+// aloneBefore / aloneAfter：pos 所在行在 pos 之前 / 之后只有空白
+func (e *htmlEditor) aloneBefore(pos int) bool {
+	return strings.TrimSpace(e.text[e.lineStart(pos):pos]) == ""
+}
+
+func (e *htmlEditor) aloneAfter(pos int) bool {
+	return strings.TrimSpace(e.text[pos:e.lineEnd(pos)]) == ""
+}
+
+// childIndent 元素内容的缩进：取第一个独占一行的子元素，否则比元素多一级
+func (e *htmlEditor) childIndent(n *preflight.HTMLNode) string {
+	for _, c := range n.Children {
+		if e.aloneBefore(c.Start) {
+			return e.indentAt(c.Start)
+		}
+	}
+	return e.indentAt(n.Start) + e.unit
+}
+
+// reindent 去掉正文的公共缩进后，每个非空行加上 indent
+func reindent(content, indent string) string {
+	lines := strings.Split(content, "\n")
+	common := -1
+	for _, l := range lines {
+		if t := strings.TrimLeft(l, " \t"); t != "" && (common < 0 || len(l)-len(t) < common) {
+			common = len(l) - len(t)
+		}
+	}
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = indent + l[common:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replace 选择器未命中时报错；只有显式 idempotent= 且替换内容已在文件中时才视为已应用
+// （替换后的元素可能不再匹配原选择器）
+func (e *htmlEditor) replace(nodes []*preflight.HTMLNode, content string, inner, idem bool) (applyState, error) {
+	if content == "" && !inner {
+		return stateAbsent, errors.New("缺少替换内容（正文）；删除元素请用 html.remove")
+	}
+	if len(nodes) == 0 {
+		if idem && content != "" && strings.Contains(squash(e.text), squash(content)) {
+			return stateApplied, nil
+		}
+		return stateAbsent, errors.New("选择器未匹配任何元素")
+	}
+	st := stateApplied
+	for _, n := range nodes {
+		if !inner {
+			if squash(e.text[n.Start:n.End]) == squash(content) {
+				continue
+			}
+			st = stateAbsent
+			e.edit(n.Start, n.End, strings.TrimLeft(reindent(content, e.indentAt(n.Start)), " \t"))
+			continue
+		}
+		if n.Void() {
+			return stateAbsent, fmt.Errorf("<%s> 没有内容区，不能 inner=true", n.Tag)
+		}
+		old := e.text[n.OpenEnd:n.CloseStart]
+		if squash(old) == squash(content) {
+			continue
+		}
+		st = stateAbsent
+		s := content
+		if strings.Contains(content, "\n") || strings.Contains(old, "\n") {
+			s = "\n" + reindent(content, e.childIndent(n)) + "\n" + e.indentAt(n.Start)
+			if content == "" {
+				s = ""
+			}
+		}
+		e.edit(n.OpenEnd, n.CloseStart, s)
+	}
+	return st, nil
+}
+
+func (e *htmlEditor) insert(nodes []*preflight.HTMLNode, content, pos string) (applyState, error) {
+	if content == "" {
+		return stateAbsent, errors.New("缺少要插入的内容（正文）")
+	}
+	if pos == "" {
+		pos = "append"
+	}
+	if len(nodes) == 0 {
+		return stateAbsent, errors.New("选择器未匹配任何元素")
+	}
+	want := squash(content)
+	st := stateApplied
+	for _, n := range nodes {
+		// before / after 插入后，:last-child 之类的选择器可能改为匹配到刚插入的元素本身
+		done := (pos == "before" || pos == "after") && squash(e.text[n.Start:n.End]) == want
+		switch {
+		case done:
+		case pos == "before":
+			done = strings.HasSuffix(squash(e.text[n.Parent.OpenEnd:n.Start]), want)
+		case pos == "after":
+			done = strings.HasPrefix(squash(e.text[n.End:n.Parent.CloseStart]), want)
+		case pos == "prepend" || pos == "append":
+			if n.Void() {
+				return stateAbsent, fmt.Errorf("<%s> 没有内容区，不能 position=%s", n.Tag, pos)
+			}
+			inner := squash(e.text[n.OpenEnd:n.CloseStart])
+			done = pos == "prepend" && strings.HasPrefix(inner, want) || pos == "append" && strings.HasSuffix(inner, want)
+		default:
+			return stateAbsent, fmt.Errorf("position=%s 无效（before|after|prepend|append）", pos)
+		}
+		if done {
+			continue
+		}
+		st = stateAbsent
+		// 目标独占一行时按整行插入，否则就地插入
+		multi := e.lineStart(n.OpenEnd) != e.lineStart(n.CloseStart)
+		switch {
+		case pos == "before" && e.aloneBefore(n.Start):
+			ls := e.lineStart(n.Start)
+			e.edit(ls, ls, reindent(content, e.indentAt(n.Start))+"\n")
+		case pos == "before":
+			e.edit(n.Start, n.Start, content)
+		case pos == "after" && e.aloneAfter(n.End):
+			le := e.lineEnd(n.End)
+			e.edit(le, le, "\n"+reindent(content, e.indentAt(n.Start)))
+		case pos == "after":
+			e.edit(n.End, n.End, content)
+		case pos == "prepend" && multi && e.aloneAfter(n.OpenEnd):
+			le := e.lineEnd(n.OpenEnd)
+			e.edit(le, le, "\n"+reindent(content, e.childIndent(n)))
+		case pos == "prepend":
+			e.edit(n.OpenEnd, n.OpenEnd, content)
+		case pos == "append" && multi && e.aloneBefore(n.CloseStart):
+			ls := e.lineStart(n.CloseStart)
+			e.edit(ls, ls, reindent(content, e.childIndent(n))+"\n")
+		default:
+			e.edit(n.CloseStart, n.CloseStart, content)
+		}
+	}
+	return st, nil
+}
+
+func (e *htmlEditor) remove(nodes []*preflight.HTMLNode) (applyState, error) {
+	if len(nodes) == 0 {
+		return stateApplied, nil
+	}
+	for _, n := range nodes {
+		from, to := n.Start, n.End
+		if e.aloneBefore(n.Start) && e.aloneAfter(n.End) {
+			from, to = e.lineStart(n.Start), e.lineEnd(n.End)
+			if to < len(e.text) {
+				to++
+			} else if from > 0 {
+				from--
+			}
+		}
+		e.edit(from, to, "")
+	}
+	return stateAbsent, nil
+}
+
+func (e *htmlEditor) setAttr(nodes []*preflight.HTMLNode, args map[string]string, xml bool) (applyState, error) {
+	name := strings.TrimSpace(args["name"])
+	if name == "" {
+		return stateAbsent, errors.New("缺少 name=（属性名）")
+	}
+	key := name
+	if !xml {
+		key = strings.ToLower(name)
+	}
+	value, hasValue := args["value"]
+	if xml && !hasValue && !argOn(args, "remove") {
+		return stateAbsent, errors.New("XML 属性必须有值（value=）")
+	}
+	if len(nodes) == 0 {
+		return stateAbsent, errors.New("选择器未匹配任何元素")
+	}
+	st := stateApplied
+	for _, n := range nodes {
+		a := n.Attr(key)
+		if argOn(args, "remove") {
+			if a == nil {
+				continue
+			}
+			from := a.Start
+			for from > n.NameEnd && (e.text[from-1] == ' ' || e.text[from-1] == '\t') {
+				from--
+			}
+			st = stateAbsent
+			e.edit(from, a.End, "")
+			continue
+		}
+		attr := name
+		if hasValue {
+			q := byte('"')
+			if a != nil && a.Quote == '\'' && !strings.Contains(value, "'") {
+				q = '\''
+			}
+			esc := "&quot;"
+			if q == '\'' {
+				esc = "&#39;"
+			}
+			attr += "=" + string(q) + strings.ReplaceAll(value, string(q), esc) + string(q)
+		}
+		if a == nil {
+			at := n.NameEnd
+			if k := len(n.Attrs); k > 0 {
+				at = n.Attrs[k-1].End
+			}
+			st = stateAbsent
+			e.edit(at, at, " "+attr)
+			continue
+		}
+		if hasValue && a.ValStart >= 0 && a.Value == value || !hasValue && a.ValStart < 0 {
+			continue
+		}
+		st = stateAbsent
+		e.edit(a.Start, a.End, e.text[a.Start:a.Start+len(name)]+attr[len(name):])
+	}
+	return st, nil
+}
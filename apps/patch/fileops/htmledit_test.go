@@ -0,0 +1,21 @@
+package fileops
+
+import "testing"
+
+// 选择器未命中：替换内容碰巧已在文件里也不能算已应用，除非显式 idempotent=
+func TestHTMLReplaceMissFailsLoudly(t *testing.T) {
+	src := "<div>\n  <p class=\"new\">hi</p>\n</div>\n"
+	repo := writeTestTree(t, map[string]string{"a.html": src})
+	args := map[string]string{"selector": "p.old"}
+	body := "<p class=\"new\">hi</p>\n"
+	if err := HTMLEdit("html.replace", repo, "a.html", body, args, nil, nil); err == nil {
+		t.Fatal("选择器未命中应报错")
+	}
+	args["idempotent"] = "on"
+	if err := HTMLEdit("html.replace", repo, "a.html", body, args, nil, nil); err != nil {
+		t.Fatalf("显式 idempotent=on 且内容已存在应视为已应用：%v", err)
+	}
+	if got := readTestFile(t, repo, "a.html"); got != src {
+		t.Fatalf("内容 = %q", got)
+	}
+}
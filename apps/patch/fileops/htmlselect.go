@@ -0,0 +1,340 @@
+package fileops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/preflight"
+)
+
+// CSS 选择器（html.* 指令用的子集）
+//   - 类型 / 通配：div、*；ID：#login；类：.primary；属性：[type]、[type=submit]、[class~=a]、[href^=http]、[src$=.png]、[title*=x]、[lang|=zh]
+//   - 伪类：:first-child、:last-child、:only-child、:nth-child(N)、:first-of-type、:last-of-type、:nth-of-type(N)
+//   - 组合符：后代（空格）、子（>）、相邻兄弟（+）、后续兄弟（~）；逗号分隔多组
+type selector []selCompound
+
+type selCompound struct {
+	comb    byte // 与左侧复合选择器的关系：' ' '>' '+' '~'；首个为 0
+	tag     string
+	id      string
+	classes []string
+	attrs   []selAttr
+	pseudos []selPseudo
+}
+
+type selAttr struct{ name, op, val string }
+
+type selPseudo struct {
+	name string
+	n    int
+}
+
+func isSelIdent(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c >= 0x80
+}
+
+// parseSelector 解析逗号分隔的选择器组
+func parseSelector(s string) ([]selector, error) {
+	var groups []selector
+	var cur selector
+	i := 0
+	ident := func() string {
+		start := i
+		for i < len(s) && (isSelIdent(s[i]) || s[i] == '\\' && i+1 < len(s)) {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		return strings.ReplaceAll(s[start:i], "\\", "")
+	}
+	bad := func(why string) error {
+		return fmt.Errorf("选择器 %q 无效：%s（第 %d 个字符）", s, why, i+1)
+	}
+	var comb byte
+	for {
+		for i < len(s) && s[i] == ' ' {
+			if comb == 0 {
+				comb = ' '
+			}
+			i++
+		}
+		if i >= len(s) || s[i] == ',' {
+			if len(cur) == 0 || comb != 0 && comb != ' ' {
+				return nil, bad("缺少选择器")
+			}
+			groups = append(groups, cur)
+			if i >= len(s) {
+				return groups, nil
+			}
+			cur, comb = nil, 0
+			i++
+			continue
+		}
+		if strings.IndexByte(">+~", s[i]) >= 0 {
+			if len(cur) == 0 {
+				return nil, bad("组合符前缺少选择器")
+			}
+			comb = s[i]
+			i++
+			continue
+		}
+		c := selCompound{comb: comb}
+		if len(cur) == 0 {
+			c.comb = 0
+		}
+		comb = 0
+		if s[i] == '*' {
+			c.tag = "*"
+			i++
+		} else if isSelIdent(s[i]) {
+			c.tag = ident()
+		}
+	simple:
+		for i < len(s) {
+			switch s[i] {
+			case '#':
+				i++
+				if c.id = ident(); c.id == "" {
+					return nil, bad("# 之后缺少 ID")
+				}
+			case '.':
+				i++
+				cls := ident()
+				if cls == "" {
+					return nil, bad(". 之后缺少类名")
+				}
+				c.classes = append(c.classes, cls)
+			case '[':
+				end := strings.IndexByte(s[i:], ']')
+				if end < 0 {
+					return nil, bad("[ 未闭合")
+				}
+				a, err := parseSelAttr(s[i+1 : i+end])
+				if err != nil {
+					return nil, bad(err.Error())
+				}
+				c.attrs = append(c.attrs, a)
+				i += end + 1
+			case ':':
+				i++
+				ps := selPseudo{name: strings.ToLower(ident())}
+				if i < len(s) && s[i] == '(' {
+					end := strings.IndexByte(s[i:], ')')
+					if end < 0 {
+						return nil, bad("( 未闭合")
+					}
+					n, err := strconv.Atoi(strings.TrimSpace(s[i+1 : i+end]))
+					if err != nil || n < 1 {
+						return nil, bad("只支持正整数参数，如 :nth-child(2)")
+					}
+					ps.n = n
+					i += end + 1
+				}
+				switch ps.name {
+				case "first-child", "last-child", "only-child", "first-of-type", "last-of-type":
+				case "nth-child", "nth-of-type":
+					if ps.n == 0 {
+						return nil, bad(":" + ps.name + " 缺少参数")
+					}
+				default:
+					return nil, bad("不支持的伪类 :" + ps.name)
+				}
+				c.pseudos = append(c.pseudos, ps)
+			default:
+				break simple
+			}
+		}
+		if c.tag == "" && c.id == "" && len(c.classes) == 0 && len(c.attrs) == 0 && len(c.pseudos) == 0 {
+			return nil, bad("意外的字符 " + strconv.QuoteRune(rune(s[i])))
+		}
+		cur = append(cur, c)
+	}
+}
+
+func parseSelAttr(s string) (selAttr, error) {
+	a := selAttr{name: strings.TrimSpace(s)}
+	if k := strings.IndexAny(s, "~^$*|="); k >= 0 {
+		a.name = strings.TrimSpace(s[:k])
+		if s[k] == '=' {
+			a.op = "="
+		} else if k+1 < len(s) && s[k+1] == '=' {
+			a.op = s[k : k+2]
+		} else {
+			return a, fmt.Errorf("属性选择器 [%s] 无效", s)
+		}
+		v := strings.TrimSpace(s[k+len(a.op):])
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		a.val = v
+	}
+	if a.name == "" {
+		return a, fmt.Errorf("属性选择器 [%s] 缺少属性名", s)
+	}
+	for i := 0; i < len(a.name); i++ {
+		if !isSelIdent(a.name[i]) && a.name[i] != ':' {
+			return a, fmt.Errorf("属性选择器 [%s] 无效：属性名含非法字符", s)
+		}
+	}
+	return a, nil
+}
+
+// selectHTML 按文档顺序返回匹配任一选择器组的元素
+func selectHTML(root *preflight.HTMLNode, groups []selector, xml bool) []*preflight.HTMLNode {
+	var out []*preflight.HTMLNode
+	var walk func(n *preflight.HTMLNode)
+	walk = func(n *preflight.HTMLNode) {
+		for _, c := range n.Children {
+			for _, g := range groups {
+				if g.matchAt(len(g)-1, c, xml) {
+					out = append(out, c)
+					break
+				}
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return out
+}
+
+func (sel selector) matchAt(i int, n *preflight.HTMLNode, xml bool) bool {
+	if !sel[i].match(n, xml) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch sel[i].comb {
+	case '>':
+		return n.Parent.Tag != "" && sel.matchAt(i-1, n.Parent, xml)
+	case '+':
+		sibs := n.Parent.Children
+		k := indexOfNode(sibs, n)
+		return k > 0 && sel.matchAt(i-1, sibs[k-1], xml)
+	case '~':
+		sibs := n.Parent.Children
+		for _, s := range sibs[:indexOfNode(sibs, n)] {
+			if sel.matchAt(i-1, s, xml) {
+				return true
+			}
+		}
+		return false
+	default:
+		for a := n.Parent; a.Tag != ""; a = a.Parent {
+			if sel.matchAt(i-1, a, xml) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func indexOfNode(list []*preflight.HTMLNode, n *preflight.HTMLNode) int {
+	for i, c := range list {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c selCompound) match(n *preflight.HTMLNode, xml bool) bool {
+	name := func(s string) string {
+		if xml {
+			return s
+		}
+		return strings.ToLower(s)
+	}
+	if c.tag != "" && c.tag != "*" && name(c.tag) != n.Tag {
+		return false
+	}
+	if c.id != "" {
+		if a := n.Attr("id"); a == nil || a.Value != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		a := n.Attr("class")
+		if a == nil {
+			return false
+		}
+		have := strings.Fields(a.Value)
+		for _, want := range c.classes {
+			if !containsStr(have, want) {
+				return false
+			}
+		}
+	}
+	for _, sa := range c.attrs {
+		a := n.Attr(name(sa.name))
+		if a == nil {
+			return false
+		}
+		v := a.Value
+		ok := true
+		switch sa.op {
+		case "=":
+			ok = v == sa.val
+		case "~=":
+			ok = containsStr(strings.Fields(v), sa.val)
+		case "^=":
+			ok = sa.val != "" && strings.HasPrefix(v, sa.val)
+		case "$=":
+			ok = sa.val != "" && strings.HasSuffix(v, sa.val)
+		case "*=":
+			ok = sa.val != "" && strings.Contains(v, sa.val)
+		case "|=":
+			ok = v == sa.val || strings.HasPrefix(v, sa.val+"-")
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(c.pseudos) == 0 {
+		return true
+	}
+	sibs := n.Parent.Children
+	pos := indexOfNode(sibs, n)
+	var same []*preflight.HTMLNode
+	for _, s := range sibs {
+		if s.Tag == n.Tag {
+			same = append(same, s)
+		}
+	}
+	typePos := indexOfNode(same, n)
+	for _, ps := range c.pseudos {
+		var ok bool
+		switch ps.name {
+		case "first-child":
+			ok = pos == 0
+		case "last-child":
+			ok = pos == len(sibs)-1
+		case "only-child":
+			ok = len(sibs) == 1
+		case "nth-child":
+			ok = pos == ps.n-1
+		case "first-of-type":
+			ok = typePos == 0
+		case "last-of-type":
+			ok = typePos == len(same)-1
+		case "nth-of-type":
+			ok = typePos == ps.n-1
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func containsStr(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+
+	"xgit/apps/patch/preflight"
+)
+
+// 每个元素带 id，按文档顺序收集命中元素的 id
+const selectDoc = `<ul id="l">
+  <li id="a" class="x y">A</li>
+  <li id="b" lang="zh-CN">B</li>
+  <p id="c">C</p>
+  <li id="d" data-src="https://x/a.png" title="hello">D</li>
+</ul>
+<div id="e"><span id="f"><em id="g"></em></span></div>
+<section id="h"><p id="i"><br id="j"></p></section>
+`
+
+func selectIDs(t *testing.T, src, sel string, xml bool) string {
+	t.Helper()
+	root, err := preflight.ParseHTMLDoc([]byte(src), xml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := parseSelector(sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, n := range selectHTML(root, groups, xml) {
+		if a := n.Attr("id"); a != nil {
+			ids = append(ids, a.Value)
+		} else {
+			ids = append(ids, n.Tag)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSelectHTML(t *testing.T) {
+	cases := []struct{ sel, want string }{
+		{"li", "a,b,d"},
+		{"LI", "a,b,d"},
+		{"*", "l,a,b,c,d,e,f,g,h,i,j"},
+		{"#c", "c"},
+		{".x.y", "a"},
+		{".x.z", ""},
+		// 组合符
+		{"div em", "g"},
+		{"div > em", ""},
+		{"span > em", "g"},
+		{"ul > li", "a,b,d"},
+		{"li + p", "c"},
+		{"li + li", "b"},
+		{"li ~ li", "b,d"},
+		{"p ~ li", "d"},
+		{"ul  >  li.x", "a"},
+		{"em, #c, li.x", "a,c,g"},
+		{"section p br", "j"},
+		// 属性
+		{"[lang]", "b"},
+		{"[id=c]", "c"},
+		{`[title="hello"]`, "d"},
+		{"[class~=y]", "a"},
+		{"[class~=x y]", ""},
+		{"[data-src^=https]", "d"},
+		{"[data-src$='.png']", "d"},
+		{"[title*=ell]", "d"},
+		{"[lang|=zh]", "b"},
+		{"[lang|=zh-CN]", "b"},
+		{"[lang|=z]", ""},
+		{"[title^=]", ""},
+		// 伪类（只数元素，不数文本）
+		{"li:first-child", "a"},
+		{"li:last-child", "d"},
+		{":only-child", "f,g,i,j"},
+		{"ul > :nth-child(3)", "c"},
+		{"li:nth-child(3)", ""},
+		{"li:first-of-type", "a"},
+		{"li:last-of-type", "d"},
+		{"li:nth-of-type(3)", "d"},
+		{"p:first-of-type", "c,i"},
+		{"li:first-child + li", "b"},
+	}
+	for _, c := range cases {
+		t.Run(c.sel, func(t *testing.T) {
+			if got := selectIDs(t, selectDoc, c.sel, false); got != c.want {
+				t.Fatalf("命中 %q，期望 %q", got, c.want)
+			}
+		})
+	}
+}
+
+// XML 模式：标签名与属性名区分大小写
+func TestSelectXMLCaseSensitive(t *testing.T) {
+	src := `<Root><Item id="a" Kind="x"/><item id="b"/></Root>`
+	for sel, want := range map[string]string{
+		"Item":            "a",
+		"item":            "b",
+		"[Kind=x]":        "a",
+		"[kind=x]":        "",
+		"Root > item":     "b",
+		"Item + item":     "b",
+		"item:last-child": "b",
+	} {
+		if got := selectIDs(t, src, sel, true); got != want {
+			t.Errorf("%s：命中 %q，期望 %q", sel, got, want)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for sel, want := range map[string]string{
+		"":                   "缺少选择器",
+		"a,":                 "缺少选择器",
+		"a >":                "缺少选择器",
+		"> a":                "组合符前缺少选择器",
+		"#":                  "# 之后缺少 ID",
+		"a.":                 ". 之后缺少类名",
+		"[href":              "[ 未闭合",
+		"[=x]":               "缺少属性名",
+		"[]":                 "缺少属性名",
+		"[a b]":              "非法字符",
+		"[a!=x]":             "无效",
+		"li:nth-child":       "缺少参数",
+		"li:nth-child(0)":    "正整数",
+		"li:nth-child(2n+1)": "正整数",
+		"li:hover":           "不支持的伪类",
+		"a/b":                "意外的字符",
+	} {
+		if _, err := parseSelector(sel); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q：err = %v，期望包含 %q", sel, err, want)
+		}
+	}
+}
+
+// 省略的结束标签按 HTML 规则隐式闭合，影响组合符的判定
+func TestSelectHTMLImpliedEnds(t *testing.T) {
+	src := "<ul><li id=\"a\">A<li id=\"b\">B</ul>\n<p id=\"p\">text<div id=\"d\"></div>\n"
+	for sel, want := range map[string]string{
+		"li + li": "b",
+		"li li":   "",
+		"p div":   "",
+		"p + div": "d",
+	} {
+		if got := selectIDs(t, src, sel, false); got != want {
+			t.Errorf("%s：命中 %q，期望 %q", sel, got, want)
+		}
+	}
+}
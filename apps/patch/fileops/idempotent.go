@@ -0,0 +1,150 @@
+package fileops
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//
+// 幂等检测：同一补丁重复投递（hash 因尾随空白等细节不同）时，识别“目标状态已存在”并跳过。
+// 模式（参数 idempotent= 或补丁头 idempotent:）：
+//   - on（默认）：已应用 → 跳过；部分存在 → 照常执行
+//   - strict：已应用 → 跳过；部分存在 → 报错
+//   - off：不做检测
+//
+
+type applyState int
+
+const (
+	stateAbsent  applyState = iota // 未应用
+	statePartial                   // 部分存在（例如上次追加中断）
+	stateApplied                   // 目标状态已存在
+)
+
+func idemMode(args map[string]string) string {
+	switch v := strings.ToLower(strings.TrimSpace(args["idempotent"])); v {
+	case "off", "false", "0", "no":
+		return "off"
+	case "strict":
+		return "strict"
+	default:
+		return "on"
+	}
+}
+
+// idemExplicit 指令参数或补丁头显式给出了 idempotent=on|strict
+func idemExplicit(args map[string]string) bool {
+	_, ok := args["idempotent"]
+	return ok && idemMode(args) != "off"
+}
+
+// idemSkip 根据检测结果决定是否跳过本指令；strict 模式下部分存在返回错误
+func idemSkip(op, rel string, st applyState, args map[string]string, logger DualLogger) (bool, error) {
+	switch idemMode(args) {
+	case "off":
+		return false, nil
+	case "strict":
+		if st == statePartial {
+			return false, fmt.Errorf("%s: %s 目标内容仅部分存在（idempotent=strict）", op, rel)
+		}
+	}
+	if st == stateApplied {
+		if logger != nil {
+			logger.Log("⏭️ %s: %s 已应用，跳过", op, rel)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// sameLine 比较两行（忽略行尾空白与换行差异）
+func sameLine(a, b string) bool {
+	return strings.TrimRight(a, " \t\r\n") == strings.TrimRight(b, " \t\r\n")
+}
+
+// significant: 含字母或数字的行才算“有效证据”（避免单独的 } / 空行造成误判）
+func significant(block []string) bool {
+	for _, l := range block {
+		for _, r := range l {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchAt: lines[at:] 是否以 block 开头（at 为 0-based）
+func matchAt(lines []string, at int, block []string) bool {
+	if at < 0 || at+len(block) > len(lines) {
+		return false
+	}
+	for i, b := range block {
+		if !sameLine(lines[at+i], b) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchExact: lines[at:] 是否逐字节以 block 开头（只忽略行尾换行）；
+// 用于 file.write / line.replace / block.replace 的“已应用”判断——只改尾随空白的替换也是真实改动
+func matchExact(lines []string, at int, block []string) bool {
+	if at < 0 || at+len(block) > len(lines) {
+		return false
+	}
+	for i, b := range block {
+		if strings.TrimSuffix(lines[at+i], "\n") != strings.TrimSuffix(b, "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// stateBefore：block 是否已紧邻位于 0-based 下标 at 之前（line.insert / file.append 语义）
+// 完整命中且含有效内容（significant）→ applied；block 的前 k 行恰好构成 at 之前的尾部（中断的写入）→ partial
+func stateBefore(lines []string, at int, block []string) applyState {
+	n := len(block)
+	if n == 0 {
+		return stateAbsent
+	}
+	if !significant(block) {
+		return stateAbsent // 空行、单独的 } 出现在那里不能证明上次已经写过
+	}
+	if matchAt(lines, at-n, block) {
+		return stateApplied
+	}
+	for k := n - 1; k > 0; k-- {
+		if matchAt(lines, at-k, block[:k]) && significant(block[:k]) {
+			return statePartial
+		}
+	}
+	return stateAbsent
+}
+
+// stateAfter：block 是否已紧邻位于 0-based 下标 at 处开始（line.append / file.prepend 语义）
+// 完整命中且含有效内容（significant）→ applied；block 的后 k 行恰好位于 at 处（中断的写入）→ partial
+func stateAfter(lines []string, at int, block []string) applyState {
+	n := len(block)
+	if n == 0 {
+		return stateAbsent
+	}
+	if !significant(block) {
+		return stateAbsent
+	}
+	if matchAt(lines, at, block) {
+		return stateApplied
+	}
+	for k := n - 1; k > 0; k-- {
+		if matchAt(lines, at, block[n-k:]) && significant(block[n-k:]) {
+			return statePartial
+		}
+	}
+	return stateAbsent
+}
+
+// textLines 把整段文本转成“行模型”，用于整文件级别的比较
+func textLines(s string) []string {
+	return splitPayload(normalizeLF(s))
+}
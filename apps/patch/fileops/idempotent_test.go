@@ -0,0 +1,120 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, content string) (string, string) {
+	t.Helper()
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return repo, "a.txt"
+}
+
+func readTestFile(t *testing.T, repo, rel string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(repo, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLineInsertRerunIsApplied(t *testing.T) {
+	repo, rel := writeTestFile(t, "a\nb\n")
+	args := map[string]string{"keys": "b"}
+	for i := 0; i < 2; i++ {
+		if err := LineInsert(repo, rel, "x\n", args, nil); err != nil {
+			t.Fatalf("第 %d 次：%v", i+1, err)
+		}
+	}
+	if got := readTestFile(t, repo, rel); got != "a\nx\nb\n" {
+		t.Fatalf("重复执行后内容 = %q", got)
+	}
+}
+
+// 锚点写错时不能因为正文恰好出现在别处而静默跳过
+func TestLineOpsWrongAnchorFails(t *testing.T) {
+	repo, rel := writeTestFile(t, "a\nx\nb\n")
+	args := map[string]string{"keys": "no-such-anchor", "match": "exact"}
+	if err := LineInsert(repo, rel, "x\n", args, nil); err == nil {
+		t.Error("line.insert 锚点未命中应报错")
+	}
+	if err := LineAppend(repo, rel, "x\n", args, nil); err == nil {
+		t.Error("line.append 锚点未命中应报错")
+	}
+	if err := LineReplace(repo, rel, "x\n", args, nil); err == nil {
+		t.Error("line.replace 锚点未命中应报错")
+	}
+	bad := map[string]string{"start-keys": "no-such-start", "match": "exact"}
+	if err := BlockReplace(repo, rel, "x\n", bad, nil); err == nil {
+		t.Error("block.replace 作用域未命中应报错")
+	}
+}
+
+// 正文不在锚点旁（而在别处）时照常执行
+func TestLineAppendBodyElsewhereStillApplies(t *testing.T) {
+	repo, rel := writeTestFile(t, "x\na\nb\n")
+	if err := LineAppend(repo, rel, "x\n", map[string]string{"keys": "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, rel); strings.Count(got, "x\n") != 2 {
+		t.Fatalf("内容 = %q", got)
+	}
+}
+
+// 上次中断只写入了正文的前几行：strict 报错，默认模式照常执行
+func TestStrictPartialFails(t *testing.T) {
+	repo, rel := writeTestFile(t, "a\nx\nb\n")
+	if err := LineInsert(repo, rel, "x\ny\n", map[string]string{"keys": "b", "idempotent": "strict"}, nil); err == nil {
+		t.Fatal("strict 下部分存在应报错")
+	}
+	if err := LineInsert(repo, rel, "x\ny\n", map[string]string{"keys": "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 空行、单独的 } 这类没有有效内容的正文不能作为“已应用”的证据
+func TestInsignificantBodyIsNotApplied(t *testing.T) {
+	repo, rel := writeTestFile(t, "a\n\nb\n")
+	if err := LineInsert(repo, rel, "\n", map[string]string{"keys": "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, rel); got != "a\n\n\nb\n" {
+		t.Fatalf("line.insert 空行被跳过：%q", got)
+	}
+	repo, rel = writeTestFile(t, "func f() {\n}\n")
+	if err := FileAppend(repo, rel, []byte("}\n"), map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, rel); got != "func f() {\n}\n}\n" {
+		t.Fatalf("file.append } 被跳过：%q", got)
+	}
+}
+
+// 只去掉尾随空白的写入 / 替换是真实改动
+func TestTrailingWhitespaceChangesAreApplied(t *testing.T) {
+	repo, rel := writeTestFile(t, "a  \nb\t\n")
+	if err := FileWrite(repo, rel, []byte("a\nb\n"), map[string]string{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, rel); got != "a\nb\n" {
+		t.Fatalf("file.write 被跳过：%q", got)
+	}
+	repo, rel = writeTestFile(t, "x := 1   \ny\n")
+	if err := LineReplace(repo, rel, "x := 1\n", map[string]string{"keys": "x := 1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, rel); got != "x := 1\ny\n" {
+		t.Fatalf("line.replace 被跳过：%q", got)
+	}
+	// 逐字节相同仍视为已应用
+	if err := FileWrite(repo, rel, []byte("x := 1\ny\n"), map[string]string{"idempotent": "strict"}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
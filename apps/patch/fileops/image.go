@@ -27,7 +27,11 @@ func FileImage(repo, rel, base64Data string, logger DualLogger) error {
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(abs, raw, 0o644); err != nil {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(abs); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := WriteFileAtomic(abs, raw, mode); err != nil {
 		return err
 	}
 	if logger != nil {
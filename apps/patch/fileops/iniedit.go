@@ -0,0 +1,281 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// iniEditor INI 与 dotenv（.env）
+//   - INI：key 为全局键；section.key 为节内键（节名含 . 时写作 ["a.b"].key）；ini.delete 只给节名时删除整节
+//   - dotenv：只有一层键，可带 export 前缀
+//   - 改值时保留原行的键、分隔符、空白与行尾注释（空白后的 ; 或 #，dotenv 只认 #），只替换值部分
+//   - 缩进的续行视为上一个键的值；能解析为 key=value / key: value 的缩进行是新的键（git config 风格）
+type iniEditor struct{ env bool }
+
+type iniEntry struct {
+	section string
+	key     string
+	line    int // 键所在行
+	end     int // 值结束行（不含，含续行）
+	valCol  int // 值起始列
+	sep     string
+}
+
+type iniDoc struct {
+	lines    []string
+	headers  map[string]int // 节名 → 表头行
+	order    []string       // 节出现顺序
+	entries  []iniEntry
+	env      bool
+	firstSep string
+}
+
+func parseINI(text string, env bool) *iniDoc {
+	d := &iniDoc{lines: strings.Split(text, "\n"), headers: map[string]int{}, env: env}
+	sec := ""
+	for i := 0; i < len(d.lines); i++ {
+		l := d.lines[i]
+		t := strings.TrimSpace(l)
+		switch {
+		case t == "" || t[0] == '#' || t[0] == ';':
+			continue
+		case !env && t[0] == '[' && strings.HasSuffix(t, "]"):
+			sec = strings.TrimSpace(t[1 : len(t)-1])
+			if _, ok := d.headers[sec]; !ok {
+				d.order = append(d.order, sec)
+			}
+			d.headers[sec] = i
+			continue
+		}
+		e, ok := iniParseEntry(l, env)
+		if !ok {
+			continue
+		}
+		e.section, e.line, e.end = sec, i, i+1
+		for !env && e.end < len(d.lines) && iniContinuation(d.lines[e.end]) {
+			e.end++
+		}
+		// dotenv 的双引号值可以跨行
+		for env && e.end < len(d.lines) && strings.HasPrefix(l[e.valCol:], `"`) && !envQuoteClosed(strings.Join(d.lines[e.line:e.end], "\n")[e.valCol+1:]) {
+			e.end++
+		}
+		i = e.end - 1
+		if d.firstSep == "" {
+			d.firstSep = e.sep
+		}
+		d.entries = append(d.entries, e)
+	}
+	return d
+}
+
+// iniParseEntry 解析 key=value（INI 也接受 key: value）
+func iniParseEntry(l string, env bool) (iniEntry, bool) {
+	lead := len(l) - len(strings.TrimLeft(l, " \t"))
+	body := l[lead:]
+	if env && strings.HasPrefix(body, "export ") {
+		body = strings.TrimLeft(body[len("export "):], " \t")
+	}
+	keyStart := len(l) - len(body)
+	cut := strings.IndexByte(body, '=')
+	if c := strings.IndexByte(body, ':'); !env && c >= 0 && (cut < 0 || c < cut) {
+		cut = c
+	}
+	if cut <= 0 {
+		return iniEntry{}, false
+	}
+	key := strings.TrimSpace(body[:cut])
+	if key == "" {
+		return iniEntry{}, false
+	}
+	keyEnd := keyStart + len(strings.TrimRight(body[:cut], " \t"))
+	valCol := keyStart + cut + 1
+	for valCol < len(l) && (l[valCol] == ' ' || l[valCol] == '\t') {
+		valCol++
+	}
+	return iniEntry{key: key, valCol: valCol, sep: l[keyEnd:valCol]}, true
+}
+
+// envQuoteClosed s（开引号之后的内容）中是否有未转义的闭合双引号
+func envQuoteClosed(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return true
+		}
+	}
+	return false
+}
+
+// iniContinuation 缩进的非空、非注释行，且本身不是 key=value / key: value 时视为续行
+func iniContinuation(l string) bool {
+	t := strings.TrimSpace(l)
+	if t == "" || t[0] == '#' || t[0] == ';' || (l[0] != ' ' && l[0] != '\t') {
+		return false
+	}
+	_, entry := iniParseEntry(l, false)
+	return !entry
+}
+
+// iniSplitComment 把值部分拆成值与行尾注释（含注释前的空白）；引号内的 ; # 不算注释
+func iniSplitComment(v string, env bool) (string, string) {
+	var quote byte
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 {
+				quote = c
+			}
+		case (c == '#' || c == ';' && !env) && i > 0 && (v[i-1] == ' ' || v[i-1] == '\t'):
+			j := i
+			for j > 0 && (v[j-1] == ' ' || v[j-1] == '\t') {
+				j--
+			}
+			return v[:j], v[j:]
+		}
+	}
+	return v, ""
+}
+
+// target 把键路径拆成（节, 键）
+func (d *iniDoc) target(path []keySeg) (string, string, error) {
+	for _, s := range path {
+		if s.isIdx {
+			return "", "", errors.New("INI / dotenv 不支持下标")
+		}
+	}
+	if d.env && len(path) > 1 {
+		return "", "", errors.New("dotenv 只有一层键")
+	}
+	names := make([]string, len(path)-1)
+	for i, s := range path[:len(path)-1] {
+		names[i] = s.key
+	}
+	return strings.Join(names, "."), path[len(path)-1].key, nil
+}
+
+func (d *iniDoc) find(sec, key string) []iniEntry {
+	var out []iniEntry
+	for _, e := range d.entries {
+		if e.section == sec && e.key == key {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// contentEnd 文件末尾换行对应的空元素之前
+func contentEnd(lines []string) int {
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		return n - 1
+	}
+	return len(lines)
+}
+
+func (ed iniEditor) set(text string, path []keySeg, value string, create bool) (string, applyState, error) {
+	if strings.Contains(value, "\n") {
+		return "", stateAbsent, errors.New("值不能跨行")
+	}
+	d := parseINI(text, ed.env)
+	sec, key, err := d.target(path)
+	if err != nil {
+		return "", stateAbsent, err
+	}
+	if hits := d.find(sec, key); len(hits) > 0 {
+		e := hits[len(hits)-1] // 重复的键以最后一个为准（与常见解析器一致）
+		l := d.lines[e.line]
+		old, comment := iniSplitComment(l[e.valCol:], ed.env)
+		if e.end > e.line+1 {
+			comment = "" // 多行值：注释无法确定归属，整体替换
+		}
+		if strings.TrimSpace(old) == strings.TrimSpace(value) && e.end == e.line+1 {
+			return text, stateApplied, nil
+		}
+		head := l[:e.valCol]
+		if strings.TrimSpace(old) == "" && strings.HasPrefix(e.sep, " ") && !strings.HasSuffix(e.sep, " ") {
+			head += " " // 原值为空（key =）：与等号前的空格对称
+		}
+		return strings.Join(splice(d.lines, e.line, e.end-e.line, []string{head + value + comment}), "\n"), stateAbsent, nil
+	}
+	if !create {
+		return "", stateAbsent, fmt.Errorf("键不存在（create=false）")
+	}
+	sep := d.firstSep
+	if sep == "" {
+		sep = " = "
+		if ed.env {
+			sep = "="
+		}
+	}
+	entry := key + sep + value
+
+	// 插在同一节最后一个键之后；节不存在时在文件末尾新建
+	at := -1
+	if hdr, ok := d.headers[sec]; ok {
+		at = hdr + 1
+	} else if sec == "" {
+		at = 0
+	}
+	for _, e := range d.entries {
+		if e.section == sec {
+			at = e.end
+		}
+	}
+	if at >= 0 {
+		ins := []string{entry}
+		if sec == "" && at == 0 && len(d.order) > 0 && strings.TrimSpace(d.lines[0]) != "" {
+			ins = append(ins, "")
+		}
+		return strings.Join(insertAt(d.lines, at, ins), "\n"), stateAbsent, nil
+	}
+	end := contentEnd(d.lines)
+	var ins []string
+	if end > 0 && strings.TrimSpace(d.lines[end-1]) != "" {
+		ins = append(ins, "")
+	}
+	ins = append(ins, "["+sec+"]", entry)
+	return strings.Join(insertAt(d.lines, end, ins), "\n"), stateAbsent, nil
+}
+
+func (ed iniEditor) delete(text string, path []keySeg) (string, applyState, error) {
+	d := parseINI(text, ed.env)
+	sec, key, err := d.target(path)
+	if err != nil {
+		return "", stateAbsent, err
+	}
+	hits := d.find(sec, key)
+	if len(hits) == 0 && sec == "" && !ed.env {
+		// 只给了节名：删除整节（表头到下一个表头之前）
+		if hdr, ok := d.headers[key]; ok {
+			end := contentEnd(d.lines)
+			for _, h := range d.headers {
+				if h > hdr && h < end {
+					end = h
+				}
+			}
+			if end == contentEnd(d.lines) { // 末尾的节：连同之前的空行一起去掉
+				for hdr > 0 && strings.TrimSpace(d.lines[hdr-1]) == "" {
+					hdr--
+				}
+			}
+			return strings.Join(splice(d.lines, hdr, end-hdr, nil), "\n"), stateAbsent, nil
+		}
+	}
+	if len(hits) == 0 {
+		return text, stateApplied, nil
+	}
+	lines := d.lines
+	for i := len(hits) - 1; i >= 0; i-- {
+		lines = splice(lines, hits[i].line, hits[i].end-hits[i].line, nil)
+	}
+	return strings.Join(lines, "\n"), stateAbsent, nil
+}
@@ -0,0 +1,479 @@
+package fileops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+	"xgit/apps/patch/preflight"
+)
+
+// json.set / json.delete / json.merge / json.append —— 按 JSON Pointer（RFC 6901，如 /scripts/build）结构化修改 JSON 文件
+//   - pointer=：目标位置；json.merge 缺省为根（""）
+//   - value= 或正文：要写入的 JSON 值（字符串需带引号）
+//   - create=（默认 true）：路径不存在时自动创建中间对象；create=false 时路径不存在即报错
+//   - 只改动目标值所在的字节区间：键顺序、缩进风格与其余内容原样保留；新插入的内容按文件已有缩进排版
+//   - json.merge：把对象值逐键深度合并到目标对象（值为 null 表示删除该键）
+//   - json.append：向数组末尾追加一个元素（已含相同元素视为已应用）
+func JSONEdit(op, repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	raw, ok := args["pointer"]
+	if !ok && op != "json.merge" {
+		return fmt.Errorf("%s: 缺少 pointer=（JSON Pointer，如 /scripts/build）", op)
+	}
+	ptr := strings.TrimSpace(raw)
+	toks, err := parseJSONPointer(ptr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	var val []byte
+	if op != "json.delete" {
+		v := strings.TrimSpace(args["value"])
+		if v == "" {
+			v = strings.TrimSpace(normalizeLF(body))
+		}
+		if v == "" {
+			return fmt.Errorf("%s: 缺少要写入的值（value= 或正文）", op)
+		}
+		if _, err := preflight.ParseJSONDoc([]byte(v), false); err != nil {
+			return fmt.Errorf("%s: 值不是合法 JSON（字符串需带引号）：%v", op, err)
+		}
+		val = []byte(v)
+	}
+
+	abs := filepath.Join(repo, rel)
+	text, ff, err := readText(abs)
+	if err != nil {
+		return err
+	}
+	d, err := newJSONDoc(text, preflight.JSONIndent(repo, rel, []byte(text)), preflight.IsJSONC(repo, rel))
+	if err != nil {
+		return fmt.Errorf("%s: %s 不是合法 JSON：%v", op, rel, err)
+	}
+	e := &jsonEditor{doc: d, op: op, create: !argOff(args, "create")}
+	where := rel + " " + ptrLabel(ptr)
+
+	var st applyState
+	switch op {
+	case "json.set":
+		st, err = e.set(toks, val)
+	case "json.delete":
+		st, err = e.delete(toks)
+	case "json.merge":
+		st, err = e.merge(toks, val)
+	case "json.append":
+		st, err = e.append(toks, val)
+	default:
+		return errors.New("未知指令: " + op)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s %w", op, where, err)
+	}
+	if string(d.src) == text {
+		if skip, err := idemSkip(op, where, stateApplied, args, logger); err != nil || skip {
+			return err
+		}
+		return nil
+	}
+	if st == stateApplied {
+		if skip, err := idemSkip(op, where, st, args, logger); err != nil || skip {
+			return err
+		}
+	}
+	if err := writeText(abs, string(d.src), ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ %s: %s", op, where)
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+func ptrLabel(ptr string) string {
+	if ptr == "" {
+		return "（根）"
+	}
+	return ptr
+}
+
+// argOff 参数是否显式关闭（缺省视为开启）
+func argOff(args map[string]string, key string) bool {
+	switch strings.ToLower(strings.TrimSpace(args[key])) {
+	case "0", "false", "no", "off":
+		return true
+	}
+	return false
+}
+
+// parseJSONPointer 拆分 JSON Pointer（~1 → /，~0 → ~）；"" 表示根
+func parseJSONPointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("JSON Pointer 必须以 / 开头：%q", s)
+	}
+	toks := strings.Split(s[1:], "/")
+	for i, t := range toks {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 >= len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+				return nil, fmt.Errorf("JSON Pointer 中 ~ 只能写作 ~0 或 ~1：%q", s)
+			}
+		}
+		toks[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return toks, nil
+}
+
+// jsonDoc 正在编辑的文档：每次改动后重新解析，保证节点区间与文本一致
+type jsonDoc struct {
+	src      []byte
+	root     *preflight.JSONNode
+	unit     string // 缩进单位（文件现有缩进 → .editorconfig → 2 空格）
+	indented bool   // 文件中是否已有缩进行（否则视为紧凑写法）
+	jsonc    bool
+}
+
+func newJSONDoc(text, unit string, jsonc bool) (*jsonDoc, error) {
+	d := &jsonDoc{src: []byte(text), unit: unit, jsonc: jsonc}
+	if err := d.reparse(); err != nil {
+		return nil, err
+	}
+	d.indented = preflight.DetectIndent(d.src) != ""
+	return d, nil
+}
+
+func (d *jsonDoc) reparse() error {
+	root, err := preflight.ParseJSONDoc(d.src, d.jsonc)
+	if err != nil {
+		return err
+	}
+	d.root = root
+	return nil
+}
+
+// splice 用 text 替换 [from, to)
+func (d *jsonDoc) splice(from, to int, text string) error {
+	d.src = append(append(append([]byte(nil), d.src[:from]...), text...), d.src[to:]...)
+	return d.reparse()
+}
+
+// multiline 容器是否跨行排版（决定新内容用多行还是单行格式）
+func (d *jsonDoc) multiline(n *preflight.JSONNode) bool {
+	if bytes.IndexByte(d.src[n.Start:n.End], '\n') >= 0 {
+		return true
+	}
+	return len(n.Members)+len(n.Elems) == 0 && d.indented
+}
+
+// trailingCommaEOL 位置 end 之后紧跟末尾逗号、且该行余下只有空白或 // 注释时，返回行尾位置
+func (d *jsonDoc) trailingCommaEOL(end int) (int, bool) {
+	if !d.jsonc {
+		return 0, false
+	}
+	i := end
+	for i < len(d.src) && (d.src[i] == ' ' || d.src[i] == '\t') {
+		i++
+	}
+	if i >= len(d.src) || d.src[i] != ',' {
+		return 0, false
+	}
+	eol := bytes.IndexByte(d.src[i:], '\n')
+	if eol < 0 {
+		return 0, false
+	}
+	eol += i
+	rest := strings.TrimSpace(string(d.src[i+1 : eol]))
+	if rest != "" && !strings.HasPrefix(rest, "//") {
+		return 0, false
+	}
+	return eol, true
+}
+
+// render 把独立的 JSON 值文本按容器 c 的排版风格输出（indent 为所在行缩进）
+func (d *jsonDoc) render(val []byte, c *preflight.JSONNode, indent string) string {
+	n, _ := preflight.ParseJSONDoc(val, false)
+	if c != nil && !d.multiline(c) {
+		return preflight.RenderJSON(val, n, "", "")
+	}
+	return preflight.RenderJSON(val, n, indent, d.unit)
+}
+
+// walk 沿 pointer 下行，返回途经的节点（nodes[0] 为根）；遇到缺失的成员即停止
+func (d *jsonDoc) walk(toks []string) ([]*preflight.JSONNode, error) {
+	nodes := []*preflight.JSONNode{d.root}
+	for i, t := range toks {
+		cur := nodes[len(nodes)-1]
+		var next *preflight.JSONNode
+		switch cur.Kind {
+		case preflight.JSONObject:
+			if _, m := cur.Member(t); m != nil {
+				next = m.Value
+			}
+		case preflight.JSONArray:
+			idx, err := arrayIndex(t, len(cur.Elems))
+			if err != nil {
+				return nil, fmt.Errorf("%s：%v", pointerOf(toks[:i+1]), err)
+			}
+			if idx < len(cur.Elems) {
+				next = cur.Elems[idx]
+			}
+		default:
+			return nil, fmt.Errorf("%s 不是对象或数组，无法继续下行", ptrLabel(pointerOf(toks[:i])))
+		}
+		if next == nil {
+			break
+		}
+		nodes = append(nodes, next)
+	}
+	return nodes, nil
+}
+
+// arrayIndex 数组下标：十进制（无前导 0）或 "-"（末尾之后）；只允许到 n（即追加位置）
+func arrayIndex(t string, n int) (int, error) {
+	if t == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || (len(t) > 1 && t[0] == '0') || t[0] == '+' {
+		return 0, fmt.Errorf("数组下标无效：%q", t)
+	}
+	if i > n {
+		return 0, fmt.Errorf("数组下标 %d 越界（长度 %d）", i, n)
+	}
+	return i, nil
+}
+
+// pointerOf 由路径片段还原 JSON Pointer
+func pointerOf(toks []string) string {
+	var sb strings.Builder
+	for _, t := range toks {
+		sb.WriteString("/" + strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
+
+func quoteJSONKey(k string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(k)
+	return strings.TrimSpace(buf.String())
+}
+
+// jsonEditor 四个指令的实现；每步改动都直接作用在 doc 上
+type jsonEditor struct {
+	doc    *jsonDoc
+	op     string
+	create bool
+}
+
+// set 写入值：已存在则替换该值，不存在则（create）在最近的已有容器中补齐路径
+func (e *jsonEditor) set(toks []string, val []byte) (applyState, error) {
+	d := e.doc
+	nodes, err := d.walk(toks)
+	if err != nil {
+		return stateAbsent, err
+	}
+	if len(nodes) == len(toks)+1 {
+		n := nodes[len(nodes)-1]
+		vn, _ := preflight.ParseJSONDoc(val, false)
+		if preflight.JSONEqual(d.src, n, val, vn) {
+			return stateApplied, nil
+		}
+		var parent *preflight.JSONNode
+		if len(nodes) > 1 {
+			parent = nodes[len(nodes)-2]
+		}
+		return stateAbsent, d.splice(n.Start, n.End, d.render(val, parent, preflight.LineIndent(d.src, n.Start)))
+	}
+	if !e.create {
+		return stateAbsent, fmt.Errorf("路径不存在（create=false）：%s", pointerOf(toks[:len(nodes)]))
+	}
+	// 缺失的部分由内向外包成嵌套值，整体插入最近的已有容器
+	rest := toks[len(nodes)-1:]
+	v := string(val)
+	for i := len(rest) - 1; i >= 1; i-- {
+		if rest[i] == "-" || rest[i] == "0" {
+			v = "[" + v + "]"
+		} else {
+			v = "{" + quoteJSONKey(rest[i]) + ": " + v + "}"
+		}
+	}
+	return stateAbsent, e.insert(nodes[len(nodes)-1], rest[0], []byte(v))
+}
+
+// insert 在容器 c 末尾插入成员（对象）或元素（数组）
+func (e *jsonEditor) insert(c *preflight.JSONNode, key string, val []byte) error {
+	d := e.doc
+	prefix := ""
+	var last int
+	count := 0
+	switch c.Kind {
+	case preflight.JSONObject:
+		prefix = quoteJSONKey(key) + ": "
+		if count = len(c.Members); count > 0 {
+			last = c.Members[count-1].Value.End
+		}
+	case preflight.JSONArray:
+		if idx, err := arrayIndex(key, len(c.Elems)); err != nil || idx != len(c.Elems) {
+			return fmt.Errorf("数组中只能追加元素（下标 - 或 %d），得到 %q", len(c.Elems), key)
+		}
+		if count = len(c.Elems); count > 0 {
+			last = c.Elems[count-1].End
+		}
+	default:
+		return errors.New("父节点不是对象或数组")
+	}
+
+	if !d.multiline(c) {
+		item := prefix + d.render(val, c, "")
+		if count > 0 {
+			return d.splice(last, last, ", "+item)
+		}
+		return d.splice(c.Start+1, c.End-1, item)
+	}
+	if count > 0 {
+		// 与最后一个成员对齐
+		var ind string
+		if c.Kind == preflight.JSONObject {
+			ind = preflight.LineIndent(d.src, c.Members[count-1].KeyStart)
+		} else {
+			ind = preflight.LineIndent(d.src, c.Elems[count-1].Start)
+		}
+		// JSONC 末尾逗号：新成员另起一行并沿用末尾逗号，原成员的行尾注释留在原行
+		if at, ok := d.trailingCommaEOL(last); ok {
+			return d.splice(at, at, "\n"+ind+prefix+d.render(val, c, ind)+",")
+		}
+		return d.splice(last, last, ",\n"+ind+prefix+d.render(val, c, ind))
+	}
+	outer := preflight.LineIndent(d.src, c.Start)
+	ind := outer + d.unit
+	return d.splice(c.Start+1, c.End-1, "\n"+ind+prefix+d.render(val, c, ind)+"\n"+outer)
+}
+
+// delete 删除成员或元素（连同相邻的逗号）；路径不存在视为已应用
+func (e *jsonEditor) delete(toks []string) (applyState, error) {
+	d := e.doc
+	if len(toks) == 0 {
+		return stateAbsent, errors.New("不能删除根节点")
+	}
+	nodes, err := d.walk(toks)
+	if err != nil {
+		return stateAbsent, err
+	}
+	if len(nodes) != len(toks)+1 {
+		return stateApplied, nil
+	}
+	c := nodes[len(nodes)-2]
+	type span struct{ start, end int }
+	var items []span
+	idx := -1
+	if c.Kind == preflight.JSONObject {
+		for _, m := range c.Members {
+			items = append(items, span{m.KeyStart, m.Value.End})
+		}
+		idx, _ = c.Member(toks[len(toks)-1])
+	} else {
+		for _, el := range c.Elems {
+			items = append(items, span{el.Start, el.End})
+		}
+		idx, _ = arrayIndex(toks[len(toks)-1], len(c.Elems))
+	}
+	switch {
+	case len(items) == 1:
+		return stateAbsent, d.splice(c.Start+1, c.End-1, "")
+	case idx > 0:
+		return stateAbsent, d.splice(items[idx-1].end, items[idx].end, "")
+	default:
+		return stateAbsent, d.splice(items[0].start, items[1].start, "")
+	}
+}
+
+// merge 逐键深度合并：双方都是对象时递归，null 删除，其余覆盖
+func (e *jsonEditor) merge(toks []string, val []byte) (applyState, error) {
+	patch, _ := preflight.ParseJSONDoc(val, false)
+	if patch.Kind != preflight.JSONObject {
+		return stateAbsent, errors.New("合并的值必须是对象")
+	}
+	nodes, err := e.doc.walk(toks)
+	if err != nil {
+		return stateAbsent, err
+	}
+	if len(nodes) != len(toks)+1 {
+		if !e.create {
+			return stateAbsent, fmt.Errorf("路径不存在（create=false）：%s", pointerOf(toks[:len(nodes)]))
+		}
+		// 先建空对象再逐键合并（null 成员不落盘）
+		if _, err := e.set(toks, []byte("{}")); err != nil {
+			return stateAbsent, err
+		}
+	} else if nodes[len(nodes)-1].Kind != preflight.JSONObject {
+		return stateAbsent, errors.New("目标不是对象，无法合并")
+	}
+	for _, m := range patch.Members {
+		sub := append(append([]string(nil), toks...), m.Key)
+		mv := val[m.Value.Start:m.Value.End]
+		var err error
+		switch {
+		case m.Value.Kind == preflight.JSONNull:
+			_, err = e.delete(sub)
+		case m.Value.Kind == preflight.JSONObject && (e.isObject(sub) || !e.exists(sub)):
+			create := e.create
+			e.create = true
+			_, err = e.merge(sub, mv)
+			e.create = create
+		default:
+			create := e.create
+			e.create = true // 目标对象已存在：新键直接插入
+			_, err = e.set(sub, mv)
+			e.create = create
+		}
+		if err != nil {
+			return stateAbsent, err
+		}
+	}
+	return stateAbsent, nil
+}
+
+func (e *jsonEditor) exists(toks []string) bool {
+	nodes, err := e.doc.walk(toks)
+	return err == nil && len(nodes) == len(toks)+1
+}
+
+func (e *jsonEditor) isObject(toks []string) bool {
+	nodes, err := e.doc.walk(toks)
+	return err == nil && len(nodes) == len(toks)+1 && nodes[len(nodes)-1].Kind == preflight.JSONObject
+}
+
+// append 向数组追加元素；数组已含相同元素视为已应用
+func (e *jsonEditor) append(toks []string, val []byte) (applyState, error) {
+	d := e.doc
+	nodes, err := d.walk(toks)
+	if err != nil {
+		return stateAbsent, err
+	}
+	if len(nodes) != len(toks)+1 {
+		if !e.create {
+			return stateAbsent, fmt.Errorf("路径不存在（create=false）：%s", pointerOf(toks[:len(nodes)]))
+		}
+		return e.set(toks, []byte("["+string(val)+"]"))
+	}
+	arr := nodes[len(nodes)-1]
+	if arr.Kind != preflight.JSONArray {
+		return stateAbsent, errors.New("目标不是数组")
+	}
+	vn, _ := preflight.ParseJSONDoc(val, false)
+	st := stateAbsent
+	for _, el := range arr.Elems {
+		if preflight.JSONEqual(d.src, el, val, vn) {
+			st = stateApplied
+			break
+		}
+	}
+	return st, e.insert(arr, "-", val)
+}
@@ -0,0 +1,109 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// json.* 之后的预检不能把未触及的部分整体重排
+func TestJSONSetKeepsUnrelatedFormatting(t *testing.T) {
+	repo := t.TempDir()
+	src := "{\n  \"name\": \"old\",\n  \"files\": [\"a\", \"b\",\n    \"c\"],\n  \"scripts\": {\"build\":\"go build\"},\n  \"a\":1\n}\n"
+	if err := os.WriteFile(filepath.Join(repo, "package.json"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := JSONEdit("json.set", repo, "package.json", "", map[string]string{"pointer": "/name", "value": `"new"`}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"name\": \"new\",\n  \"files\": [\"a\", \"b\",\n    \"c\"],\n  \"scripts\": {\"build\":\"go build\"},\n  \"a\":1\n}\n"
+	if got := readTestFile(t, repo, "package.json"); got != want {
+		t.Fatalf("内容 =\n%s\n期望 =\n%s", got, want)
+	}
+}
+
+func TestJSONEdit(t *testing.T) {
+	cases := []struct {
+		name, op, rel, src string
+		args               map[string]string
+		want               string
+	}{
+		{"~1 转义为 /", "json.set", "a.json", "{\n  \"a/b\": 1\n}\n",
+			map[string]string{"pointer": "/a~1b", "value": "2"}, "{\n  \"a/b\": 2\n}\n"},
+		{"~0 转义为 ~", "json.set", "a.json", "{\n  \"m~n\": 1,\n  \"m~1n\": 1\n}\n",
+			map[string]string{"pointer": "/m~0n", "value": "2"}, "{\n  \"m~n\": 2,\n  \"m~1n\": 1\n}\n"},
+		{"~01 先解 ~1 再解 ~0", "json.set", "a.json", "{\n  \"m~n\": 1,\n  \"m~1n\": 1\n}\n",
+			map[string]string{"pointer": "/m~01n", "value": "2"}, "{\n  \"m~n\": 1,\n  \"m~1n\": 2\n}\n"},
+		{"新键名按 JSON 转义", "json.set", "a.json", "{\n  \"a\": 1\n}\n",
+			map[string]string{"pointer": "/x~1\"y", "value": "2"}, "{\n  \"a\": 1,\n  \"x/\\\"y\": 2\n}\n"},
+		{"补齐嵌套路径", "json.set", "a.json", "{\n  \"a\": 1\n}\n",
+			map[string]string{"pointer": "/b/c", "value": "true"}, "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": true\n  }\n}\n"},
+		{"JSONC 保留注释", "json.set", "a.jsonc", "{\n  // 名称\n  \"name\": \"a\", /* 行尾 */\n  \"v\": 1,\n}\n",
+			map[string]string{"pointer": "/name", "value": `"b"`}, "{\n  // 名称\n  \"name\": \"b\", /* 行尾 */\n  \"v\": 1,\n}\n"},
+		{"JSONC 末尾逗号后追加", "json.set", "a.jsonc", "{\n  \"a\": 1, // c\n}\n",
+			map[string]string{"pointer": "/b", "value": "2"}, "{\n  \"a\": 1, // c\n  \"b\": 2,\n}\n"},
+		{"JSONC 删除末尾成员", "json.delete", "a.jsonc", "{\n  // c\n  \"a\": 1,\n  \"b\": 2,\n}\n",
+			map[string]string{"pointer": "/b"}, "{\n  // c\n  \"a\": 1,\n}\n"},
+		{"删除首个成员", "json.delete", "a.json", "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3\n}\n",
+			map[string]string{"pointer": "/a"}, "{\n  \"b\": 2,\n  \"c\": 3\n}\n"},
+		{"删除中间成员", "json.delete", "a.json", "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3\n}\n",
+			map[string]string{"pointer": "/b"}, "{\n  \"a\": 1,\n  \"c\": 3\n}\n"},
+		{"删除末尾成员", "json.delete", "a.json", "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3\n}\n",
+			map[string]string{"pointer": "/c"}, "{\n  \"a\": 1,\n  \"b\": 2\n}\n"},
+		{"删除唯一成员", "json.delete", "a.json", "{\n  \"a\": 1\n}\n",
+			map[string]string{"pointer": "/a"}, "{}\n"},
+		{"删除紧凑写法中的成员", "json.delete", "a.json", "{\"a\": 1, \"b\": 2, \"c\": 3}\n",
+			map[string]string{"pointer": "/b"}, "{\"a\": 1, \"c\": 3}\n"},
+		{"删除数组元素", "json.delete", "a.json", "[1, 2, 3]\n",
+			map[string]string{"pointer": "/0"}, "[2, 3]\n"},
+		{"删除不存在的键视为已应用", "json.delete", "a.json", "{\"a\": 1}\n",
+			map[string]string{"pointer": "/z"}, "{\"a\": 1}\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{c.rel: c.src})
+			if err := JSONEdit(c.op, repo, c.rel, "", c.args, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, repo, c.rel); got != c.want {
+				t.Fatalf("内容 = %q\n期望 = %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestJSONEditErrors(t *testing.T) {
+	cases := []struct {
+		name, op, rel, src string
+		args               map[string]string
+		want               string
+	}{
+		{"create=false 不补齐", "json.set", "a.json", "{\"a\": {}}\n",
+			map[string]string{"pointer": "/a/b/c", "value": "1", "create": "false"}, "路径不存在（create=false）：/a"},
+		{"非法 ~ 转义", "json.set", "a.json", "{}\n",
+			map[string]string{"pointer": "/a~2", "value": "1"}, "~0 或 ~1"},
+		{"缺少前导 /", "json.set", "a.json", "{}\n",
+			map[string]string{"pointer": "a", "value": "1"}, "必须以 / 开头"},
+		{"值不是 JSON", "json.set", "a.json", "{}\n",
+			map[string]string{"pointer": "/a", "value": "bare"}, "不是合法 JSON"},
+		{"严格 JSON 不接受注释", "json.set", "a.json", "{\n  // c\n  \"a\": 1\n}\n",
+			map[string]string{"pointer": "/a", "value": "2"}, "a.json 不是合法 JSON"},
+		{"严格 JSON 不接受末尾逗号", "json.set", "a.json", "{\"a\": 1,}\n",
+			map[string]string{"pointer": "/a", "value": "2"}, "a.json 不是合法 JSON"},
+		{"不能删除根", "json.delete", "a.json", "{}\n",
+			map[string]string{"pointer": ""}, "不能删除根节点"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{c.rel: c.src})
+			err := JSONEdit(c.op, repo, c.rel, "", c.args, nil, nil)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("err = %v，期望包含 %q", err, c.want)
+			}
+			if got := readTestFile(t, repo, c.rel); got != c.src {
+				t.Fatalf("报错时不应改动文件：%q", got)
+			}
+		})
+	}
+}
@@ -1,61 +1,37 @@
 package fileops
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
-)
-
-//
-// 外部依赖（由上层注入/同包提供）：
-//
-
-// RunGit 可选注入：若不为 nil，则用于 stage 文件
-var RunGit func(repo string, logger DualLogger, args ...string) (string, error)
 
-// PreflightOne 可选注入：若不为 nil，则用于对单文件做预检
-var PreflightOne func(repo, rel string, logger DualLogger) error
+	"xgit/apps/patch/gitops"
+)
 
 //
 // 公共小工具
 //
 
-// 读为“行模型”：每个元素末尾尽量带 '\n'
-func readLines(abs string) ([]string, error) {
-	data, err := os.ReadFile(abs)
+// 读为“行模型”：每个元素末尾都带 '\n'；CRLF/BOM/末行无换行等外观记录在 fileFormat 中，写回时恢复
+func readLines(abs string) ([]string, fileFormat, error) {
+	s, ff, err := readText(abs)
 	if err != nil {
-		return nil, err
-	}
-	sc := bufio.NewScanner(strings.NewReader(string(data)))
-	sc.Split(bufio.ScanLines)
-	var lines []string
-	for sc.Scan() {
-		lines = append(lines, sc.Text()+"\n")
-	}
-	// 如果文件非空且末尾无 \n，则把最后一行视为“无换行行”
-	if len(data) > 0 && data[len(data)-1] != '\n' {
-		if len(lines) == 0 {
-			lines = []string{""}
-		} else {
-			lines[len(lines)-1] = strings.TrimRight(lines[len(lines)-1], "\n")
-		}
+		return nil, ff, err
+	}
+	lines := splitKeepNL(s)
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
 	}
-	return lines, nil
+	return lines, ff, nil
 }
 
-// 写回（保持 0644；自动建父目录）
-func writeLines(abs string, lines []string) error {
-	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
-		return err
-	}
+// 写回（原子写入；保留权限、换行风格、BOM 与末尾换行状态；自动建父目录）
+func writeLines(abs string, lines []string, ff fileFormat) error {
 	var sb strings.Builder
 	for _, l := range lines {
 		sb.WriteString(l)
 	}
-	return os.WriteFile(abs, []byte(sb.String()), 0o644)
+	return writeText(abs, applyFinalNL(sb.String(), ff), ff)
 }
 
 func ensureTrailingNL(lines []string) []string {
@@ -117,6 +93,13 @@ func splitPayload(body string) []string {
 	return out
 }
 
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func parseInt(s string) int {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -170,10 +153,10 @@ func explodeKeys(v string) []string {
 	return parts
 }
 
-// 在 [from..] 范围内做“宽松唯一命中”：
+// 在 [from..to] 范围内做“宽松唯一命中”（to ≤ 0 或超出末行 → 到 EOF）：
 // 规则：忽略大小写、忽略行首缩进；先尝试“任一 key 唯一命中”；若均不唯一，再尝试“两个 key AND”；再尝试“全部 AND”。
 // 返回：绝对行号(1-based)。若多于 1 且 nth>0 则选第 nth；否则报错。
-func pickUniqueLoose(lines []string, keys []string, from int, nth int) (int, []int, error) {
+func pickUniqueLoose(lines []string, keys []string, from, to int, nth int) (int, []int, error) {
 	norm := func(s string) string {
 		return strings.ToLower(strings.TrimLeft(s, " \t"))
 	}
@@ -184,6 +167,9 @@ func pickUniqueLoose(lines []string, keys []string, from int, nth int) (int, []i
 	if from > N {
 		return 0, nil, errors.New("起点超界")
 	}
+	if to > 0 && to < N {
+		N = to
+	}
 	L := make([]string, N)
 	for i := 0; i < N; i++ {
 		L[i] = norm(lines[i])
@@ -268,35 +254,86 @@ OUTER:
 
 type scope struct{ start, end int } // [start..end] 闭区间，1-based；end==len(lines) 表示到 EOF
 
-// 解析作用域。无 start-keys → 全文。end-keys 缺省 → 到 EOF。
+// 解析作用域。无 start-keys/start-context → 全文。end-keys/end-context 缺省 → 到 EOF。
 // start 需要唯一命中；若多处则用 nthb 选择。
 // end 在 start 之后搜索；若多处取“第一处”。
-func resolveScope(lines []string, args map[string]string) (scope, error) {
+// start-context 以片段首行为起点，end-context 以片段末行为终点（fuzzy=true 时允许模糊定位）。
+// end=auto：按语言识别起始行所在构造的结束行（见 blockend.go）。
+// scope=func:Name 等：Go 语义作用域（见 goscope.go），与 start-*/end-* 互斥。
+func resolveScope(rel string, lines []string, args map[string]string, logger DualLogger) (scope, error) {
 	N := len(lines)
 	full := scope{start: 1, end: N}
 
+	if sel := strings.TrimSpace(args["scope"]); sel != "" {
+		for _, k := range []string{"start-keys", "start-context", "end-keys", "end-context", "end"} {
+			if strings.TrimSpace(args[k]) != "" {
+				return scope{}, fmt.Errorf("scope= 不能与 %s 同时使用", k)
+			}
+		}
+		return resolveGoScope(rel, lines, sel, parseInt(args["nthb"]))
+	}
+
 	startKeys := strings.TrimSpace(args["start-keys"])
-	if startKeys == "" {
-		return full, nil
+	startCtx := args["start-context"]
+	if strings.TrimSpace(args["end"]) != "" && !strings.EqualFold(strings.TrimSpace(args["end"]), "auto") {
+		return scope{}, fmt.Errorf("未知 end=%q（仅支持 auto）", args["end"])
+	}
+	if startKeys != "" && strings.TrimSpace(startCtx) != "" {
+		return scope{}, errors.New("start-keys 与 start-context 不能同时使用")
 	}
-	keysS := explodeKeys(startKeys)
 	nthb := parseInt(args["nthb"])
-	si, _, err := pickUniqueLoose(lines, keysS, 1, nthb)
-	if err != nil {
-		return scope{}, fmt.Errorf("start-keys 定位失败：%v", err)
+	var si int
+	switch {
+	case strings.TrimSpace(startCtx) != "":
+		s, _, err := pickContext(lines, "start-context", startCtx, args, 1, N, nthb, logger)
+		if err != nil {
+			return scope{}, fmt.Errorf("start-context 定位失败：%v", err)
+		}
+		si = s
+	case startKeys != "":
+		s, _, err := pickKeys(lines, startKeys, args, 1, N, nthb)
+		if err != nil {
+			return scope{}, fmt.Errorf("start-keys 定位失败：%v", err)
+		}
+		si = s
+	default:
+		if strings.TrimSpace(args["end"]) != "" {
+			return scope{}, errors.New("end=auto 需要 start-keys 或 start-context")
+		}
+		return full, nil
 	}
 
 	endKeys := strings.TrimSpace(args["end-keys"])
-	if endKeys == "" {
+	endCtx := args["end-context"]
+	endAuto := strings.EqualFold(strings.TrimSpace(args["end"]), "auto")
+	if n := btoi(endKeys != "") + btoi(strings.TrimSpace(endCtx) != "") + btoi(endAuto); n > 1 {
+		return scope{}, errors.New("end-keys / end-context / end=auto 只能使用其一")
+	}
+	var ei int
+	switch {
+	case endAuto:
+		e, err := autoEnd(rel, lines, si)
+		if err != nil {
+			return scope{}, err
+		}
+		ei = e
+	case strings.TrimSpace(endCtx) != "":
+		// end 片段从 si+1 开始找；允许多处，取第一处；终点为片段末行
+		s, n, err := pickContext(lines, "end-context", endCtx, args, si+1, N, 1, logger)
+		if err != nil {
+			return scope{}, fmt.Errorf("end-context 定位失败：%v", err)
+		}
+		ei = s + n - 1
+	case endKeys != "":
+		// end 从 si+1 开始找；允许多处，取第一处
+		e, _, err := pickKeys(lines, endKeys, args, si+1, N, 1)
+		if err != nil {
+			return scope{}, fmt.Errorf("end-keys 定位失败：%v", err)
+		}
+		ei = e
+	default:
 		return scope{start: si, end: N}, nil
 	}
-	keysE := explodeKeys(endKeys)
-	// end 从 si+1 开始找；允许多处，取第一处
-	ei, list, err := pickUniqueLoose(lines, keysE, si+1, 1)
-	if err != nil {
-		return scope{}, fmt.Errorf("end-keys 定位失败：%v", err)
-	}
-	_ = list // 仅用于调试时查看
 	if ei < si {
 		return scope{}, fmt.Errorf("非法范围：end(%d) < start(%d)", ei, si)
 	}
@@ -309,9 +346,9 @@ func resolveScope(lines []string, args map[string]string) (scope, error) {
 
 // resolveLineInScope: 在作用域内找到目标“基准行”
 // 1) 若提供 lineno → 直接使用“相对作用域”的行号（1-based）
-// 2) 否则用 keys（宽松唯一命中），若多处 → 用 nthl 选择
+// 2) 否则用 context 片段（整体命中，目标行由 context-at 指定）或 keys（按 match= 模式，默认宽松唯一命中），若多处 → 用 nthl 选择
 // 3) 若提供 offset（仅当无作用域时有效），在基准行上做 ± 偏移
-func resolveLineInScope(lines []string, sc scope, args map[string]string) (int, error) {
+func resolveLineInScope(lines []string, sc scope, args map[string]string, logger DualLogger) (int, error) {
 	relLine := parseInt(args["lineno"])
 	keys := strings.TrimSpace(args["keys"])
 	nthl := parseInt(args["nthl"])
@@ -344,20 +381,32 @@ func resolveLineInScope(lines []string, sc scope, args map[string]string) (int,
 		return abs, nil
 	}
 
-	// 2) keys 宽松匹配（仅在作用域内）
-	if keys == "" {
-		return 0, errors.New("缺少 lineno 或 keys")
-	}
-	K := explodeKeys(keys)
-	// 在 [sc.start..sc.end] 内找
-	idx, cands, err := pickUniqueLoose(lines, K, sc.start, nthl)
-	if err != nil {
-		return 0, fmt.Errorf("keys 定位失败：%v", err)
-	}
-	if idx > sc.end { // pickUniqueLoose 可能跨出范围（极端），再兜底
-		return 0, fmt.Errorf("keys 命中行 %d 超出作用域 [%d..%d]", idx, sc.start, sc.end)
+	// 2) context 片段 / keys 匹配（仅在作用域内）
+	var idx int
+	if ctx := args["context"]; strings.TrimSpace(ctx) != "" {
+		if keys != "" {
+			return 0, errors.New("keys 与 context 不能同时使用")
+		}
+		s, n, err := pickContext(lines, "context", ctx, args, sc.start, sc.end, nthl, logger)
+		if err != nil {
+			return 0, fmt.Errorf("context 定位失败：%v", err)
+		}
+		at, err := contextAt(args, n)
+		if err != nil {
+			return 0, err
+		}
+		idx = s + at
+	} else {
+		if keys == "" {
+			return 0, errors.New("缺少 lineno、keys 或 context")
+		}
+		// 在 [sc.start..sc.end] 内找（按 match= 模式）；唯一性与 nth 都只看作用域内的命中
+		k, _, err := pickKeys(lines, keys, args, sc.start, sc.end, nthl)
+		if err != nil {
+			return 0, fmt.Errorf("keys 定位失败：%v", err)
+		}
+		idx = k
 	}
-	_ = cands
 
 	// 3) offset（仅无作用域）
 	if !hasScope && offset != 0 {
@@ -370,17 +419,17 @@ func resolveLineInScope(lines []string, sc scope, args map[string]string) (int,
 	return idx, nil
 }
 
-// stage+预检（若外部注入）
-func stageAndPreflight(repo, rel string, logger DualLogger) error {
-	if RunGit != nil {
-		_, _ = RunGit(repo, logger, "add", "--", rel)
-	}
-	if PreflightOne != nil {
-		if err := PreflightOne(repo, rel, logger); err != nil {
-			if logger != nil {
-				logger.Log("❌ 预检失败：%s (%v)", rel, err)
-			}
-			return err
+// stage+预检（git 由调用方注入；为 nil 时仅预检）
+func stageAndPreflight(repo, rel string, git gitops.GitBackend, logger DualLogger) error {
+	if err := preflightOne(repo, rel, logger); err != nil {
+		if logger != nil {
+			logger.Log("❌ 预检失败：%s (%v)", rel, err)
+		}
+		return err
+	}
+	if git != nil {
+		if err := git.Add(repo, rel); err != nil {
+			return fmt.Errorf("暂存 %s 失败：%w", rel, err)
 		}
 	}
 	return nil
@@ -0,0 +1,38 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+// scope= 限定 keys 的查找范围：作用域外的同名行既不参与唯一性判断，也不会被命中
+func TestKeysBoundedByScope(t *testing.T) {
+	src := "package a\n\nfunc Foo() error {\n\treturn nil\n}\n\nfunc Bar() error {\n\tx := 1\n\t_ = x\n\treturn nil\n}\n"
+	for _, c := range []struct {
+		name string
+		args map[string]string
+		want string
+		err  string
+	}{
+		{"loose", map[string]string{"scope": "func:Foo", "keys": "return nil"}, "\treturn nil // foo\n", ""},
+		{"exact", map[string]string{"scope": "func:Foo", "keys": "\treturn nil", "match": "exact"}, "\treturn nil // foo\n", ""},
+		{"范围外", map[string]string{"scope": "func:Foo", "keys": "x := 1"}, "", "未命中"},
+	} {
+		repo := writeTestTree(t, map[string]string{"a.go": src})
+		err := LineReplace(repo, "a.go", "\treturn nil // foo\n", c.args, nil)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s：err = %v，期望 %s", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s：%v", c.name, err)
+			continue
+		}
+		got := readTestFile(t, repo, "a.go")
+		if !strings.Contains(got, "func Foo() error {\n"+c.want) || strings.Count(got, "// foo") != 1 {
+			t.Errorf("%s：\n%s", c.name, got)
+		}
+	}
+}
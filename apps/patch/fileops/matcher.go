@@ -0,0 +1,169 @@
+package fileops
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//
+// 匹配模式：match=loose|regex|exact|word（作用于 keys / start-keys / end-keys）
+//   - loose（默认）：忽略大小写与缩进的子串匹配，带 OR/AND 回退（见 pickUniqueLoose）
+//   - regex：RE2 正则，逐行匹配（不含换行符）；多行参数每行一条，任一命中即可
+//   - exact：去掉首尾空白后整行相等（区分大小写）
+//   - word：按“整词”出现（两侧不是字母/数字/下划线，区分大小写）
+// 非 loose 模式下 keys 只按换行拆分（| 与 , 在正则/代码里很常见，不再视为分隔符）。
+//
+
+func matchMode(args map[string]string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(args["match"])); m {
+	case "", "loose":
+		return "loose", nil
+	case "regex", "exact", "word":
+		return m, nil
+	default:
+		return "", fmt.Errorf("未知 match=%q（支持 loose|regex|exact|word）", m)
+	}
+}
+
+// lineMatcher 非 loose 模式的单行匹配器
+type lineMatcher struct {
+	mode string
+	keys []string
+	res  []*regexp.Regexp
+}
+
+func newLineMatcher(raw, mode string) (*lineMatcher, error) {
+	m := &lineMatcher{mode: mode}
+	for _, k := range strings.Split(normalizeLF(raw), "\n") {
+		if strings.TrimSpace(k) == "" {
+			continue
+		}
+		if mode == "regex" {
+			re, err := regexp.Compile(k)
+			if err != nil {
+				return nil, fmt.Errorf("正则无效 %q：%v", k, err)
+			}
+			m.res = append(m.res, re)
+		} else {
+			k = strings.TrimSpace(k)
+		}
+		m.keys = append(m.keys, k)
+	}
+	if len(m.keys) == 0 {
+		return nil, fmt.Errorf("缺少 keys")
+	}
+	return m, nil
+}
+
+// match 返回是否命中，以及命中的文本（regex 模式下为捕获组，无捕获组时为整体匹配）
+func (m *lineMatcher) match(line string) (bool, []string) {
+	line = strings.TrimRight(line, "\r\n")
+	switch m.mode {
+	case "regex":
+		for _, re := range m.res {
+			if sm := re.FindStringSubmatch(line); sm != nil {
+				if len(sm) > 1 {
+					return true, sm[1:]
+				}
+				return true, sm[:1]
+			}
+		}
+	case "exact":
+		t := strings.TrimSpace(line)
+		for _, k := range m.keys {
+			if t == k {
+				return true, nil
+			}
+		}
+	case "word":
+		for _, k := range m.keys {
+			if containsWord(line, k) {
+				return true, []string{k}
+			}
+		}
+	}
+	return false, nil
+}
+
+func (m *lineMatcher) String() string {
+	return m.mode + " " + strings.Join(quoteAll(m.keys), " | ")
+}
+
+// containsWord: k 在 s 中以“整词”形式出现
+func containsWord(s, k string) bool {
+	isWord := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for off := 0; ; {
+		i := strings.Index(s[off:], k)
+		if i < 0 {
+			return false
+		}
+		i += off
+		j := i + len(k)
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[j:])
+		if (i == 0 || !isWord(before)) && (j == len(s) || !isWord(after)) {
+			return true
+		}
+		off = i + 1
+	}
+}
+
+// pickKeys 按 args 中的 match= 模式在 [from..to] 内定位唯一行（to ≤ 0 → 到 EOF）；多处命中时用 nth 选择（语义同 pickUniqueLoose）
+func pickKeys(lines []string, raw string, args map[string]string, from, to, nth int) (int, []int, error) {
+	mode, err := matchMode(args)
+	if err != nil {
+		return 0, nil, err
+	}
+	if mode == "loose" {
+		return pickUniqueLoose(lines, explodeKeys(raw), from, to, nth)
+	}
+	m, err := newLineMatcher(raw, mode)
+	if err != nil {
+		return 0, nil, err
+	}
+	if from < 1 {
+		from = 1
+	}
+	if from > len(lines) {
+		return 0, nil, fmt.Errorf("起点超界")
+	}
+	if to <= 0 || to > len(lines) {
+		to = len(lines)
+	}
+	var cands []int
+	var caps []string
+	for i := from - 1; i < to; i++ {
+		if ok, sub := m.match(lines[i]); ok {
+			cands = append(cands, i+1)
+			caps = append(caps, describeHit(i+1, sub))
+		}
+	}
+	switch {
+	case len(cands) == 0:
+		return 0, nil, fmt.Errorf("%s 未命中", m)
+	case len(cands) == 1:
+		return cands[0], cands, nil
+	case nth > 0 && nth <= len(cands):
+		return cands[nth-1], cands, nil
+	}
+	return 0, cands, fmt.Errorf("%s 多处命中 %s（可用 nth=1..%d 选择）", m, strings.Join(caps, " "), len(cands))
+}
+
+// describeHit 生成 "L12" 或 "L12[Foo,bar]" 形式的命中描述
+func describeHit(ln int, sub []string) string {
+	if len(sub) == 0 {
+		return fmt.Sprintf("L%d", ln)
+	}
+	return fmt.Sprintf("L%d[%s]", ln, strings.Join(sub, ","))
+}
+
+func quoteAll(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = fmt.Sprintf("%q", s)
+	}
+	return out
+}
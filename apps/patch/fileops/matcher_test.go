@@ -0,0 +1,52 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPickKeysModes(t *testing.T) {
+	lines := []string{
+		"func Foo() {",
+		"\tfooBar := 1",
+		"\treturn foo",
+		"}",
+		"var x = 42 // id=7",
+	}
+	cases := []struct {
+		name, keys, mode string
+		nth              int
+		want             int
+		err              string
+	}{
+		{"loose 忽略大小写与缩进", "FOOBAR", "", 0, 2, ""},
+		{"loose 单个 key 不唯一时用 AND", "foo|return", "", 0, 3, ""},
+		{"loose 多处命中", "foo", "", 0, 0, "多处命中"},
+		{"loose nth 选择", "foo", "loose", 2, 2, ""},
+		{"exact 整行（去首尾空白）", "return foo", "exact", 0, 3, ""},
+		{"exact 区分大小写", "Return foo", "exact", 0, 0, "未命中"},
+		{"exact 不是子串匹配", "return", "exact", 0, 0, "未命中"},
+		{"word 整词", "foo", "word", 0, 3, ""},
+		{"word 区分大小写", "Foo", "word", 0, 1, ""},
+		{"regex 逐行", `^var \w+ = \d+`, "regex", 0, 5, ""},
+		{"regex 多行参数任一命中", "^}$\n^nothing$", "regex", 0, 4, ""},
+		{"regex | 不是分隔符", `fooBar|return`, "regex", 0, 0, "多处命中 L2[fooBar] L3[return]"},
+		{"regex 捕获组出现在报错中", `(foo)\w*`, "regex", 0, 0, "L2[foo] L3[foo]"},
+		{"regex 无效", `foo(`, "regex", 0, 0, "正则无效"},
+		{"未知模式", "foo", "glob", 0, 0, "未知 match="},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _, err := pickKeys(lines, c.keys, map[string]string{"match": c.mode}, 1, 0, c.nth)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v，期望包含 %q", err, c.err)
+				}
+				return
+			}
+			if err != nil || got != c.want {
+				t.Fatalf("got L%d err=%v，期望 L%d", got, err, c.want)
+			}
+		})
+	}
+}
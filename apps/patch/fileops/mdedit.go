@@ -0,0 +1,411 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+)
+
+// md.section.replace / append / delete / insert —— 按标题路径修改 Markdown 章节
+//   - section=：标题路径，用 " > " 分隔逐级收窄，如 "## 3. 核心指令集 > ### 3.2"；
+//     每段可带 # 限定级别；依次按文字完全相同、前缀（"3.2" 匹配 "3.2 行级指令"）、包含匹配，匹配到多处时报错
+//   - 章节从标题行开始，到下一个同级或更高级的标题之前为止（包含子章节）
+//   - md.section.replace：正文替换章节内容（标题保留；正文首行是同级标题时连标题一起替换）
+//   - md.section.append：正文追加到章节末尾（子章节之后）
+//   - md.section.delete：删除整个章节；不存在视为已应用
+//   - md.section.insert：正文（以标题开头的新章节）插到目标章节之后，position=before 时插到之前
+//
+// md.table.set-row / md.table.delete-row —— 修改章节中的表格行（section= 缺省为全文，table=N 选第 N 张表）
+//   - 按首列的值（key=，set-row 缺省取正文首列）定位行；set-row 不存在时追加到表格末尾
+func MarkdownEdit(op, repo, rel, body string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	text, ff, err := readText(abs)
+	if err != nil {
+		return err
+	}
+	content := strings.Trim(normalizeLF(body), "\n")
+	path := strings.TrimSpace(args["section"])
+	d := &mdDoc{lines: strings.Split(text, "\n")}
+	d.scan()
+	where := rel + " " + path
+
+	var st applyState
+	switch op {
+	case "md.section.replace", "md.section.append", "md.section.delete", "md.section.insert":
+		if path == "" {
+			return fmt.Errorf("%s: 缺少 section=（标题路径，如 ## 3. 核心指令集 > ### 3.2）", op)
+		}
+		st, err = d.section(op, path, content, strings.ToLower(strings.TrimSpace(args["position"])))
+	case "md.table.set-row", "md.table.delete-row":
+		st, err = d.tableRow(op, path, content, args)
+		where = strings.TrimSpace(where + " " + args["key"])
+	default:
+		return errors.New("未知指令: " + op)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s %w", op, where, err)
+	}
+	out := strings.Join(d.lines, "\n")
+	if st == stateApplied || out == text {
+		if skip, err := idemSkip(op, where, stateApplied, args, logger); err != nil || skip {
+			return err
+		}
+		if out == text {
+			return nil
+		}
+	}
+	if err := writeText(abs, out, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ %s: %s", op, where)
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+type mdHeading struct {
+	line  int
+	level int
+	text  string
+}
+
+type mdDoc struct {
+	lines    []string
+	headings []mdHeading
+}
+
+var (
+	mdATX   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?[ \t]*$`)
+	mdFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+)
+
+// parseMDHeading 识别 ATX 标题（# 标题），去掉结尾的 #
+func parseMDHeading(l string) (int, string, bool) {
+	m := mdATX.FindStringSubmatch(l)
+	if m == nil {
+		return 0, "", false
+	}
+	t := strings.TrimRight(m[2], "#")
+	if t != m[2] && t != "" && !strings.HasSuffix(t, " ") && !strings.HasSuffix(t, "\t") {
+		t = m[2] // "C#" 之类：结尾的 # 属于文字
+	}
+	return len(m[1]), strings.TrimSpace(t), true
+}
+
+// scan 收集标题（跳过围栏代码块）
+func (d *mdDoc) scan() {
+	d.headings = nil
+	fence := ""
+	for i, l := range d.lines {
+		if m := mdFence.FindStringSubmatch(l); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(l[len(m[0]):]) == "":
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		if lv, t, ok := parseMDHeading(l); ok {
+			d.headings = append(d.headings, mdHeading{line: i, level: lv, text: t})
+		}
+	}
+}
+
+// end 第 k 个标题所在章节的结束行（下一个同级或更高级标题；否则文件末尾）
+func (d *mdDoc) end(k int) int {
+	for _, h := range d.headings[k+1:] {
+		if h.level <= d.headings[k].level {
+			return h.line
+		}
+	}
+	return contentEnd(d.lines)
+}
+
+var mdThematic = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+
+// trimEnd 去掉 [from, to) 末尾的空行与分隔线（---），它们属于章节之间的分隔
+func (d *mdDoc) trimEnd(from, to int) int {
+	for to > from && (strings.TrimSpace(d.lines[to-1]) == "" || mdThematic.MatchString(d.lines[to-1])) {
+		to--
+	}
+	return to
+}
+
+// find 按标题路径查找，返回标题下标；未找到返回 -1
+func (d *mdDoc) find(path string) (int, error) {
+	from, to := 0, contentEnd(d.lines)
+	k := -1
+	for _, seg := range strings.Split(path, " > ") {
+		seg = strings.TrimSpace(seg)
+		level := 0
+		if lv, t, ok := parseMDHeading(seg); ok {
+			level, seg = lv, t
+		}
+		if seg == "" {
+			return -1, fmt.Errorf("标题路径 %q 中有空段", path)
+		}
+		var exact, prefix, inside []int
+		for i, h := range d.headings {
+			if h.line < from || h.line >= to || level > 0 && h.level != level {
+				continue
+			}
+			switch {
+			case h.text == seg:
+				exact = append(exact, i)
+			case strings.HasPrefix(h.text, seg) && mdBoundary(h.text[len(seg):]):
+				prefix = append(prefix, i)
+			case strings.Contains(h.text, seg):
+				inside = append(inside, i)
+			}
+		}
+		hits := exact
+		if len(hits) == 0 {
+			hits = prefix
+		}
+		if len(hits) == 0 {
+			hits = inside
+		}
+		switch len(hits) {
+		case 0:
+			return -1, nil
+		case 1:
+		default:
+			var at []string
+			for _, i := range hits {
+				at = append(at, strconv.Itoa(d.headings[i].line+1))
+			}
+			return -1, fmt.Errorf("标题 %q 匹配到 %d 处（第 %s 行），请写出上级标题或 # 级别", seg, len(hits), strings.Join(at, "、"))
+		}
+		k = hits[0]
+		from, to = d.headings[k].line+1, d.end(k)
+	}
+	return k, nil
+}
+
+// mdBoundary 前缀匹配须在词边界处结束（"3.2" 不匹配 "3.21"）
+func mdBoundary(rest string) bool {
+	if rest == "" {
+		return true
+	}
+	c := rest[0]
+	return !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_')
+}
+
+func (d *mdDoc) splice(start, del int, insert []string) {
+	d.lines = splice(d.lines, start, del, insert)
+}
+
+func (d *mdDoc) section(op, path, content, pos string) (applyState, error) {
+	k, err := d.find(path)
+	if err != nil {
+		return stateAbsent, err
+	}
+	if k < 0 {
+		if op == "md.section.delete" {
+			return stateApplied, nil
+		}
+		return stateAbsent, errors.New("未找到该章节")
+	}
+	if content == "" && op != "md.section.delete" {
+		return stateAbsent, errors.New("缺少正文")
+	}
+	h := d.headings[k]
+	end := d.end(k)
+	bodyEnd := d.trimEnd(h.line+1, end)
+	var body []string
+	if content != "" {
+		body = strings.Split(content, "\n")
+	}
+
+	switch op {
+	case "md.section.replace":
+		from := h.line + 1
+		if lv, _, ok := parseMDHeading(body[0]); ok && lv == h.level {
+			from = h.line
+		} else if from < bodyEnd && strings.TrimSpace(d.lines[from]) == "" || from == bodyEnd {
+			body = append([]string{""}, body...) // 标题与内容之间空一行
+		}
+		if strings.Join(d.lines[from:bodyEnd], "\n") == strings.Join(body, "\n") {
+			return stateApplied, nil
+		}
+		d.splice(from, bodyEnd-from, body)
+
+	case "md.section.append":
+		if strings.HasSuffix(squash(strings.Join(d.lines[h.line+1:bodyEnd], "\n")), squash(content)) {
+			return stateApplied, nil
+		}
+		if bodyEnd == h.line+1 || !mdContinues(d.lines[bodyEnd-1], body[0]) {
+			body = append([]string{""}, body...)
+		}
+		if bodyEnd == end && end < contentEnd(d.lines) {
+			body = append(body, "") // 与下一个标题之间空一行
+		}
+		d.splice(bodyEnd, 0, body)
+
+	case "md.section.delete":
+		from := h.line
+		if end == contentEnd(d.lines) { // 末尾的章节：连同之前的空行一起去掉
+			for from > 0 && strings.TrimSpace(d.lines[from-1]) == "" {
+				from--
+			}
+		}
+		d.splice(from, end-from, nil)
+
+	case "md.section.insert":
+		lv, title, ok := parseMDHeading(body[0])
+		if !ok {
+			return stateAbsent, errors.New("md.section.insert 的正文须以标题开头（如 ## 新章节）")
+		}
+		// 同一上级范围内已有同名同级标题视为已应用
+		from, to := 0, contentEnd(d.lines)
+		for p := k - 1; p >= 0; p-- {
+			if d.headings[p].level < h.level {
+				from, to = d.headings[p].line, d.end(p)
+				break
+			}
+		}
+		for _, o := range d.headings {
+			if o.line >= from && o.line < to && o.level == lv && o.text == title {
+				return stateApplied, nil
+			}
+		}
+		switch pos {
+		case "", "after":
+			// 章节末尾的空行统一成一个，新章节与下一个标题之间也空一行
+			at := d.trimEnd(h.line, end)
+			ins := append([]string{""}, body...)
+			if end < contentEnd(d.lines) {
+				d.splice(at, end-at, append(ins, ""))
+			} else {
+				d.splice(at, 0, ins)
+			}
+		case "before":
+			d.splice(h.line, 0, append(body, ""))
+		default:
+			return stateAbsent, fmt.Errorf("position=%s 无效（before|after）", pos)
+		}
+	}
+	return stateAbsent, nil
+}
+
+var mdListItem = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s`)
+
+// mdContinues 追加内容能否紧接上一行（同为列表项或表格行时不空行）
+func mdContinues(last, next string) bool {
+	if mdListItem.MatchString(last) && mdListItem.MatchString(next) {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(last), "|") && strings.HasPrefix(strings.TrimSpace(next), "|")
+}
+
+var mdTableSep = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// mdCells 拆分表格行（\| 不作分隔）
+func mdCells(l string) []string {
+	t := strings.TrimSpace(l)
+	t = strings.TrimPrefix(t, "|")
+	if strings.HasSuffix(t, "|") && !strings.HasSuffix(t, "\\|") {
+		t = t[:len(t)-1]
+	}
+	var cells []string
+	start := 0
+	for i := 0; i < len(t); i++ {
+		switch t[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, strings.TrimSpace(t[start:i]))
+			start = i + 1
+		}
+	}
+	return append(cells, strings.TrimSpace(t[start:]))
+}
+
+func (d *mdDoc) tableRow(op, path, content string, args map[string]string) (applyState, error) {
+	from, to := 0, contentEnd(d.lines)
+	if path != "" {
+		k, err := d.find(path)
+		if err != nil {
+			return stateAbsent, err
+		}
+		if k < 0 {
+			return stateAbsent, errors.New("未找到该章节")
+		}
+		from, to = d.headings[k].line+1, d.end(k)
+	}
+	nth := 1
+	if s := strings.TrimSpace(args["table"]); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return stateAbsent, fmt.Errorf("table=%s 无效（从 1 开始的序号）", s)
+		}
+		nth = n
+	}
+	// 表格：表头行 + 分隔行 + 连续的 | 行
+	rows, seen := -1, 0
+	for i := from; i+1 < to; i++ {
+		if strings.HasPrefix(strings.TrimSpace(d.lines[i]), "|") && mdTableSep.MatchString(d.lines[i+1]) && strings.Contains(d.lines[i+1], "-") {
+			if seen++; seen == nth {
+				rows = i + 2
+				break
+			}
+			i++
+		}
+	}
+	if rows < 0 {
+		return stateAbsent, fmt.Errorf("未找到第 %d 张表格", nth)
+	}
+	last := rows
+	for last < to && strings.HasPrefix(strings.TrimSpace(d.lines[last]), "|") {
+		last++
+	}
+
+	key := strings.TrimSpace(args["key"])
+	if op == "md.table.set-row" {
+		if content == "" || strings.Contains(content, "\n") || !strings.HasPrefix(content, "|") {
+			return stateAbsent, errors.New("正文须为一行表格行（| a | b |）")
+		}
+		if key == "" {
+			key = mdCells(content)[0]
+		}
+	} else if key == "" {
+		return stateAbsent, errors.New("缺少 key=（行首列的值）")
+	}
+	var hits []int
+	for i := rows; i < last; i++ {
+		if mdCells(d.lines[i])[0] == key {
+			hits = append(hits, i)
+		}
+	}
+
+	if op == "md.table.delete-row" {
+		if len(hits) == 0 {
+			return stateApplied, nil
+		}
+		for i := len(hits) - 1; i >= 0; i-- {
+			d.splice(hits[i], 1, nil)
+		}
+		return stateAbsent, nil
+	}
+	switch len(hits) {
+	case 0:
+		d.splice(last, 0, []string{content})
+	case 1:
+		if strings.Join(mdCells(d.lines[hits[0]]), "|") == strings.Join(mdCells(content), "|") {
+			return stateApplied, nil
+		}
+		d.lines[hits[0]] = content
+	default:
+		return stateAbsent, fmt.Errorf("首列为 %q 的行有 %d 行，无法确定替换哪一行", key, len(hits))
+	}
+	return stateAbsent, nil
+}
@@ -0,0 +1,106 @@
+package fileops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownFencedHashIsNotHeading(t *testing.T) {
+	cases := []struct {
+		name, src, section, want string
+	}{
+		{"``` 中的 # 行",
+			"# A\n\n```sh\n# 注释\n```\n\na\n\n# B\n\nb\n", "A",
+			"# A\n\nnew\n\n# B\n\nb\n"},
+		{"~~~ 中的 ``` 不结束围栏",
+			"# A\n\n~~~\n```\n# 注释\n~~~\n\n# B\n\nb\n", "A",
+			"# A\n\nnew\n\n# B\n\nb\n"},
+		{"更短的围栏不结束围栏",
+			"# A\n\n````md\n```\n# 注释\n```\n````\n\n# B\n\nb\n", "A",
+			"# A\n\nnew\n\n# B\n\nb\n"},
+		{"带文字的 ``` 行不结束围栏",
+			"# A\n\n```\n``` x\n# 注释\n```\n\n# B\n\nb\n", "A",
+			"# A\n\nnew\n\n# B\n\nb\n"},
+		{"缩进 4 格的 # 不是标题",
+			"# A\n\n    # 代码\n\n# B\n\nb\n", "A",
+			"# A\n\nnew\n\n# B\n\nb\n"},
+		{"#后无空格不是标题",
+			"# A\n\n#tag\n\n# B\n\nb\n", "A",
+			"# A\n\nnew\n\n# B\n\nb\n"},
+		{"结尾的 # 去掉，C# 保留",
+			"## C# ##\n\nx\n\n## D\n", "C#",
+			"## C# ##\n\nnew\n\n## D\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.md": c.src})
+			if err := MarkdownEdit("md.section.replace", repo, "a.md", "new\n", map[string]string{"section": c.section}, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestFile(t, repo, "a.md"); got != c.want {
+				t.Fatalf("内容 = %q\n期望 = %q", got, c.want)
+			}
+		})
+	}
+
+	// 围栏内的 # 行不能作为查找目标
+	repo := writeTestTree(t, map[string]string{"a.md": "# A\n\n```\n# 注释\n```\n"})
+	err := MarkdownEdit("md.section.append", repo, "a.md", "x\n", map[string]string{"section": "注释"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "未找到该章节") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+const mdAmbiguous = "# 安装\n\n## 用法\n\na\n\n# 开发\n\n## 用法\n\nb\n\n## 用法说明\n\nc\n\n### 3.2 行级\n\nd\n\n### 3.21 块级\n\ne\n"
+
+func TestMarkdownHeadingPath(t *testing.T) {
+	cases := []struct {
+		name, section, want string // want 为被替换掉的原正文
+	}{
+		{"上级标题收窄", "安装 > 用法", "a"},
+		{"上级标题收窄到第二处", "开发 > 用法", "b"},
+		{"完全相同优先于前缀", "开发 > ## 用法", "b"},
+		{"前缀匹配", "开发 > 用法说", "c"},
+		{"前缀须在词边界结束", "3.2", "d"},
+		{"带 # 的级别限定", "### 3.21", "e"},
+		{"包含匹配", "块级", "e"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.md": mdAmbiguous})
+			if err := MarkdownEdit("md.section.replace", repo, "a.md", "new\n", map[string]string{"section": c.section}, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			got := readTestFile(t, repo, "a.md")
+			if strings.Contains(got, "\n"+c.want+"\n") || strings.Count(got, "new") != 1 {
+				t.Fatalf("替换了错误的章节：%q", got)
+			}
+		})
+	}
+}
+
+func TestMarkdownHeadingPathErrors(t *testing.T) {
+	cases := []struct {
+		name, section, want string
+	}{
+		{"同名标题有两处", "用法", "匹配到 2 处（第 3、9 行）"},
+		{"级别限定后仍有两处", "## 用法", "匹配到 2 处"},
+		{"前缀匹配到多处", "用", "匹配到 3 处"},
+		{"子标题不存在", "用法说明 > x", "未找到该章节"},
+		{"路径中有空段", "安装 >  > 用法", "空段"},
+		{"上级之外不查找", "安装 > 用法说明", "未找到该章节"},
+		{"级别不符", "### 用法", "未找到该章节"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTestTree(t, map[string]string{"a.md": mdAmbiguous})
+			err := MarkdownEdit("md.section.replace", repo, "a.md", "new\n", map[string]string{"section": c.section}, nil, nil)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("err = %v，期望包含 %q", err, c.want)
+			}
+			if got := readTestFile(t, repo, "a.md"); got != mdAmbiguous {
+				t.Fatalf("报错时不应改动文件：%q", got)
+			}
+		})
+	}
+}
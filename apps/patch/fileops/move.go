@@ -20,6 +20,13 @@ import (
 func FileMove(repo, fromRel, toRel string, logger DualLogger) error {
 	from := filepath.Join(repo, fromRel)
 	to := filepath.Join(repo, toRel)
+	// 幂等：源已不存在且目标已存在 → 视为已移动
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		if _, err := os.Stat(to); err == nil {
+			_, _ = idemSkip("file.move", fromRel+" -> "+toRel, stateApplied, nil, logger)
+			return nil
+		}
+	}
 	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
 		if logger != nil {
 			logger.Log("❌ file.move mkdir 失败：%s -> %s (%v)", fromRel, toRel, err)
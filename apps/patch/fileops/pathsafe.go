@@ -0,0 +1,178 @@
+package fileops
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//
+// 路径安全：所有指令的目标路径都必须落在仓库内
+//   - 拒绝绝对路径、.. 越界、经由符号链接逃出仓库
+//   - 拒绝修改 .git（任意层级，大小写不敏感）、仓库根的 .xgit/（校验闸门与保护列表本身）
+//     与 .xgit/protected 中配置的受保护路径
+//
+
+// ProtectedFile 目标仓库的受保护路径列表（可选）
+//
+// 格式（每行一个模式，# 开头为注释；模式按 path.Match 匹配路径本身或其任一上级目录；
+// 不含 / 的模式匹配任意层级的文件名或目录名）：
+//
+//	deploy/prod.env
+//	secrets/*
+//	*.pem
+const ProtectedFile = ".xgit/protected"
+
+// PathGuard 仓库路径校验器
+type PathGuard struct {
+	repo      string
+	real      string // repo 解析符号链接后的真实路径
+	protected []string
+}
+
+// NewPathGuard 读取 repo/.xgit/protected 构建校验器；列表不存在时只保护 .git 与 .xgit/
+func NewPathGuard(repo string) (*PathGuard, error) {
+	real, err := filepath.EvalSymlinks(repo)
+	if err != nil {
+		return nil, err
+	}
+	g := &PathGuard{repo: repo, real: real}
+	f, err := os.Open(filepath.Join(repo, ProtectedFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return g, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pat := strings.Trim(filepath.ToSlash(line), "/")
+		if _, err := path.Match(pat, ""); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行模式无效：%q", ProtectedFile, n, line)
+		}
+		g.protected = append(g.protected, pat)
+	}
+	return g, sc.Err()
+}
+
+// Resolve 校验相对路径 rel 并返回其绝对路径（不跟随符号链接，指令仍按原路径操作）
+func (g *PathGuard) Resolve(rel string) (string, error) {
+	clean, err := cleanRel(rel)
+	if err != nil {
+		return "", err
+	}
+	if err := g.checkProtected(clean); err != nil {
+		return "", err
+	}
+	real, err := g.realPath(clean)
+	if err != nil {
+		return "", err
+	}
+	if real != clean {
+		if err := g.checkProtected(real); err != nil {
+			return "", fmt.Errorf("经符号链接指向 %s：%w", real, err)
+		}
+	}
+	return filepath.Join(g.repo, filepath.FromSlash(clean)), nil
+}
+
+// cleanRel 规范化为仓库内的 / 分隔相对路径
+func cleanRel(rel string) (string, error) {
+	rel = strings.TrimSpace(rel)
+	switch {
+	case rel == "":
+		return "", errors.New("路径为空")
+	case strings.ContainsRune(rel, 0):
+		return "", errors.New("路径包含 NUL 字符")
+	case filepath.IsAbs(rel) || strings.HasPrefix(rel, "/") || strings.HasPrefix(rel, `\`) || filepath.VolumeName(rel) != "":
+		return "", errors.New("不允许绝对路径")
+	}
+	clean := path.Clean(strings.ReplaceAll(rel, `\`, "/"))
+	switch {
+	case clean == ".":
+		return "", errors.New("不能指向仓库根目录")
+	case clean == ".." || strings.HasPrefix(clean, "../"):
+		return "", errors.New("路径越出仓库")
+	}
+	return clean, nil
+}
+
+// checkProtected .git、.xgit/ 与配置的受保护路径
+func (g *PathGuard) checkProtected(clean string) error {
+	parts := strings.Split(clean, "/")
+	if strings.EqualFold(parts[0], ".xgit") {
+		return errors.New("受保护路径：.xgit/")
+	}
+	for _, p := range parts {
+		if strings.EqualFold(p, ".git") {
+			return errors.New("受保护路径：.git")
+		}
+	}
+	for _, pat := range g.protected {
+		anyLevel := !strings.Contains(pat, "/") // 不含 / 的模式（如 *.pem）匹配任意层级的同名文件或目录
+		for i := range parts {
+			sub := strings.Join(parts[:i+1], "/")
+			if anyLevel {
+				sub = parts[i]
+			}
+			if ok, _ := path.Match(pat, sub); ok {
+				return fmt.Errorf("受保护路径：匹配 %s 中的 %q", ProtectedFile, pat)
+			}
+		}
+	}
+	return nil
+}
+
+// realPath 逐级解析已存在的符号链接，返回真实位置相对仓库的路径；逃出仓库时报错
+func (g *PathGuard) realPath(clean string) (string, error) {
+	cur := g.real
+	parts := strings.Split(clean, "/")
+	for i, p := range parts {
+		next := filepath.Join(cur, p)
+		fi, err := os.Lstat(next)
+		if err != nil {
+			// 不存在（将被创建）：其余部分按字面拼接
+			cur = filepath.Join(append([]string{cur}, parts[i:]...)...)
+			break
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(next)
+			if err != nil { // 悬空链接：按链接内容字面解析
+				link, lerr := os.Readlink(next)
+				if lerr != nil {
+					return "", lerr
+				}
+				if !filepath.IsAbs(link) {
+					link = filepath.Join(cur, link)
+				}
+				target = filepath.Clean(link)
+			}
+			next = target
+		}
+		if !within(g.real, next) {
+			return "", fmt.Errorf("符号链接 %s 指向仓库之外（%s）", strings.Join(parts[:i+1], "/"), next)
+		}
+		cur = next
+	}
+	r, err := filepath.Rel(g.real, cur)
+	if err != nil || !within(g.real, cur) {
+		return "", errors.New("路径越出仓库")
+	}
+	return filepath.ToSlash(r), nil
+}
+
+func within(root, p string) bool {
+	r, err := filepath.Rel(root, p)
+	return err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator))
+}
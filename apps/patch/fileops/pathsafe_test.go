@@ -0,0 +1,79 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathGuardResolve(t *testing.T) {
+	repo := writeTestTree(t, map[string]string{
+		".xgit/protected": "# 受保护\nsecrets/*\n*.pem\ndeploy/prod.env\n",
+		"sub/a.txt":       "a\n",
+		"secrets/k":       "k\n",
+	})
+	outside := t.TempDir()
+	for link, target := range map[string]string{
+		"out":      outside,                   // 逃出仓库
+		"in":       "sub",                     // 仓库内
+		"sec":      "secrets",                 // 指向受保护目录
+		"dangling": "../../nowhere/x",         // 悬空且越界
+		"sub/up":   filepath.Join("..", ".."), // 相对链接向上越界
+	} {
+		if err := os.Symlink(target, filepath.Join(repo, link)); err != nil {
+			t.Skip("不支持符号链接：", err)
+		}
+	}
+	g, err := NewPathGuard(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct{ rel, err string }{
+		{"sub/a.txt", ""},
+		{"sub/new/b.txt", ""},
+		{"./sub/../sub/a.txt", ""},
+		{"in/a.txt", ""},
+		{"deploy/dev.env", ""},
+		{"", "路径为空"},
+		{".", "仓库根目录"},
+		{"/etc/passwd", "绝对路径"},
+		{`\etc\passwd`, "绝对路径"},
+		{"../x", "越出仓库"},
+		{"sub/../../x", "越出仓库"},
+		{`sub\..\..\x`, "越出仓库"},
+		{"out/x", "指向仓库之外"},
+		{"dangling", "指向仓库之外"},
+		{"sub/up/x", "指向仓库之外"},
+		{".git/config", "受保护路径：.git"},
+		{"sub/.GIT/HEAD", "受保护路径：.git"},
+		{".xgit/checks", "受保护路径：.xgit/"},
+		{".XGIT/protected", "受保护路径：.xgit/"},
+		{"secrets/k", "secrets/*"},
+		{"secrets/nested/k", "secrets/*"},
+		{"certs/server.pem", "*.pem"},
+		{"deploy/prod.env", "deploy/prod.env"},
+		{"sec/k", "经符号链接指向 secrets/k"},
+	}
+	for _, c := range cases {
+		abs, err := g.Resolve(c.rel)
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%q：%v", c.rel, err)
+			} else if !strings.HasPrefix(abs, repo) {
+				t.Errorf("%q → %s 不在仓库内", c.rel, abs)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%q：err = %v，期望包含 %q", c.rel, err, c.err)
+		}
+	}
+}
+
+func TestPathGuardRejectsBadPattern(t *testing.T) {
+	repo := writeTestTree(t, map[string]string{".xgit/protected": "ok/*\n[bad\n"})
+	if _, err := NewPathGuard(repo); err == nil || !strings.Contains(err.Error(), "第 2 行") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -19,6 +19,11 @@ func preflightOne(repo, rel string, logger DualLogger) error {
 	return nil
 }
 
+// Preflight 供上层对单文件重新预检（例如三方合并写回之后）
+func Preflight(repo, rel string, logger DualLogger) error {
+	return preflightOne(repo, rel, logger)
+}
+
 // 预检：对 files 中的每个文件选择合适的 Runner 并执行
 func preflightRun(repo string, files []string, logger DualLogger) error {
 	logf := func(format string, a ...any) {
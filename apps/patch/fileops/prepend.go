@@ -17,16 +17,23 @@ import (
 
 // XGIT:BEGIN GO:FUNC_FILE_PREPEND
 // FilePrepend 开头插入 —— 协议: file.prepend
-func FilePrepend(repo, rel string, data []byte, logger DualLogger) error {
+func FilePrepend(repo, rel string, data []byte, args map[string]string, logger DualLogger) error {
 	abs := filepath.Join(repo, rel)
-	_ = os.MkdirAll(filepath.Dir(abs), 0o755)
-	old, _ := os.ReadFile(abs)
-	newContent := []byte(normalizeLF(string(data)))
+	old, ff, err := readText(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	newContent := normalizeLF(string(data))
 	if len(newContent) > 0 && newContent[len(newContent)-1] != '\n' {
-		newContent = append(newContent, '\n')
+		newContent += "\n"
+	}
+	// 幂等：文件开头已是这段内容 → 跳过
+	st := stateAfter(textLines(old), 0, textLines(newContent))
+	if skip, err := idemSkip("file.prepend", rel, st, args, logger); err != nil || skip {
+		return err
 	}
-	newContent = append(newContent, old...)
-	if err := os.WriteFile(abs, newContent, 0o644); err != nil {
+	// 沿用原文件的权限、换行风格与 BOM（BOM 仍位于文件最前）
+	if err := writeText(abs, newContent+old, ff); err != nil {
 		if logger != nil {
 			logger.Log("❌ file.prepend 写入失败：%s (%v)", rel, err)
 		}
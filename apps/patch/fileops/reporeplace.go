@@ -0,0 +1,234 @@
+package fileops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"xgit/apps/patch/gitops"
+)
+
+// repo.replace —— 全仓批量查找替换（大范围重构用）
+//   - 头部路径为可选的子目录（"" 或 "." 表示整个仓库）
+//   - 只处理纳入版本管理的文件与未被 .gitignore 忽略的新文件（git ls-files），按内容嗅探跳过二进制
+//   - include= / exclude=：逗号分隔的通配（见 glob.go）；受保护路径（.xgit/protected）自动跳过
+//   - regex=true：find 为 RE2 正则，with 可引用 $1 / ${name}；默认字面匹配
+//   - expect-total=：全仓命中总数断言（N / + / all，默认 +）；先统计再写入，不符时不做任何修改
+//   - find 零命中：仅 idempotent=on|strict、非正则且 expect-total=N 时，with 在全仓恰好出现 N 次才记为已应用
+//   - preview=true：只输出逐文件命中与替换前后对照，不写文件
+func RepoReplace(repo, dir string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	find, with := normalizeLF(args["find"]), normalizeLF(args["with"])
+	if find == "" {
+		return errors.New("repo.replace: 缺少 find")
+	}
+	if git == nil {
+		return errors.New("repo.replace: 需要 git 后端列出文件")
+	}
+	want := countSpec{any: true}
+	if raw := strings.TrimSpace(args["expect-total"]); raw != "" {
+		var err error
+		if want, err = parseCount(raw); err != nil {
+			return fmt.Errorf("repo.replace: expect-total %w", err)
+		}
+	}
+	var re *regexp.Regexp
+	if argOn(args, "regex") {
+		var err error
+		if re, err = regexp.Compile(find); err != nil {
+			return fmt.Errorf("repo.replace: 正则无效：%v", err)
+		}
+	}
+	files, err := repoReplaceFiles(repo, dir, args, git, logger)
+	if err != nil {
+		return err
+	}
+
+	type edit struct {
+		rel  string
+		ff   fileFormat
+		text string
+		out  string
+		hits [][]int
+	}
+	var edits []edit
+	total, withHits := 0, 0
+	for _, rel := range files {
+		text, ff, err := readText(filepath.Join(repo, filepath.FromSlash(rel)))
+		if err != nil {
+			return fmt.Errorf("repo.replace: 读取 %s 失败：%w", rel, err)
+		}
+		var spans [][]int
+		if re != nil {
+			spans = re.FindAllStringSubmatchIndex(text, -1)
+		} else {
+			spans = findLiteral(text, find)
+		}
+		if len(spans) == 0 {
+			if re == nil && significant([]string{with}) {
+				withHits += len(findLiteral(text, with))
+			}
+			continue
+		}
+		var sb strings.Builder
+		prev := 0
+		for _, sp := range spans {
+			sb.WriteString(text[prev:sp[0]])
+			if re != nil {
+				sb.Write(re.ExpandString(nil, with, text, sp))
+			} else {
+				sb.WriteString(with)
+			}
+			prev = sp[1]
+		}
+		sb.WriteString(text[prev:])
+		if out := sb.String(); out != text {
+			edits = append(edits, edit{rel: rel, ff: ff, text: text, out: out, hits: spans})
+		}
+		total += len(spans)
+	}
+
+	if total == 0 {
+		// 仅在显式幂等、字面匹配且 expect-total 为确切数字时，with 的全仓出现次数恰好等于它才视为已应用
+		st := stateAbsent
+		if re == nil && want.n > 0 && idemExplicit(args) && withHits == want.n {
+			st = stateApplied
+		}
+		if skip, err := idemSkip("repo.replace", dirLabel(dir), st, args, logger); err != nil || skip {
+			return err
+		}
+	}
+	if err := want.check(total); err != nil {
+		return fmt.Errorf("repo.replace: %s %v（%d 个文件）", dirLabel(dir), err, len(edits))
+	}
+	if logger != nil {
+		logger.Log("🔎 repo.replace: %s 扫描 %d 个文件，命中 %d 处 / %d 个文件", dirLabel(dir), len(files), total, len(edits))
+	}
+
+	preview := argOn(args, "preview")
+	for _, e := range edits {
+		if logger != nil {
+			logger.Log("✏️ repo.replace: %s ×%d（%s）", e.rel, len(e.hits), hitLines(e.text, 0, e.hits))
+		}
+		if preview {
+			previewEdit(e.text, e.out, logger)
+			continue
+		}
+		if err := writeText(filepath.Join(repo, filepath.FromSlash(e.rel)), e.out, e.ff); err != nil {
+			return err
+		}
+		if err := stageAndPreflight(repo, e.rel, git, logger); err != nil {
+			return err
+		}
+	}
+	if preview && logger != nil {
+		logger.Log("👀 repo.replace: preview=true，未写入任何文件")
+	}
+	return nil
+}
+
+// repoReplaceFiles 候选文件：git ls-files ∩ 子目录 ∩ include − exclude − 受保护 − 二进制
+func repoReplaceFiles(repo, dir string, args map[string]string, git gitops.GitBackend, logger DualLogger) ([]string, error) {
+	incl, err := CompileGlobs(args["include"])
+	if err != nil {
+		return nil, fmt.Errorf("repo.replace: include %w", err)
+	}
+	excl, err := CompileGlobs(args["exclude"])
+	if err != nil {
+		return nil, fmt.Errorf("repo.replace: exclude %w", err)
+	}
+	guard, err := NewPathGuard(repo)
+	if err != nil {
+		return nil, fmt.Errorf("repo.replace: %w", err)
+	}
+	prefix := ""
+	if d := strings.Trim(path.Clean(filepath.ToSlash(strings.TrimSpace(dir))), "/"); d != "" && d != "." {
+		prefix = d + "/"
+	}
+	all, err := git.LsFiles(repo)
+	if err != nil {
+		return nil, fmt.Errorf("repo.replace: 列出文件失败：%w", err)
+	}
+	var out []string
+	binaries, protected := 0, 0
+	for _, rel := range all {
+		if !strings.HasPrefix(rel, prefix) || (len(incl) > 0 && !MatchAny(incl, rel)) || MatchAny(excl, rel) {
+			continue
+		}
+		abs, err := guard.Resolve(rel)
+		if err != nil {
+			protected++
+			continue
+		}
+		fi, err := os.Lstat(abs)
+		if err != nil || !fi.Mode().IsRegular() { // 已删除 / 符号链接 / 子模块
+			continue
+		}
+		if isBinaryFile(abs) {
+			binaries++
+			continue
+		}
+		out = append(out, rel)
+	}
+	if logger != nil && (binaries > 0 || protected > 0) {
+		logger.Log("ℹ️ repo.replace: 跳过二进制文件 %d 个、受保护路径 %d 个", binaries, protected)
+	}
+	return out, nil
+}
+
+// isBinaryFile 嗅探前 8000 字节：含 NUL 或不是合法 UTF-8 视为二进制
+func isBinaryFile(abs string) bool {
+	f, err := os.Open(abs)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	buf := make([]byte, 8000)
+	n, _ := f.Read(buf)
+	buf = buf[:n]
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return true
+	}
+	// 截断处可能切开多字节字符：去掉末尾不完整的部分再校验
+	for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+		buf = buf[:len(buf)-1]
+	}
+	return !utf8.Valid(buf)
+}
+
+// previewEdit 逐行对照输出替换前后不同的行（每个文件最多 10 行）
+func previewEdit(before, after string, logger DualLogger) {
+	if logger == nil {
+		return
+	}
+	a, b := strings.Split(before, "\n"), strings.Split(after, "\n")
+	if len(a) != len(b) { // 跨行替换：行号无法一一对应，只给出行数变化
+		logger.Log("    （跨行替换：%d → %d 行）", len(a), len(b))
+		return
+	}
+	shown := 0
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		if shown == 10 {
+			logger.Log("    …")
+			return
+		}
+		logger.Log("    L%d - %s", i+1, a[i])
+		logger.Log("    L%d + %s", i+1, b[i])
+		shown++
+	}
+}
+
+func dirLabel(dir string) string {
+	if d := strings.TrimSpace(dir); d != "" && d != "." {
+		return d
+	}
+	return "（全仓）"
+}
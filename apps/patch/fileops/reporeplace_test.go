@@ -0,0 +1,49 @@
+package fileops
+
+import (
+	"testing"
+
+	"xgit/apps/patch/gitops"
+)
+
+func newReplaceRepo(t *testing.T, content string) (string, *gitops.FakeBackend) {
+	t.Helper()
+	repo, rel := writeTestFile(t, content)
+	git := gitops.NewFakeBackend()
+	git.Tracked[repo] = []string{rel}
+	return repo, git
+}
+
+// with 已出现在某处不能掩盖 find 全仓未命中
+func TestRepoReplaceMissingFindFailsLoudly(t *testing.T) {
+	repo, git := newReplaceRepo(t, "NewName()\n")
+	if err := RepoReplace(repo, "", map[string]string{"find": "OldName(", "with": "NewName("}, git, nil); err == nil {
+		t.Fatal("find 全仓未命中应报错")
+	}
+	args := map[string]string{"find": "OldName(", "with": "NewName(", "idempotent": "on"}
+	if err := RepoReplace(repo, "", args, git, nil); err == nil {
+		t.Fatal("没有 expect-total=N 时不能视为已应用")
+	}
+}
+
+func TestRepoReplaceExplicitIdempotentExactTotal(t *testing.T) {
+	repo, git := newReplaceRepo(t, "NewName()\nNewName()\n")
+	args := map[string]string{"find": "OldName(", "with": "NewName(", "idempotent": "on", "expect-total": "2"}
+	if err := RepoReplace(repo, "", args, git, nil); err != nil {
+		t.Fatalf("with 恰好出现 expect-total 次应视为已应用：%v", err)
+	}
+	args["expect-total"] = "3"
+	if err := RepoReplace(repo, "", args, git, nil); err == nil {
+		t.Fatal("with 出现次数与 expect-total 不符应报错")
+	}
+}
+
+func TestRepoReplaceWritesAllHits(t *testing.T) {
+	repo, git := newReplaceRepo(t, "OldName()\nx := OldName()\n")
+	if err := RepoReplace(repo, "", map[string]string{"find": "OldName(", "with": "NewName(", "expect-total": "2"}, git, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, "a.txt"); got != "NewName()\nx := NewName()\n" {
+		t.Fatalf("内容 = %q", got)
+	}
+}
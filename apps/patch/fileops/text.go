@@ -0,0 +1,232 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/gitops"
+)
+
+// text.replace —— 把文件（或 start-keys/end-keys 作用域）内的片段 find 替换为 with
+//   - find< / with<：多行参数（也可写单行 find= / with=；with 为空表示删除）
+//   - regex=true：find 为 RE2 正则，with 可引用 $1 / ${name}；默认按字面匹配
+//   - count=N：必须恰好命中 N 处（默认 1）；count=+：至少 1 处；count=all：全部替换，0 处也不报错
+//     命中数与期望不符时报错并列出命中行，不做任何修改
+//   - fuzzy=true：字面未命中时按整行模糊定位唯一一处（见 fuzzy.go），用 with 替换这些行
+func TextReplace(repo, rel string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	text, ff, err := readText(abs)
+	if err != nil {
+		return err
+	}
+	find, with := normalizeLF(args["find"]), normalizeLF(args["with"])
+	if find == "" {
+		return errors.New("text.replace: 缺少 find")
+	}
+	want, err := parseCount(args["count"])
+	if err != nil {
+		return fmt.Errorf("text.replace: %w", err)
+	}
+	regex := argOn(args, "regex") // 文件已统一为 LF 后匹配，CRLF/BOM 由写入层恢复
+
+	lo, hi, sc, err := scopeSpan(rel, text, args, logger)
+	if err != nil {
+		return fmt.Errorf("text.replace: %w", err)
+	}
+	region := text[lo:hi]
+
+	var re *regexp.Regexp
+	var spans [][]int
+	if regex {
+		if re, err = regexp.Compile(find); err != nil {
+			return fmt.Errorf("text.replace: 正则无效：%v", err)
+		}
+		spans = re.FindAllStringSubmatchIndex(region, -1)
+	} else {
+		spans = findLiteral(region, find)
+	}
+
+	if len(spans) == 0 {
+		// 只在显式要求幂等且断言了确切命中数时，才把“with 恰好出现 N 次”视为已应用
+		if !regex && want.n > 0 && idemExplicit(args) && significant([]string{with}) && len(findLiteral(region, with)) == want.n {
+			if skip, err := idemSkip("text.replace", rel, stateApplied, args, logger); err != nil || skip {
+				return err
+			}
+		}
+		if fuzzyOn(args) && !want.all {
+			if regex {
+				return errors.New("text.replace: fuzzy 不能与 regex=true 同时使用")
+			}
+			if want.n > 1 {
+				return errors.New("text.replace: fuzzy 仅支持替换单处（count=1 或 +）")
+			}
+			return textReplaceFuzzy(repo, rel, text, ff, sc, find, with, args, git, logger)
+		}
+	}
+	if err := want.check(len(spans)); err != nil {
+		return fmt.Errorf("text.replace: %s find %v（%s）", rel, err, hitLines(text, lo, spans))
+	}
+	if len(spans) == 0 {
+		if logger != nil {
+			logger.Log("ℹ️ text.replace: %s 未命中（count=all），无需替换", rel)
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	prev := 0
+	for _, sp := range spans {
+		sb.WriteString(region[prev:sp[0]])
+		if re != nil {
+			sb.Write(re.ExpandString(nil, with, region, sp))
+		} else {
+			sb.WriteString(with)
+		}
+		prev = sp[1]
+	}
+	sb.WriteString(region[prev:])
+	out := text[:lo] + sb.String() + text[hi:]
+
+	if err := writeText(abs, out, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ text.replace: %s 替换 %d 处（%s）", rel, len(spans), hitLines(text, lo, spans))
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+// textReplaceFuzzy 在作用域内模糊定位 find 对应的整行区间，替换为 with
+func textReplaceFuzzy(repo, rel, text string, ff fileFormat, sc scope, find, with string, args map[string]string, git gitops.GitBackend, logger DualLogger) error {
+	abs := filepath.Join(repo, rel)
+	lines := splitKeepNL(text)
+	h, err := fuzzyFind(lines, contextLines(find), sc.start, sc.end, args)
+	if err != nil {
+		return fmt.Errorf("text.replace: %s find 未命中；%v", rel, err)
+	}
+	if logger != nil {
+		logger.Log("🔍 text.replace: %s 模糊命中 %s", rel, h)
+	}
+	last := lines[h.start+h.n-2]
+	repl := with
+	if repl != "" && !strings.HasSuffix(repl, "\n") && strings.HasSuffix(last, "\n") {
+		repl += "\n"
+	}
+	out := strings.Join(lines[:h.start-1], "") + repl + strings.Join(lines[h.start-1+h.n:], "")
+	if err := writeText(abs, out, ff); err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Log("✏️ text.replace: %s 替换 L%d-L%d（%d→%d 行）", rel, h.start, h.start+h.n-1, h.n, len(splitKeepNL(repl)))
+	}
+	return stageAndPreflight(repo, rel, git, logger)
+}
+
+// countSpec 命中数断言
+type countSpec struct {
+	n   int  // 恰好 n 处（n>0）
+	any bool // 至少 1 处
+	all bool // 不限
+}
+
+func parseCount(raw string) (countSpec, error) {
+	switch v := strings.ToLower(strings.TrimSpace(raw)); v {
+	case "":
+		return countSpec{n: 1}, nil
+	case "+", "any":
+		return countSpec{any: true}, nil
+	case "all", "*":
+		return countSpec{all: true}, nil
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return countSpec{}, fmt.Errorf("count=%q 非法（支持 N / + / all）", raw)
+		}
+		return countSpec{n: n}, nil
+	}
+}
+
+func (c countSpec) check(got int) error {
+	switch {
+	case c.all:
+		return nil
+	case c.any:
+		if got == 0 {
+			return errors.New("未命中（期望至少 1 处）")
+		}
+		return nil
+	case got != c.n:
+		return fmt.Errorf("命中 %d 处，期望恰好 %d 处", got, c.n)
+	}
+	return nil
+}
+
+// findLiteral 字面查找全部不重叠的命中区间
+func findLiteral(s, sub string) [][]int {
+	var out [][]int
+	for off := 0; off <= len(s); {
+		i := strings.Index(s[off:], sub)
+		if i < 0 {
+			break
+		}
+		out = append(out, []int{off + i, off + i + len(sub)})
+		off += i + len(sub)
+	}
+	return out
+}
+
+// scopeSpan 把作用域参数（start-keys/end-keys 等）换算成字节区间与行区间；无作用域 → 全文
+func scopeSpan(rel, text string, args map[string]string, logger DualLogger) (int, int, scope, error) {
+	lines := splitKeepNL(text)
+	if strings.TrimSpace(args["start-keys"]) == "" && strings.TrimSpace(args["start-context"]) == "" && strings.TrimSpace(args["end"]) == "" && strings.TrimSpace(args["scope"]) == "" {
+		return 0, len(text), scope{start: 1, end: len(lines)}, nil
+	}
+	sc, err := resolveScope(rel, lines, args, logger)
+	if err != nil {
+		return 0, 0, scope{}, err
+	}
+	lo, hi := 0, 0
+	for i, l := range lines {
+		if i < sc.start-1 {
+			lo += len(l)
+		}
+		if i < sc.end {
+			hi += len(l)
+		}
+	}
+	return lo, hi, sc, nil
+}
+
+// splitKeepNL 按行拆分并保留行尾换行（末尾无换行的最后一行原样保留）
+func splitKeepNL(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hitLines 生成命中位置描述，如 "L3 L9"（行号相对整个文件）
+func hitLines(text string, base int, spans [][]int) string {
+	if len(spans) == 0 {
+		return "无命中"
+	}
+	var parts []string
+	for _, sp := range spans {
+		parts = append(parts, fmt.Sprintf("L%d", strings.Count(text[:base+sp[0]], "\n")+1))
+	}
+	return strings.Join(parts, " ")
+}
+
+// argOn 布尔参数（true/1/yes/on）
+func argOn(args map[string]string, key string) bool {
+	switch strings.ToLower(strings.TrimSpace(args[key])) {
+	case "1", "true", "yes", "y", "on":
+		return true
+	}
+	return false
+}
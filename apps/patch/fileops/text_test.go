@@ -0,0 +1,45 @@
+package fileops
+
+import "testing"
+
+func TestTextReplaceCount(t *testing.T) {
+	repo, rel := writeTestFile(t, "foo bar foo\nfoo\n")
+	if err := TextReplace(repo, rel, map[string]string{"find": "foo", "with": "baz"}, nil, nil); err == nil {
+		t.Fatal("默认 count=1 命中 3 处应报错")
+	}
+	if got := readTestFile(t, repo, rel); got != "foo bar foo\nfoo\n" {
+		t.Fatalf("报错时不应修改文件：%q", got)
+	}
+	if err := TextReplace(repo, rel, map[string]string{"find": "foo", "with": "baz", "count": "3"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, repo, rel); got != "baz bar baz\nbaz\n" {
+		t.Fatalf("内容 = %q", got)
+	}
+}
+
+// with 已出现在文件中不能掩盖 find 未命中
+func TestTextReplaceMissingFindFailsLoudly(t *testing.T) {
+	repo, rel := writeTestFile(t, "newName()\n")
+	args := map[string]string{"find": "oldName()", "with": "newName()"}
+	if err := TextReplace(repo, rel, args, nil, nil); err == nil {
+		t.Fatal("find 未命中应报错")
+	}
+	args["count"] = "+"
+	args["idempotent"] = "on"
+	if err := TextReplace(repo, rel, args, nil, nil); err == nil {
+		t.Fatal("count=+ 没有确切命中数，不能视为已应用")
+	}
+}
+
+func TestTextReplaceExplicitIdempotentExactCount(t *testing.T) {
+	repo, rel := writeTestFile(t, "newName()\nnewName()\n")
+	args := map[string]string{"find": "oldName()", "with": "newName()", "count": "2", "idempotent": "on"}
+	if err := TextReplace(repo, rel, args, nil, nil); err != nil {
+		t.Fatalf("with 恰好出现 2 次应视为已应用：%v", err)
+	}
+	args["count"] = "3"
+	if err := TextReplace(repo, rel, args, nil, nil); err == nil {
+		t.Fatal("with 出现次数与 count 不符应报错")
+	}
+}
@@ -0,0 +1,189 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/preflight"
+)
+
+// tomlEditor TOML
+//   - set：已有键只替换值的字节区间（行尾注释保留）；缺失的键插到所属表的最后一个键之后，
+//     所属表不存在时在文件末尾新建表头（上级以点分键或数组表出现时改为点分键写法）
+//   - delete：删除键值行；路径为表时删除整张表（含其子表）；数组表元素写作 bin[1]
+type tomlEditor struct{}
+
+// tomlPath 键路径转为 ParseTOMLDoc 的路径形式（下标记作 "[N]"）
+func tomlPath(path []keySeg) []string {
+	out := make([]string, len(path))
+	for i, s := range path {
+		if s.isIdx {
+			out[i] = "[" + strconv.Itoa(s.index) + "]"
+		} else {
+			out[i] = s.key
+		}
+	}
+	return out
+}
+
+func hasPathPrefix(p, prefix []string) bool {
+	if len(p) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var tomlBare = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(keys []string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		if tomlBare.MatchString(k) {
+			parts[i] = k
+		} else {
+			parts[i] = strconv.Quote(k)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (tomlEditor) set(text string, path []keySeg, value string, create bool) (string, applyState, error) {
+	if _, err := preflight.ParseTOMLDoc([]byte("x = " + value + "\n")); err != nil {
+		return "", stateAbsent, fmt.Errorf("值不是合法的 TOML 字面量：%v", err)
+	}
+	entries, err := preflight.ParseTOMLDoc([]byte(text))
+	if err != nil {
+		return "", stateAbsent, fmt.Errorf("文件不是合法 TOML：%v", err)
+	}
+	target := tomlPath(path)
+	for _, e := range entries {
+		switch {
+		case hasPathPrefix(e.Path, target) && len(e.Path) == len(target) && e.Table:
+			return "", stateAbsent, errors.New("目标是一张表，不能直接赋值（整表可用 toml.delete 删除）")
+		case hasPathPrefix(e.Path, target) && len(e.Path) == len(target):
+			if text[e.ValStart:e.ValEnd] == value {
+				return text, stateApplied, nil
+			}
+			return text[:e.ValStart] + value + text[e.ValEnd:], stateAbsent, nil
+		case !e.Table && len(e.Path) < len(target) && hasPathPrefix(target, e.Path):
+			return "", stateAbsent, fmt.Errorf("上级 %s 已是一个值，无法在其下创建键", strings.Join(e.Path, "."))
+		}
+	}
+	if !create {
+		return "", stateAbsent, errors.New("键不存在（create=false）")
+	}
+
+	// 所属表：路径前缀最长的已有表头（无则为根表）
+	var table []string
+	hdr := -1
+	for i, e := range entries {
+		if e.Table && len(e.Path) < len(target) && hasPathPrefix(target, e.Path) && len(e.Path) >= len(table) {
+			table, hdr = e.Path, i
+		}
+	}
+	rest := target[len(table):]
+	for _, k := range rest {
+		if strings.HasPrefix(k, "[") {
+			return "", stateAbsent, errors.New("不能新建数组表元素")
+		}
+	}
+	// 该表内已有同一上级的点分键，或所属表是数组表元素（无法用表头表达）：写点分键
+	dotted := len(rest) == 1
+	for _, k := range table {
+		dotted = dotted || strings.HasPrefix(k, "[")
+	}
+	for _, e := range entries {
+		if !e.Table && e.KeyDotted > 1 && len(e.Path)-e.KeyDotted == len(table) && hasPathPrefix(e.Path, append(append([]string(nil), table...), rest[0])) {
+			dotted = true
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	if !dotted {
+		end := contentEnd(lines)
+		var ins []string
+		if end > 0 && strings.TrimSpace(lines[end-1]) != "" {
+			ins = append(ins, "")
+		}
+		ins = append(ins, "["+tomlKey(target[:len(target)-1])+"]", tomlKey(target[len(target)-1:])+" = "+value)
+		return strings.Join(insertAt(lines, end, ins), "\n"), stateAbsent, nil
+	}
+
+	// 插到所属表最后一个键值之后（无键值时紧跟表头；根表则放在文件开头）
+	at := 0
+	if hdr >= 0 {
+		at = entries[hdr].Line + 1
+	}
+	for i := hdr + 1; i < len(entries) && !entries[i].Table; i++ {
+		at = entries[i].EndLine + 1
+	}
+	ins := []string{tomlKey(rest) + " = " + value}
+	if hdr < 0 && at == 0 && len(entries) > 0 && strings.TrimSpace(lines[0]) != "" {
+		ins = append(ins, "")
+	}
+	return strings.Join(insertAt(lines, at, ins), "\n"), stateAbsent, nil
+}
+
+func (tomlEditor) delete(text string, path []keySeg) (string, applyState, error) {
+	entries, err := preflight.ParseTOMLDoc([]byte(text))
+	if err != nil {
+		return "", stateAbsent, fmt.Errorf("文件不是合法 TOML：%v", err)
+	}
+	target := tomlPath(path)
+	lines := strings.Split(text, "\n")
+	end := contentEnd(lines)
+
+	// 路径下的全部表头与键值：表头删到下一张不属于该路径的表之前
+	type span struct{ from, to int }
+	var spans []span
+	for i, e := range entries {
+		if !hasPathPrefix(e.Path, target) {
+			continue
+		}
+		if !e.Table {
+			spans = append(spans, span{e.Line, e.EndLine + 1})
+			continue
+		}
+		to := end
+		for _, n := range entries[i+1:] {
+			if n.Table && !hasPathPrefix(n.Path, target) {
+				to = n.Line
+				break
+			}
+		}
+		from := e.Line
+		if to == end { // 末尾的表：连同之前的空行一起去掉
+			for from > 0 && strings.TrimSpace(lines[from-1]) == "" {
+				from--
+			}
+		}
+		spans = append(spans, span{from, to})
+	}
+	if len(spans) == 0 {
+		return text, stateApplied, nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].from < spans[j].from })
+	merged := []span{spans[0]}
+	for _, s := range spans[1:] {
+		if last := &merged[len(merged)-1]; s.from <= last.to {
+			if s.to > last.to {
+				last.to = s.to
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	for i := len(merged) - 1; i >= 0; i-- {
+		lines = splice(lines, merged[i].from, merged[i].to-merged[i].from, nil)
+	}
+	return strings.Join(lines, "\n"), stateAbsent, nil
+}
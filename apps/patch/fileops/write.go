@@ -17,7 +17,7 @@ import (
 
 // XGIT:BEGIN GO:FUNC_FILE_WRITE
 // FileWrite 写入（覆盖）文件 —— 协议: file.write
-func FileWrite(repo, rel string, data []byte, logger DualLogger) error {
+func FileWrite(repo, rel string, data []byte, args map[string]string, logger DualLogger) error {
 	abs := filepath.Join(repo, rel)
 	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
 		if logger != nil {
@@ -25,13 +25,28 @@ func FileWrite(repo, rel string, data []byte, logger DualLogger) error {
 		}
 		return err
 	}
-	// 统一 LF；保证末尾换行
+	// 统一 LF；保证末尾换行（ensure_nl=false 时保持正文原样）
 	s := string(data)
 	s = normalizeLF(s)
-	if len(s) > 0 && s[len(s)-1] != '\n' {
+	if ensureNL(args, true) && len(s) > 0 && s[len(s)-1] != '\n' {
 		s += "\n"
 	}
-	if err := os.WriteFile(abs, []byte(s), 0o644); err != nil {
+	// 覆盖已有文件时沿用其权限、换行风格与 BOM
+	old, ff, err := readText(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// 幂等：内容逐字节一致视为已应用（只去掉尾随空白的写入仍然执行；换行风格按原文件保留）
+	if ff.exists {
+		st := stateAbsent
+		if old == s {
+			st = stateApplied
+		}
+		if skip, err := idemSkip("file.write", rel, st, args, logger); err != nil || skip {
+			return err
+		}
+	}
+	if err := writeText(abs, s, ff); err != nil {
 		if logger != nil {
 			logger.Log("❌ file.write 写入失败：%s (%v)", rel, err)
 		}
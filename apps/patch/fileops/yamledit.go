@@ -0,0 +1,242 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"xgit/apps/patch/preflight"
+)
+
+// yamlEditor YAML（单文档）
+//   - set：已有键只替换行内值（行尾注释保留），原值为子块时整块替换；缺失的键按同级缩进插到最后一个同级条目之后
+//   - 多行值（正文）按目标层级重新缩进；首行为 | / > 时作为块标量写在键的同一行
+//   - delete：删除条目及其子块；下标 steps[1] 指序列中的第 2 项
+type yamlEditor struct{}
+
+type yamlDoc struct {
+	lines []string
+	nodes []*preflight.YAMLNode
+	unit  string
+}
+
+func parseYAMLForEdit(text string) (*yamlDoc, error) {
+	lines := strings.Split(text, "\n")
+	docs := preflight.YAMLDocuments(lines)
+	if len(docs) > 1 {
+		return nil, errors.New("暂不支持多文档 YAML（--- 分隔）")
+	}
+	nodes, err := preflight.ParseYAMLDoc(lines, docs[0][0], docs[0][1])
+	if err != nil {
+		return nil, fmt.Errorf("文件不是合法 YAML：%v", err)
+	}
+	unit := preflight.DetectIndent([]byte(text))
+	if unit == "" || strings.Contains(unit, "\t") {
+		unit = "  "
+	}
+	return &yamlDoc{lines: lines, nodes: nodes, unit: unit}, nil
+}
+
+// find 沿路径查找；未找到时返回已匹配的层数、最后匹配的上级与该层的同级条目
+func (d *yamlDoc) find(path []keySeg) (node, parent *preflight.YAMLNode, depth int, siblings []*preflight.YAMLNode) {
+	cur := d.nodes
+	for i, seg := range path {
+		var hit *preflight.YAMLNode
+		idx := 0
+		for _, n := range cur {
+			if seg.isIdx && n.Seq {
+				if idx == seg.index {
+					hit = n
+					break
+				}
+				idx++
+			} else if !seg.isIdx && !n.Seq && n.ColonEnd >= 0 && n.Key == seg.key {
+				hit = n
+				break
+			}
+		}
+		if hit == nil {
+			return nil, parent, i, cur
+		}
+		if i == len(path)-1 {
+			return hit, parent, i, cur
+		}
+		parent, cur = hit, hit.Children
+	}
+	return nil, parent, len(path), cur
+}
+
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z0-9_$][A-Za-z0-9_.$/-]*$`)
+
+func yamlKey(k string) string {
+	if yamlPlainKey.MatchString(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+// yamlValueLines 把值接在 head（"  key:" 或 "  -"）之后；多行值去掉公共缩进后按 indent 重新缩进
+func yamlValueLines(head, indent, value string, seq bool) []string {
+	vl := strings.Split(value, "\n")
+	if len(vl) == 1 {
+		return []string{head + " " + value}
+	}
+	min := -1
+	for _, l := range vl {
+		if t := strings.TrimLeft(l, " "); t != "" && (min < 0 || len(l)-len(t) < min) {
+			min = len(l) - len(t)
+		}
+	}
+	for i, l := range vl {
+		if len(l) >= min && min > 0 {
+			vl[i] = l[min:]
+		}
+	}
+	var out []string
+	first := strings.TrimSpace(vl[0])
+	if seq || strings.HasPrefix(first, "|") || strings.HasPrefix(first, ">") {
+		out = append(out, head+" "+first)
+		vl = vl[1:]
+	} else {
+		out = append(out, head)
+	}
+	for _, l := range vl {
+		if strings.TrimSpace(l) == "" {
+			out = append(out, "")
+		} else {
+			out = append(out, indent+l)
+		}
+	}
+	return out
+}
+
+// yamlStructured 多行值的首行是否已是 YAML 结构（映射、序列、流式集合或块标量指示符）
+func yamlStructured(value string) bool {
+	first := strings.TrimSpace(value[:strings.IndexByte(value, '\n')])
+	return first == "-" || strings.HasPrefix(first, "- ") || strings.HasSuffix(first, ":") || strings.Contains(first, ": ") ||
+		first != "" && strings.ContainsRune("[{|>", rune(first[0]))
+}
+
+func (yamlEditor) set(text string, path []keySeg, value string, create bool) (string, applyState, error) {
+	d, err := parseYAMLForEdit(text)
+	if err != nil {
+		return "", stateAbsent, err
+	}
+	n, parent, depth, siblings := d.find(path)
+	if strings.Contains(value, "\n") && !yamlStructured(value) {
+		// 多行纯文本写成块标量，沿用原值的指示符（|- / >）
+		ind := "|"
+		if n != nil && n.ValCol >= 0 {
+			if v := d.lines[n.Line][n.ValCol:n.ValEnd]; strings.HasPrefix(v, "|") || strings.HasPrefix(v, ">") {
+				ind = v
+			}
+		}
+		value = ind + "\n" + value
+	}
+	if n != nil {
+		l := d.lines[n.Line]
+		var repl []string
+		switch {
+		case !strings.Contains(value, "\n") && n.ValCol >= 0:
+			repl = []string{l[:n.ValCol] + value + l[n.ValEnd:]}
+		case n.Seq:
+			repl = yamlValueLines(l[:n.ColonEnd], strings.Repeat(" ", n.Col+2), value, true)
+		default:
+			repl = yamlValueLines(l[:n.ColonEnd], strings.Repeat(" ", n.Col)+d.unit, value, false)
+			if c := strings.TrimSpace(l[n.ColonEnd:]); strings.HasPrefix(c, "#") && len(repl) == 1 {
+				repl[0] += "  " + c
+			}
+		}
+		if strings.Join(repl, "\n") == strings.Join(d.lines[n.Line:n.End], "\n") {
+			return text, stateApplied, nil
+		}
+		return strings.Join(splice(d.lines, n.Line, n.End-n.Line, repl), "\n"), stateAbsent, nil
+	}
+
+	if !create {
+		return "", stateAbsent, errors.New("键不存在（create=false）")
+	}
+	rest := path[depth:]
+	for _, s := range rest {
+		if s.isIdx {
+			return "", stateAbsent, errors.New("序列下标不存在（不能新建序列项）")
+		}
+	}
+	if len(siblings) > 0 && siblings[0].Seq {
+		return "", stateAbsent, fmt.Errorf("%s 是序列，请用下标访问", keyPathString(path[:depth]))
+	}
+	lines := d.lines
+	if parent != nil && parent.ValCol >= 0 {
+		l := lines[parent.Line]
+		switch v := strings.TrimSpace(l[parent.ValCol:parent.ValEnd]); v {
+		case "{}", "null", "~":
+			lines = splice(lines, parent.Line, 1, []string{strings.TrimRight(l[:parent.ColonEnd]+l[parent.ValEnd:], " ")})
+		default:
+			return "", stateAbsent, fmt.Errorf("上级 %s 已是标量值 %s，无法在其下创建键", keyPathString(path[:depth]), v)
+		}
+	}
+
+	// 缩进：与同级一致；无同级时比上级多一级（序列项的子映射对齐到 "- " 之后）
+	col, at := 0, contentEnd(lines)
+	switch {
+	case len(siblings) > 0:
+		col, at = siblings[0].Col, siblings[len(siblings)-1].End
+	case parent != nil && parent.Seq:
+		col, at = parent.Col+2, parent.End
+	case parent != nil:
+		col, at = parent.Col+len(d.unit), parent.End
+	}
+	var ins []string
+	for i, s := range rest {
+		indent := strings.Repeat(" ", col) + strings.Repeat(d.unit, i)
+		head := indent + yamlKey(s.key) + ":"
+		if i < len(rest)-1 {
+			ins = append(ins, head)
+			continue
+		}
+		ins = append(ins, yamlValueLines(head, indent+d.unit, value, false)...)
+	}
+	return strings.Join(insertAt(lines, at, ins), "\n"), stateAbsent, nil
+}
+
+func (yamlEditor) delete(text string, path []keySeg) (string, applyState, error) {
+	d, err := parseYAMLForEdit(text)
+	if err != nil {
+		return "", stateAbsent, err
+	}
+	n, parent, _, siblings := d.find(path)
+	if n == nil {
+		return text, stateApplied, nil
+	}
+	lines := d.lines
+	// 序列项同一行上的第一个键（- name: x）：把下一个键挪到 "- " 之后，唯一的键则留下空映射
+	if parent != nil && parent.Seq && parent.Inline && n.Line == parent.Line {
+		prefix := lines[parent.Line][:n.Col]
+		if len(siblings) == 1 {
+			return strings.Join(splice(lines, n.Line, n.End-n.Line, []string{strings.TrimRight(prefix, " ") + " {}"}), "\n"), stateAbsent, nil
+		}
+		next := siblings[1]
+		merged := prefix + strings.TrimLeft(lines[next.Line], " ")
+		return strings.Join(splice(lines, n.Line, next.Line-n.Line+1, []string{merged}), "\n"), stateAbsent, nil
+	}
+	lines = splice(lines, n.Line, n.End-n.Line, nil)
+	// 删掉上级映射键的最后一个子项时写成空集合，避免上级变成 null
+	if parent != nil && !parent.Seq && len(siblings) == 1 {
+		l := lines[parent.Line]
+		empty := "{}"
+		if n.Seq {
+			empty = "[]"
+		}
+		switch c := strings.TrimSpace(l[parent.ColonEnd:]); {
+		case strings.HasPrefix(c, "#"):
+			lines[parent.Line] = l[:parent.ColonEnd] + " " + empty + "  " + c
+		case c != "": // 锚点 / 标签
+			lines[parent.Line] = l[:parent.ColonEnd] + " " + c + " " + empty
+		default:
+			lines[parent.Line] = l[:parent.ColonEnd] + " " + empty
+		}
+	}
+	return strings.Join(lines, "\n"), stateAbsent, nil
+}
@@ -0,0 +1,191 @@
+package gitops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// XGIT:BEGIN GITOPS BACKEND
+// GitBackend 抽象 patchd 用到的全部 git 能力；生产用 ExecBackend，测试用 FakeBackend。
+// 约定：repo 为仓库根目录；返回的 error 已包含 git 的输出，便于直接写日志。
+type GitBackend interface {
+	RevParse(repo, ref string) (string, error)                       // rev-parse --verify <ref>
+	Add(repo string, paths ...string) error                          // add -A -- [paths...]（无 paths → 全部）
+	AddIntent(repo string, paths ...string) error                    // add -N -- paths...
+	StagedNames(repo string) ([]string, error)                       // diff --cached --name-only -z
+	Commit(repo, author, message string) error                       // commit --author -m
+	Reset(repo, mode, ref string) error                              // reset --<mode> [ref]
+	Clean(repo string) error                                         // clean -fd
+	Apply(repo string, args ...string) (string, error)               // apply <args...>（含补丁路径）
+	Mv(repo, from, to string) error                                  // mv -f from to
+	Rm(repo string, paths ...string) error                           // rm -f -- paths...
+	Tag(repo, name, ref, message string, force bool) error           // tag [-a -m] name ref [-f]
+	Revert(repo, ref string, noCommit bool) error                    // revert [--no-commit] ref
+	Push(repo, remote, ref string) (string, error)                   // push remote ref
+	Show(repo, ref, path string) ([]byte, error)                     // show <ref>:<path>（仅 stdout）
+	MergeFile(repo, ours, base, theirs string) ([]byte, bool, error) // merge-file -p；bool=是否有冲突
+	LsFiles(repo string) ([]string, error)                           // ls-files -z --cached --others --exclude-standard
+}
+
+// ExecBackend 通过本机 git 可执行文件实现 GitBackend
+type ExecBackend struct {
+	Bin string // git 可执行文件（默认 "git"）
+}
+
+// NewExecBackend 构造默认的 exec 实现
+func NewExecBackend() *ExecBackend { return &ExecBackend{Bin: "git"} }
+
+// run 执行 git 命令（自动 -C repo），返回合并输出（stdout+stderr）
+func (b *ExecBackend) run(repo string, args ...string) (string, error) {
+	bin := b.Bin
+	if bin == "" {
+		bin = "git"
+	}
+	cmd := exec.Command(bin, append([]string{"-C", repo}, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("git %s 失败：%v\n%s", strings.Join(args, " "), err, out.String())
+	}
+	return out.String(), nil
+}
+
+func (b *ExecBackend) RevParse(repo, ref string) (string, error) {
+	out, err := b.run(repo, "rev-parse", "--verify", ref)
+	return strings.TrimSpace(out), err
+}
+
+func (b *ExecBackend) Add(repo string, paths ...string) error {
+	_, err := b.run(repo, append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+func (b *ExecBackend) AddIntent(repo string, paths ...string) error {
+	_, err := b.run(repo, append([]string{"add", "-N", "--"}, paths...)...)
+	return err
+}
+
+func (b *ExecBackend) StagedNames(repo string) ([]string, error) {
+	out, err := b.run(repo, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, p := range strings.Split(out, "\x00") {
+		if strings.TrimSpace(p) != "" {
+			names = append(names, p)
+		}
+	}
+	return names, nil
+}
+
+func (b *ExecBackend) Commit(repo, author, message string) error {
+	_, err := b.run(repo, "commit", "--author", author, "-m", message)
+	return err
+}
+
+func (b *ExecBackend) Reset(repo, mode, ref string) error {
+	args := []string{"reset", "--" + mode}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	_, err := b.run(repo, args...)
+	return err
+}
+
+func (b *ExecBackend) Clean(repo string) error {
+	_, err := b.run(repo, "clean", "-fd")
+	return err
+}
+
+func (b *ExecBackend) Apply(repo string, args ...string) (string, error) {
+	return b.run(repo, append([]string{"apply"}, args...)...)
+}
+
+func (b *ExecBackend) Mv(repo, from, to string) error {
+	_, err := b.run(repo, "mv", "-f", from, to)
+	return err
+}
+
+func (b *ExecBackend) Rm(repo string, paths ...string) error {
+	_, err := b.run(repo, append([]string{"rm", "-f", "--"}, paths...)...)
+	return err
+}
+
+func (b *ExecBackend) Tag(repo, name, ref, message string, force bool) error {
+	var args []string
+	if message != "" {
+		args = []string{"tag", "-a", name, ref, "-m", message}
+	} else {
+		args = []string{"tag", name, ref}
+	}
+	if force {
+		args = append(args, "-f")
+	}
+	_, err := b.run(repo, args...)
+	return err
+}
+
+func (b *ExecBackend) Revert(repo, ref string, noCommit bool) error {
+	args := []string{"revert"}
+	if noCommit {
+		args = append(args, "--no-commit")
+	}
+	_, err := b.run(repo, append(args, ref)...)
+	return err
+}
+
+func (b *ExecBackend) Push(repo, remote, ref string) (string, error) {
+	return b.run(repo, "push", remote, ref)
+}
+
+func (b *ExecBackend) Show(repo, ref, path string) ([]byte, error) {
+	return b.stdout(repo, "show", ref+":"+path)
+}
+
+func (b *ExecBackend) LsFiles(repo string) ([]string, error) {
+	out, err := b.stdout(repo, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" && !seen[p] { // 冲突文件在 --cached 中会出现多次
+			seen[p] = true
+			names = append(names, p)
+		}
+	}
+	return names, nil
+}
+
+func (b *ExecBackend) MergeFile(repo, ours, base, theirs string) ([]byte, bool, error) {
+	out, err := b.stdout(repo, "merge-file", "-p", ours, base, theirs)
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && ee.ExitCode() > 0 && ee.ExitCode() < 128 {
+		return out, true, nil // 退出码 = 冲突数
+	}
+	return out, false, err
+}
+
+// stdout 执行 git 命令，仅返回 stdout（stderr 只用于报错），适合读取文件内容
+func (b *ExecBackend) stdout(repo string, args ...string) ([]byte, error) {
+	bin := b.Bin
+	if bin == "" {
+		bin = "git"
+	}
+	cmd := exec.Command(bin, append([]string{"-C", repo}, args...)...)
+	var out, er bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &er
+	if err := cmd.Run(); err != nil {
+		return out.Bytes(), fmt.Errorf("git %s 失败：%w\n%s", strings.Join(args, " "), err, er.String())
+	}
+	return out.Bytes(), nil
+}
+
+// XGIT:END GITOPS BACKEND
@@ -1,10 +1,7 @@
 package gitops
 
 import (
-	"bytes"
-	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
@@ -15,25 +12,6 @@ type DualLogger interface {
 	Log(format string, a ...any)
 }
 
-// runGit 执行 git 命令（自动 -C repo），返回合并输出（stdout+stderr）
-func runGit(repo string, logger DualLogger, args ...string) (string, error) {
-	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err := cmd.Run()
-	if err != nil {
-		return out.String(), fmt.Errorf("git %s 失败：%v\n%s", strings.Join(args, " "), err, out.String())
-	}
-	return out.String(), nil
-}
-
-// runGitQuiet 同 runGit，但仅关心错误
-func runGitQuiet(repo string, logger DualLogger, args ...string) error {
-	_, err := runGit(repo, logger, args...)
-	return err
-}
-
 // findRejects 扫描 repo 下的 .rej 文件；仅用于提示
 func findRejects(repo string) ([]string, error) {
 	var out []string
@@ -16,7 +16,7 @@ import (
 
 // 依赖（在其它文件已提供）：
 // - type DualLogger interface{ Log(format string, a ...any) }
-// - runGit(repo string, logger DualLogger, args ...string) (string, error)
+// - GitBackend（由调用方注入）
 // - findRejects(repo string) ([]string, error)
 //
 // 设计（lean，无影子、无语言预检）：
@@ -27,7 +27,7 @@ import (
 //   对“新建文件”执行：补丁 + 行数 == 工作区实际行数 的强校验。
 
 // Diff 应用 diffText 到 repo
-func Diff(repo string, diffText string, logger DualLogger) error {
+func Diff(repo string, diffText string, git GitBackend, logger DualLogger) error {
 	log := func(format string, a ...any) {
 		if logger != nil {
 			logger.Log(format, a...)
@@ -66,7 +66,7 @@ func Diff(repo string, diffText string, logger DualLogger) error {
 	diffText = sanitizeDiff(diffText)
 
 	// 1.1) 结构化预处理：先处理删除/改名（不匹配内容）
-	if updated, did, err := applyStructuralOps(repo, diffText, logger); err != nil {
+	if updated, did, err := applyStructuralOps(repo, diffText, git, logger); err != nil {
 		return err
 	} else {
 		if did {
@@ -108,7 +108,7 @@ func Diff(repo string, diffText string, logger DualLogger) error {
 	log("📄 git.diff 正在应用补丁：%s", filepath.Base(patchPath))
 
 	// 3) 针对新增/重命名做 intent add -N
-	intentAddFromDiff(repo, diffText, logger)
+	intentAddFromDiff(repo, diffText, git)
 
 	// 3.2) 文件系统预检：新增/修改/删除/改名的存在性约束
 	if err := fsPreflight(repo, diffText, logger); err != nil {
@@ -116,7 +116,7 @@ func Diff(repo string, diffText string, logger DualLogger) error {
 	}
 
 	// 3.5) 预检：在正式 apply 前先 --check --recount
-	if err := preflightCheck(repo, patchPath, logger); err != nil {
+	if err := preflightCheck(repo, patchPath, git); err != nil {
 		// 若能解析出报错行，打印上下文
 		if line := extractPatchErrorLine(err.Error()); line > 0 {
 			if ctx := readPatchContext(patchPath, line, 20); ctx != "" {
@@ -133,8 +133,8 @@ func Diff(repo string, diffText string, logger DualLogger) error {
 	var lastPatchErrLine int
 
 	for i, args := range strategies {
-		full := append([]string{"apply"}, append(args, patchPath)...)
-		out, err := runGit(repo, logger, full...)
+		full := append(append([]string{}, args...), patchPath)
+		out, err := git.Apply(repo, full...)
 		if err != nil {
 			// 循环里只记“简要”，别刷屏
 			log("⚠️ git %v 失败（策略 #%d）", args, i+1)
@@ -276,7 +276,7 @@ func buildStrategiesFromDiff(s string) [][]string {
 }
 
 // intentAddFromDiff 对 a/ 和 b/ 路径、以及 rename from/to 的路径做 git add -N
-func intentAddFromDiff(repo string, diffText string, logger DualLogger) {
+func intentAddFromDiff(repo string, diffText string, git GitBackend) {
 	paths, _, _, _ := parseDiffPaths(diffText)
 
 	addN := func(p string) {
@@ -288,7 +288,7 @@ func intentAddFromDiff(repo string, diffText string, logger DualLogger) {
 		if strings.HasSuffix(p, "/") {
 			return
 		}
-		_, _ = runGit(repo, logger, "add", "-N", p)
+		_ = git.AddIntent(repo, p)
 	}
 
 	// a/ 与 b/ 路径
@@ -577,8 +577,8 @@ func hashAndNLines(s string) (sum string, n int) {
 }
 
 // NEW: 预检 – 在正式 apply 前先 --check --recount
-func preflightCheck(repo, patchPath string, logger DualLogger) error {
-	_, err := runGit(repo, logger, "apply", "--check", "--recount", "--verbose", patchPath)
+func preflightCheck(repo, patchPath string, git GitBackend) error {
+	_, err := git.Apply(repo, "--check", "--recount", "--verbose", patchPath)
 	if err != nil {
 		return fmt.Errorf("git apply --check 失败：%w", err)
 	}
@@ -714,7 +714,7 @@ func fsPreflight(repo, diffText string, logger DualLogger) error {
 
 // applyStructuralOps: 先用 porcelain 命令处理删除/改名，不让 git apply 去匹配旧内容。
 // 返回：更新后的 diff（已剔除 D/R 的块）、是否做了结构化处理、错误
-func applyStructuralOps(repo, s string, logger DualLogger) (string, bool, error) {
+func applyStructuralOps(repo, s string, git GitBackend, logger DualLogger) (string, bool, error) {
 	adds, dels, mods, renames := summarizeDiffFiles(s)
 	_ = adds
 	_ = mods // 这里只处理 dels/renames
@@ -732,7 +732,7 @@ func applyStructuralOps(repo, s string, logger DualLogger) (string, bool, error)
 	for _, pr := range renames {
 		from, to := pr[0], pr[1]
 		// 若 from 不存在，交给 fsPreflight 已经会拦；这里直接尝试 mv
-		if _, err := runGit(repo, logger, "mv", "-f", from, to); err != nil {
+		if err := git.Mv(repo, from, to); err != nil {
 			errs = append(errs, fmt.Sprintf("rename %s→%s 失败: %v", from, to, err))
 		} else {
 			log("🔧 rename: %s → %s", from, to)
@@ -742,7 +742,7 @@ func applyStructuralOps(repo, s string, logger DualLogger) (string, bool, error)
 
 	// 再处理 delete：等价 git rm -f path
 	for _, p := range dels {
-		if _, err := runGit(repo, logger, "rm", "-f", "--", p); err != nil {
+		if err := git.Rm(repo, p); err != nil {
 			errs = append(errs, fmt.Sprintf("delete %s 失败: %v", p, err))
 		} else {
 			log("🗑️ delete: %s", p)
@@ -846,3 +846,77 @@ func stripFileDiffBlocks(s string, paths []string) string {
 	res := strings.TrimLeft(strings.Join(out, "\n"), "\n")
 	return res
 }
+
+// DiffPaths 补丁涉及的全部仓库相对路径（--- / +++ / diff --git / rename / copy，去重，不含 /dev/null）
+// 供上层在 git apply 之前做路径安全校验
+func DiffPaths(diffText string) []string {
+	s := sanitizeDiff(diffText)
+	seen := map[string]bool{}
+	var out []string
+	add := func(p string) {
+		p = strings.TrimSpace(p)
+		if i := strings.IndexByte(p, '\t'); i >= 0 { // 去掉 "--- a/x\t时间戳"
+			p = p[:i]
+		}
+		p = strings.Trim(p, `"`)
+		if p == "" || p == "/dev/null" || seen[p] {
+			return
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	strip := func(p, prefix string) string {
+		return strings.TrimPrefix(strings.TrimSpace(p), prefix)
+	}
+	// 按 @@ 头部的行数跳过 hunk 正文（其中以 "--- " 开头的是删除行，不是路径头）
+	oldLeft, newLeft := 0, 0
+	for _, l := range strings.Split(s, "\n") {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(l, "-"):
+				oldLeft--
+			case strings.HasPrefix(l, "+"):
+				newLeft--
+			case strings.HasPrefix(l, "\\"):
+			default:
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			oldLeft, newLeft = hunkCounts(l)
+		case strings.HasPrefix(l, "diff --git "):
+			if f := strings.Fields(strings.TrimPrefix(l, "diff --git ")); len(f) == 2 {
+				add(strip(f[0], "a/"))
+				add(strip(f[1], "b/"))
+			}
+		case strings.HasPrefix(l, "--- "):
+			add(strip(l[4:], "a/"))
+		case strings.HasPrefix(l, "+++ "):
+			add(strip(l[4:], "b/"))
+		case strings.HasPrefix(l, "rename from "), strings.HasPrefix(l, "copy from "):
+			add(l[strings.Index(l, " from ")+6:])
+		case strings.HasPrefix(l, "rename to "), strings.HasPrefix(l, "copy to "):
+			add(l[strings.Index(l, " to ")+4:])
+		}
+	}
+	return out
+}
+
+// hunkCounts 解析 "@@ -a,b +c,d @@" 中的 b 与 d（省略时为 1）
+func hunkCounts(h string) (int, int) {
+	n := func(tok string) int {
+		if _, c, ok := strings.Cut(tok, ","); ok {
+			v, _ := strconv.Atoi(c)
+			return v
+		}
+		return 1
+	}
+	f := strings.Fields(h)
+	if len(f) < 3 {
+		return 0, 0
+	}
+	return n(f[1]), n(f[2])
+}
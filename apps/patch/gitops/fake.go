@@ -0,0 +1,230 @@
+package gitops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// XGIT:BEGIN GITOPS FAKE
+// FakeCall 记录一次对 FakeBackend 的调用
+type FakeCall struct {
+	Op   string   // 方法名：rev-parse / add / commit / ...
+	Repo string   // 仓库路径
+	Args []string // 其余参数（按方法签名顺序展开）
+}
+
+func (c FakeCall) String() string {
+	return c.Op + " " + strings.Join(c.Args, " ")
+}
+
+// FakeHandler 脚本化响应：返回 (输出, 错误)
+type FakeHandler func(call FakeCall) (string, error)
+
+// FakeBackend 内存版 GitBackend：记录全部调用，可按方法名脚本化返回值。
+// 未脚本化的方法默认成功；RevParse 默认返回 Heads[repo]（为空则报错），
+// StagedNames 默认返回 Staged[repo]，Show 默认返回 Files["ref:path"]，LsFiles 默认返回 Tracked[repo]。
+// Commit/Reset 会相应维护 Heads/Staged。
+type FakeBackend struct {
+	mu      sync.Mutex
+	Calls   []FakeCall
+	Heads   map[string]string      // repo → HEAD
+	Staged  map[string][]string    // repo → 已暂存文件
+	Files   map[string]string      // "ref:path" → 内容（供 Show 使用）
+	Tracked map[string][]string    // repo → 纳入版本管理的文件（供 LsFiles 使用）
+	Script  map[string]FakeHandler // op → 响应
+	seq     int
+}
+
+// NewFakeBackend 构造空的 FakeBackend
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		Heads:   map[string]string{},
+		Staged:  map[string][]string{},
+		Files:   map[string]string{},
+		Tracked: map[string][]string{},
+		Script:  map[string]FakeHandler{},
+	}
+}
+
+// On 为某个方法设置脚本化响应（op 与 FakeCall.Op 一致）
+func (f *FakeBackend) On(op string, h FakeHandler) *FakeBackend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Script[op] = h
+	return f
+}
+
+// Fail 让某个方法固定返回错误
+func (f *FakeBackend) Fail(op string, err error) *FakeBackend {
+	return f.On(op, func(FakeCall) (string, error) { return "", err })
+}
+
+// Ops 返回按顺序记录的调用（"op arg1 arg2" 形式），便于断言
+func (f *FakeBackend) Ops() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, 0, len(f.Calls))
+	for _, c := range f.Calls {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+// record 记录调用；若有脚本则返回脚本结果（第二个返回值为 true）
+func (f *FakeBackend) record(op, repo string, args ...string) (string, bool, error) {
+	f.mu.Lock()
+	call := FakeCall{Op: op, Repo: repo, Args: append([]string(nil), args...)}
+	f.Calls = append(f.Calls, call)
+	h := f.Script[op]
+	f.mu.Unlock()
+	if h != nil {
+		out, err := h(call)
+		return out, true, err
+	}
+	return "", false, nil
+}
+
+func (f *FakeBackend) RevParse(repo, ref string) (string, error) {
+	if out, ok, err := f.record("rev-parse", repo, ref); ok {
+		return out, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ref = strings.TrimSuffix(ref, "^{commit}")
+	if h := f.Heads[repo]; h != "" && (ref == "HEAD" || ref == h) {
+		return h, nil
+	}
+	return "", fmt.Errorf("git rev-parse --verify %s 失败：fake 无此提交", ref)
+}
+
+func (f *FakeBackend) Add(repo string, paths ...string) error {
+	_, _, err := f.record("add", repo, paths...)
+	return err
+}
+
+func (f *FakeBackend) AddIntent(repo string, paths ...string) error {
+	_, _, err := f.record("add-intent", repo, paths...)
+	return err
+}
+
+func (f *FakeBackend) StagedNames(repo string) ([]string, error) {
+	if out, ok, err := f.record("staged", repo); ok {
+		if out == "" {
+			return nil, err
+		}
+		return strings.Split(out, "\n"), err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.Staged[repo]...), nil
+}
+
+func (f *FakeBackend) Commit(repo, author, message string) error {
+	if _, ok, err := f.record("commit", repo, author, message); ok {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	f.Heads[repo] = fmt.Sprintf("fake%036d", f.seq)
+	f.Staged[repo] = nil
+	return nil
+}
+
+func (f *FakeBackend) Reset(repo, mode, ref string) error {
+	if _, ok, err := f.record("reset", repo, mode, ref); ok {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ref != "" && ref != "HEAD" {
+		f.Heads[repo] = ref
+	}
+	if mode != "soft" {
+		f.Staged[repo] = nil
+	}
+	return nil
+}
+
+func (f *FakeBackend) Clean(repo string) error {
+	_, _, err := f.record("clean", repo)
+	return err
+}
+
+func (f *FakeBackend) Apply(repo string, args ...string) (string, error) {
+	out, _, err := f.record("apply", repo, args...)
+	return out, err
+}
+
+func (f *FakeBackend) Mv(repo, from, to string) error {
+	_, _, err := f.record("mv", repo, from, to)
+	return err
+}
+
+func (f *FakeBackend) Rm(repo string, paths ...string) error {
+	_, _, err := f.record("rm", repo, paths...)
+	return err
+}
+
+func (f *FakeBackend) Tag(repo, name, ref, message string, force bool) error {
+	_, _, err := f.record("tag", repo, name, ref, message, fmt.Sprint(force))
+	return err
+}
+
+func (f *FakeBackend) Revert(repo, ref string, noCommit bool) error {
+	_, _, err := f.record("revert", repo, ref, fmt.Sprint(noCommit))
+	return err
+}
+
+func (f *FakeBackend) Push(repo, remote, ref string) (string, error) {
+	out, _, err := f.record("push", repo, remote, ref)
+	return out, err
+}
+
+// Show 默认返回 Files[ref+":"+path]；不存在则报错
+func (f *FakeBackend) Show(repo, ref, path string) ([]byte, error) {
+	if out, ok, err := f.record("show", repo, ref, path); ok {
+		return []byte(out), err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if b, ok := f.Files[ref+":"+path]; ok {
+		return []byte(b), nil
+	}
+	return nil, fmt.Errorf("git show %s:%s 失败：fake 无此文件", ref, path)
+}
+
+// LsFiles 默认返回 Tracked[repo]；脚本输出按换行分隔
+func (f *FakeBackend) LsFiles(repo string) ([]string, error) {
+	if out, ok, err := f.record("ls-files", repo); ok {
+		if out == "" {
+			return nil, err
+		}
+		return strings.Split(out, "\n"), err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.Tracked[repo]...), nil
+}
+
+// MergeFile 需脚本化：脚本返回 ErrFakeConflict 表示“合并有冲突”；未脚本化时报错
+func (f *FakeBackend) MergeFile(repo, ours, base, theirs string) ([]byte, bool, error) {
+	out, ok, err := f.record("merge-file", repo, ours, base, theirs)
+	if !ok {
+		return nil, false, errors.New("git merge-file 失败：fake 未脚本化")
+	}
+	if errors.Is(err, ErrFakeConflict) {
+		return []byte(out), true, nil
+	}
+	return []byte(out), false, err
+}
+
+// ErrFakeConflict 供 merge-file 脚本使用，表示“合并有冲突”
+var ErrFakeConflict = errors.New("fake merge conflict")
+
+var _ GitBackend = (*FakeBackend)(nil)
+var _ GitBackend = (*ExecBackend)(nil)
+
+// XGIT:END GITOPS FAKE
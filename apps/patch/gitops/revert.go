@@ -8,18 +8,12 @@ import (
 
 // XGIT:BEGIN GITOPS REVERT
 // Revert 撤销指定提交的更改（真正的git revert功能）
-func Revert(repo, ref string, noCommit bool, logger DualLogger) error {
+func Revert(repo, ref string, noCommit bool, git GitBackend, logger DualLogger) error {
 	ref = strings.TrimSpace(ref)
 	if ref == "" {
 		return errors.New("git.revert: 缺少要撤销的提交 ref")
 	}
 
-	args := []string{"revert"}
-	if noCommit {
-		args = append(args, "--no-commit") // 不自动提交，仅应用更改到暂存区
-	}
-	args = append(args, ref)
-
 	if logger != nil {
 		if noCommit {
 			logger.Log("↩️ git.revert: 撤销提交 %s（不自动提交）", ref)
@@ -28,7 +22,8 @@ func Revert(repo, ref string, noCommit bool, logger DualLogger) error {
 		}
 	}
 
-	if _, err := runGit(repo, logger, args...); err != nil {
+	// noCommit：不自动提交，仅应用更改到暂存区
+	if err := git.Revert(repo, ref, noCommit); err != nil {
 		return fmt.Errorf("git.revert 执行失败：%w", err)
 	}
 
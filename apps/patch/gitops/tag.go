@@ -8,7 +8,7 @@ import (
 
 // XGIT:BEGIN GITOPS TAG
 // 创建或更新标签。
-func Tag(repo, name, ref, message string, force, push bool, logger DualLogger) error {
+func Tag(repo, name, ref, message string, force, push bool, git GitBackend, logger DualLogger) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return errors.New("git.tag: 缺少标签名 name")
@@ -18,17 +18,6 @@ func Tag(repo, name, ref, message string, force, push bool, logger DualLogger) e
 		ref = "HEAD"
 	}
 
-	// 构造命令参数
-	var args []string
-	if message != "" {
-		args = []string{"tag", "-a", name, ref, "-m", message}
-	} else {
-		args = []string{"tag", name, ref}
-	}
-	if force {
-		args = append(args, "-f")
-	}
-
 	if logger != nil {
 		if message != "" {
 			logger.Log("🏷️  git.tag 附注标签：%s -> %s", name, ref)
@@ -36,7 +25,7 @@ func Tag(repo, name, ref, message string, force, push bool, logger DualLogger) e
 			logger.Log("🏷️  git.tag 轻量标签：%s -> %s", name, ref)
 		}
 	}
-	if _, err := runGit(repo, logger, args...); err != nil {
+	if err := git.Tag(repo, name, ref, message, force); err != nil {
 		return fmt.Errorf("git.tag 失败：%w", err)
 	}
 	if logger != nil {
@@ -47,7 +36,7 @@ func Tag(repo, name, ref, message string, force, push bool, logger DualLogger) e
 		if logger != nil {
 			logger.Log("🚀 推送标签到远端：origin %s", name)
 		}
-		if _, err := runGit(repo, logger, "push", "origin", name); err != nil {
+		if _, err := git.Push(repo, "origin", name); err != nil {
 			return fmt.Errorf("git.tag: 推送标签失败：%w", err)
 		}
 		if logger != nil {
@@ -0,0 +1,147 @@
+package main
+
+// XGIT:BEGIN FILE-HEADER
+// globops.go — 多文件目标：指令头路径可写通配（apps/**/*.go）或逗号分隔的路径列表
+// 展开为逐文件执行（每个文件单独做基线校验与路径校验），并逐文件输出结果
+// XGIT:END FILE-HEADER
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"xgit/apps/patch/fileops"
+	"xgit/apps/patch/gitops"
+)
+
+// isMultiTarget 指令头路径是否为通配 / 路径列表。
+// 路径本身就是一个已存在的文件（如 Next.js 的 app/[id]/page.tsx）时按字面处理；glob=false 强制按字面处理
+func isMultiTarget(repo string, op *FileOp) bool {
+	if !globCapable(op.Cmd) || !argBool(op.Args, "glob", true) {
+		return false
+	}
+	if !fileops.HasGlobMeta(op.Path) && !strings.Contains(op.Path, ",") {
+		return false
+	}
+	return !literalExists(repo, op.Path)
+}
+
+// literalExists rel 按字面是否为工作区中已存在的路径
+func literalExists(repo, rel string) bool {
+	_, err := os.Lstat(filepath.Join(repo, rel))
+	return err == nil
+}
+
+// globCapable 可以逐文件执行的指令：编辑已有文件的指令（新建 / 移动类指令只接受单个路径）
+func globCapable(cmd string) bool {
+	switch cmd {
+	case "file.write", "file.move", "file.binary", "file.image", "go.rename", "go.move-package":
+		return false
+	}
+	return !strings.HasPrefix(cmd, "git.") && !strings.HasPrefix(cmd, "repo.")
+}
+
+// targetPatterns 路径列表中的各项（原样，可含通配）
+func targetPatterns(op *FileOp) []string {
+	var out []string
+	for _, p := range strings.Split(op.Path, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// expandTargets 展开多文件目标：通配只匹配已存在、且未被 .gitignore 忽略的普通文件（以 git ls-files 为准），
+// 字面路径（含按字面存在的带通配符路径）原样保留；去重排序后应用 exclude=，并按 min-matches（默认 1）/ max-matches 校验命中数
+func expandTargets(repo string, op *FileOp, git gitops.GitBackend) ([]string, error) {
+	excl, err := fileops.CompileGlobs(op.Args["exclude"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: exclude %w", op.Cmd, err)
+	}
+	seen := map[string]bool{}
+	var files []string
+	var listed map[string]bool // git ls-files 的结果，首次遇到通配时读取
+	for _, p := range targetPatterns(op) {
+		var hits []string
+		if fileops.HasGlobMeta(p) && !literalExists(repo, p) {
+			g, err := fileops.CompileGlob(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op.Cmd, err)
+			}
+			if listed == nil {
+				all, err := git.LsFiles(repo)
+				if err != nil {
+					return nil, fmt.Errorf("%s: 列出文件失败：%w", op.Cmd, err)
+				}
+				listed = make(map[string]bool, len(all))
+				for _, f := range all {
+					listed[f] = true
+				}
+			}
+			walked, err := fileops.WalkGlob(repo, g)
+			if err != nil {
+				return nil, fmt.Errorf("%s: 遍历 %s 失败：%w", op.Cmd, p, err)
+			}
+			for _, h := range walked {
+				if listed[h] {
+					hits = append(hits, h)
+				}
+			}
+		} else {
+			hits = []string{p}
+		}
+		for _, h := range hits {
+			if !seen[h] && !fileops.MatchAny(excl, h) {
+				seen[h] = true
+				files = append(files, h)
+			}
+		}
+	}
+	sort.Strings(files)
+
+	min := argInt(op.Args, "min-matches", 1)
+	max := argInt(op.Args, "max-matches", -1)
+	if len(files) < min {
+		return nil, fmt.Errorf("%s: %q 命中 %d 个文件，少于 min-matches=%d", op.Cmd, op.Path, len(files), min)
+	}
+	if max >= 0 && len(files) > max {
+		return nil, fmt.Errorf("%s: %q 命中 %d 个文件，超过 max-matches=%d（%s）", op.Cmd, op.Path, len(files), max, previewPaths(files, 5))
+	}
+	return files, nil
+}
+
+// applyOpTargets 单路径直接执行；多文件目标逐文件执行（任一文件失败即中止，由事务整体回滚）
+func applyOpTargets(repo string, op *FileOp, patch *Patch, bi *baseInfo, git gitops.GitBackend, logger *DualLogger) error {
+	if !isMultiTarget(repo, op) {
+		return applyOpPinned(repo, op, patch, bi, git, logger)
+	}
+	files, err := expandTargets(repo, op, git)
+	if err != nil {
+		return err
+	}
+	logger.Log("🗂️ %s: %q 命中 %d 个文件", op.Cmd, op.Path, len(files))
+	for i, f := range files {
+		one := *op
+		one.Path = f
+		one.Args = make(map[string]string, len(op.Args))
+		for k, v := range op.Args {
+			one.Args[k] = v
+		}
+		if err := applyOpPinned(repo, &one, patch, bi, git, logger); err != nil {
+			logger.Log("❌ [%d/%d] %s：%v", i+1, len(files), f, err)
+			return fmt.Errorf("%w（%d/%d 个文件已完成）", err, i, len(files))
+		}
+		logger.Log("📄 [%d/%d] %s ✅", i+1, len(files), f)
+	}
+	return nil
+}
+
+func previewPaths(files []string, n int) string {
+	if len(files) <= n {
+		return strings.Join(files, ", ")
+	}
+	return strings.Join(files[:n], ", ") + fmt.Sprintf(" 等 %d 个", len(files))
+}
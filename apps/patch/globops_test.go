@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 按字面存在的带通配符路径（Next.js 动态路由）不能被当作通配展开
+func TestLiteralBracketPathIsNotGlob(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"app/[id]/page.tsx": "old\n", "app/i/page.tsx": "old\n"})
+	git := newTestFake(repo, "app/[id]/page.tsx")
+	git.Tracked[repo] = []string{"app/[id]/page.tsx", "app/i/page.tsx"}
+	logger, buf := testLogger()
+	patch := &Patch{Ops: []*FileOp{
+		{Cmd: "text.replace", Path: "app/[id]/page.tsx", Args: map[string]string{"find": "old", "with": "new"}},
+	}}
+	if !runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatalf("runBatch 失败：\n%s", buf)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo, "app/[id]/page.tsx")); string(b) != "new\n" {
+		t.Fatalf("app/[id]/page.tsx = %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo, "app/i/page.tsx")); string(b) != "old\n" {
+		t.Fatalf("app/i/page.tsx 不应被修改：%q", b)
+	}
+}
+
+func TestGlobFalseForcesLiteral(t *testing.T) {
+	repo := newTestRepo(t, nil)
+	op := &FileOp{Cmd: "file.ensure-line", Path: "app/[slug]/page.tsx", Args: map[string]string{"glob": "false"}}
+	if isMultiTarget(repo, op) {
+		t.Fatal("glob=false 应按字面处理")
+	}
+	op.Args = map[string]string{}
+	if !isMultiTarget(repo, op) {
+		t.Fatal("不存在的带通配符路径默认按通配处理")
+	}
+}
+
+// 通配只命中 git ls-files 列出的文件：被 .gitignore 忽略的生成物不参与
+func TestExpandTargetsSkipsIgnoredFiles(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{
+		"src/a.go":          "package a\n",
+		"src/b.go":          "package b\n",
+		"node_modules/x.go": "package x\n",
+	})
+	git := newTestFake(repo)
+	git.Tracked[repo] = []string{"src/a.go", "src/b.go", ".gitignore"}
+	files, err := expandTargets(repo, &FileOp{Cmd: "text.replace", Path: "**/*.go", Args: map[string]string{}}, git)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0] != "src/a.go" || files[1] != "src/b.go" {
+		t.Fatalf("命中 %v", files)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+// XGIT:BEGIN FILE-HEADER
+// journal.go — 批次预写日志（WAL）：记录 preHead、已完成指令与文件备份
+// patchd 中途被杀 → 下次启动时检测未完成的任务，按 recover: 策略整体回滚或续跑，并在日志中报告
+// XGIT:END FILE-HEADER
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"xgit/apps/patch/fileops"
+	"xgit/apps/patch/gitops"
+)
+
+const journalDir = ".xgit_journal"
+
+// 任务阶段
+const (
+	phaseOps    = "ops"    // 正在执行指令
+	phaseCommit = "commit" // 指令与校验已完成，正在提交
+	phasePush   = "push"   // 已提交，正在推送
+)
+
+// Journal 单个任务的预写日志（落盘于 <patchDir>/.xgit_journal/journal.json）
+type Journal struct {
+	Job     string    `json:"job"`      // 任务标识（补丁 md5 前 8 位）
+	Repo    string    `json:"repo"`     // 目标仓库绝对路径
+	PreHead string    `json:"pre_head"` // 任务开始前的 HEAD
+	Recover string    `json:"recover"`  // 中断后的处理策略：rollback|resume
+	Phase   string    `json:"phase"`
+	Current int       `json:"current"` // 正在执行的指令序号（0-based；-1 表示无）
+	Done    []int     `json:"done"`    // 已完成的指令序号
+	Backups []Backup  `json:"backups"` // 执行每条指令前的文件备份
+	Started time.Time `json:"started"`
+
+	dir string
+}
+
+// Backup 指令执行前某个文件的状态
+type Backup struct {
+	Op     int    `json:"op"`
+	Path   string `json:"path"`             // 相对仓库路径
+	Copy   string `json:"copy,omitempty"`   // 备份副本（相对 journal 目录）
+	Absent bool   `json:"absent,omitempty"` // 执行前文件不存在
+	Mode   uint32 `json:"mode,omitempty"`
+}
+
+// newJournal 开始一个新任务：保存补丁副本并落盘
+func newJournal(patchDir, job, repo, preHead, recover string, patchText []byte) (*Journal, error) {
+	dir := filepath.Join(patchDir, journalDir)
+	_ = os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "backups"), 0o755); err != nil {
+		return nil, err
+	}
+	if err := writeFileSync(filepath.Join(dir, "patch.txt"), patchText, 0o644); err != nil {
+		return nil, err
+	}
+	if recover != "resume" {
+		recover = "rollback"
+	}
+	j := &Journal{Job: job, Repo: repo, PreHead: preHead, Recover: recover, Phase: phaseOps, Current: -1, Started: time.Now(), dir: dir}
+	return j, j.save()
+}
+
+// loadJournal 读取遗留的任务日志；不存在返回 (nil, nil)
+func loadJournal(patchDir string) (*Journal, error) {
+	dir := filepath.Join(patchDir, journalDir)
+	b, err := os.ReadFile(filepath.Join(dir, "journal.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	j := &Journal{}
+	if err := json.Unmarshal(b, j); err != nil {
+		return nil, fmt.Errorf("journal 损坏：%w", err)
+	}
+	j.dir = dir
+	return j, nil
+}
+
+// save 原子落盘（tmp → fsync → rename）
+func (j *Journal) save() error {
+	if j == nil {
+		return nil
+	}
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(j.dir, "journal.json.tmp")
+	if err := writeFileSync(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(j.dir, "journal.json"))
+}
+
+// beginOp 记录即将执行的指令，并备份它会修改的文件
+func (j *Journal) beginOp(i int, op *FileOp, git gitops.GitBackend) error {
+	if j == nil {
+		return nil
+	}
+	j.Current = i
+	for _, rel := range opTouchedPaths(j.Repo, op, git) {
+		bk := Backup{Op: i, Path: rel}
+		abs := filepath.Join(j.Repo, rel)
+		fi, err := os.Stat(abs)
+		switch {
+		case err != nil && os.IsNotExist(err):
+			bk.Absent = true
+		case err != nil:
+			return err
+		case fi.IsDir():
+			continue // 目录类操作不备份，续跑时由回滚兜底
+		default:
+			data, err := os.ReadFile(abs)
+			if err != nil {
+				return err
+			}
+			bk.Copy = filepath.Join("backups", strconv.Itoa(i)+"-"+strconv.Itoa(len(j.Backups)))
+			bk.Mode = uint32(fi.Mode().Perm())
+			if err := writeFileSync(filepath.Join(j.dir, bk.Copy), data, 0o600); err != nil {
+				return err
+			}
+		}
+		j.Backups = append(j.Backups, bk)
+	}
+	return j.save()
+}
+
+// doneOp 标记指令完成
+func (j *Journal) doneOp(i int) error {
+	if j == nil {
+		return nil
+	}
+	j.Done = append(j.Done, i)
+	j.Current = -1
+	return j.save()
+}
+
+// setPhase 切换阶段
+func (j *Journal) setPhase(p string) error {
+	if j == nil {
+		return nil
+	}
+	j.Phase = p
+	return j.save()
+}
+
+// finish 任务结束（成功或已回滚），删除日志
+func (j *Journal) finish() {
+	if j == nil {
+		return
+	}
+	_ = os.RemoveAll(j.dir)
+}
+
+// restoreOp 把第 i 条指令涉及的文件恢复到执行前状态；无法确定涉及文件时返回错误
+func (j *Journal) restoreOp(i int, op *FileOp, git gitops.GitBackend) error {
+	if opTouchedPaths(j.Repo, op, git) == nil {
+		return fmt.Errorf("%s 涉及的文件无法确定，不能续跑", op.Cmd)
+	}
+	for _, bk := range j.Backups {
+		if bk.Op != i {
+			continue
+		}
+		abs := filepath.Join(j.Repo, bk.Path)
+		if bk.Absent {
+			if err := os.RemoveAll(abs); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(j.dir, bk.Copy))
+		if err != nil {
+			return fmt.Errorf("备份缺失 %s：%w", bk.Path, err)
+		}
+		if err := fileops.WriteFileAtomic(abs, data, os.FileMode(bk.Mode)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// opTouchedPaths 指令会修改的文件（相对路径）；nil 表示无法静态确定（如 git.*）
+// 多文件目标按当前工作区展开（与随后执行时一致）
+func opTouchedPaths(repo string, op *FileOp, git gitops.GitBackend) []string {
+	switch {
+	case isMultiTarget(repo, op):
+		files, err := expandTargets(repo, op, git)
+		if err != nil {
+			return nil
+		}
+		return files
+	case op.Cmd == "file.move":
+		return []string{op.Path, op.Args["to"]}
+	case isContentOp(op.Cmd), op.Cmd == "file.delete", op.Cmd == "file.chmod",
+		op.Cmd == "file.binary", op.Cmd == "file.image":
+		return []string{op.Path}
+	}
+	return nil
+}
+
+// RecoverJournal 启动时检测被中断的任务：按 recover 策略回滚或续跑，并报告处理结果。
+// 返回被处理任务的 job（即补丁 md5 前 8 位，便于调用方记为已处理）；无中断任务返回 ""。
+func RecoverJournal(patchDir string, git gitops.GitBackend, logger *DualLogger) string {
+	j, err := loadJournal(patchDir)
+	if err != nil {
+		logger.Log("⚠️ 读取任务日志失败：%v（已丢弃，无法自动恢复）", err)
+		_ = os.RemoveAll(filepath.Join(patchDir, journalDir))
+		return ""
+	}
+	if j == nil {
+		return ""
+	}
+	logger.Log("♻️ 检测到被中断的任务 job=%s（阶段=%s，已完成 %d 条指令，策略=%s）", j.Job, j.Phase, len(j.Done), j.Recover)
+
+	if j.Recover == "resume" {
+		err := resumeJournal(j, git, logger)
+		if err == nil {
+			logger.Log("♻️ 中断任务 job=%s 已续跑完成", j.Job)
+			j.finish()
+			return j.Job
+		}
+		logger.Log("⚠️ 续跑失败：%v → 改为整体回滚", err)
+	}
+	if err := rollbackJournal(j, git); err != nil {
+		// 现场未能恢复：保留任务日志，下次启动时重试；本次仍返回 job，避免同一补丁在未知状态上重放
+		logger.Log("❌ 中断任务 job=%s 回滚失败：%v（任务日志已保留，下次启动时重试）", j.Job, err)
+		return j.Job
+	}
+	logger.Log("♻️ 中断任务 job=%s 已整体回滚到 %s", j.Job, shortSHA(j.PreHead))
+	j.finish()
+	return j.Job
+}
+
+// rollbackJournal 整体回滚到任务开始前的 HEAD（与事务回滚一致）
+func rollbackJournal(j *Journal, git gitops.GitBackend) error {
+	if err := git.Reset(j.Repo, "hard", j.PreHead); err != nil {
+		return err
+	}
+	return git.Clean(j.Repo)
+}
+
+// resumeJournal 续跑：恢复中断指令涉及的文件，从中断处继续执行剩余指令并提交推送
+func resumeJournal(j *Journal, git gitops.GitBackend, logger *DualLogger) error {
+	text, err := os.ReadFile(filepath.Join(j.dir, "patch.txt"))
+	if err != nil {
+		return fmt.Errorf("补丁副本缺失：%w", err)
+	}
+	patch, err := ParsePatch(string(text), eofMark)
+	if err != nil {
+		return err
+	}
+	head, _ := git.RevParse(j.Repo, "HEAD")
+
+	switch j.Phase {
+	case phasePush:
+		return pushBatch(j.Repo, git, logger.Log)
+	case phaseCommit:
+		if head != j.PreHead { // 提交已完成，仅差推送
+			return pushBatch(j.Repo, git, logger.Log)
+		}
+		committed, err := commitBatch(j.Repo, patch, git, logger.Log)
+		if err != nil || !committed {
+			return err
+		}
+		return pushBatch(j.Repo, git, logger.Log)
+	}
+
+	if head != j.PreHead {
+		return fmt.Errorf("HEAD 已变化（%s → %s）", shortSHA(j.PreHead), shortSHA(head))
+	}
+	start := len(j.Done)
+	if j.Current >= len(patch.Ops) || start > len(patch.Ops) {
+		return errors.New("任务日志与补丁副本不一致")
+	}
+	if j.Current >= 0 {
+		if err := j.restoreOp(j.Current, patch.Ops[j.Current], git); err != nil {
+			return err
+		}
+		start = j.Current
+	}
+	logger.Log("♻️ 从第 %d 条指令续跑（共 %d 条）", start+1, len(patch.Ops))
+	if !runBatch(logger, j.Repo, patch, git, j, start) {
+		return errors.New("续跑执行失败")
+	}
+	return nil
+}
+
+// writeFileSync 写文件并 fsync，保证日志/备份在崩溃后可用
+func writeFileSync(path string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
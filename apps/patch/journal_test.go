@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"xgit/apps/patch/gitops"
+)
+
+// 两条指令：a.txt 追加一行，b.txt 追加一行
+func journalPatch(recover string) string {
+	head := ""
+	if recover != "" {
+		head = "recover: " + recover + "\n\n"
+	}
+	return head + "=== file.append: \"a.txt\" ===\ntwo\n=== end ===\n\n=== file.append: \"b.txt\" ===\nbee\n=== end ===\n" + eofMark + "\n"
+}
+
+// interruptedJob 模拟 runBatch 执行到一半被杀：#1 已完成，#2 开始后只写了一半
+func interruptedJob(t *testing.T, recover string) (patchDir, repo string, git *gitops.FakeBackend) {
+	t.Helper()
+	repo = newTestRepo(t, map[string]string{"a.txt": "one\n", "b.txt": "b\n"})
+	git = newTestFake(repo, "a.txt", "b.txt")
+	patchDir = t.TempDir()
+	text := journalPatch(recover)
+	patch, err := ParsePatch(text, eofMark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jr, err := newJournal(patchDir, "job12345", repo, fakeHead, patch.Recover, []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jr.beginOp(0, patch.Ops[0], git); err != nil {
+		t.Fatal(err)
+	}
+	writeRepoFile(t, repo, "a.txt", "one\ntwo\n")
+	if err := jr.doneOp(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := jr.beginOp(1, patch.Ops[1], git); err != nil {
+		t.Fatal(err)
+	}
+	writeRepoFile(t, repo, "b.txt", "b\nbe")
+	return patchDir, repo, git
+}
+
+func writeRepoFile(t *testing.T, repo, rel, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo, rel), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readRepoFile(t *testing.T, repo, rel string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(repo, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestJournalRecordsProgressAndBackups(t *testing.T) {
+	patchDir, repo, git := interruptedJob(t, "resume")
+	j, err := loadJournal(patchDir)
+	if err != nil || j == nil {
+		t.Fatalf("任务日志未落盘：%v", err)
+	}
+	if j.Job != "job12345" || j.Repo != repo || j.PreHead != fakeHead || j.Recover != "resume" || j.Phase != phaseOps {
+		t.Errorf("任务日志头部不符：%+v", j)
+	}
+	if len(j.Done) != 1 || j.Done[0] != 0 || j.Current != 1 {
+		t.Errorf("进度不符：done=%v current=%d", j.Done, j.Current)
+	}
+	if len(j.Backups) != 2 || j.Backups[1].Path != "b.txt" {
+		t.Fatalf("备份不符：%+v", j.Backups)
+	}
+	if b, _ := os.ReadFile(filepath.Join(j.dir, j.Backups[1].Copy)); string(b) != "b\n" {
+		t.Errorf("b.txt 的备份应为执行前内容：%q", b)
+	}
+
+	// 执行前不存在的文件记为 Absent
+	op := &FileOp{Cmd: "file.write", Path: "c.txt", Args: map[string]string{}}
+	if err := j.beginOp(2, op, git); err != nil {
+		t.Fatal(err)
+	}
+	if bk := j.Backups[len(j.Backups)-1]; bk.Path != "c.txt" || !bk.Absent {
+		t.Errorf("新文件应记为 Absent：%+v", bk)
+	}
+}
+
+func TestRecoverJournalRollback(t *testing.T) {
+	patchDir, _, git := interruptedJob(t, "")
+	logger, buf := testLogger()
+	if job := RecoverJournal(patchDir, git, logger); job != "job12345" {
+		t.Fatalf("job = %q", job)
+	}
+	ops := git.Ops()
+	if !hasOp(ops, "reset hard "+fakeHead) || !hasOp(ops, "clean") {
+		t.Errorf("应整体回滚到 preHead：%v", ops)
+	}
+	if hasOp(ops, "commit") || hasOp(ops, "push") {
+		t.Errorf("回滚时不应提交 / 推送：%v", ops)
+	}
+	if j, _ := loadJournal(patchDir); j != nil {
+		t.Error("回滚完成后应删除任务日志")
+	}
+	if !strings.Contains(buf.String(), "已整体回滚") {
+		t.Errorf("日志缺少回滚结果：\n%s", buf)
+	}
+}
+
+func TestRecoverJournalResumeInOps(t *testing.T) {
+	patchDir, repo, git := interruptedJob(t, "resume")
+	logger, buf := testLogger()
+	if job := RecoverJournal(patchDir, git, logger); job != "job12345" {
+		t.Fatalf("job = %q\n%s", job, buf)
+	}
+	if a, b := readRepoFile(t, repo, "a.txt"), readRepoFile(t, repo, "b.txt"); a != "one\ntwo\n" || b != "b\nbee\n" {
+		t.Errorf("续跑结果应与一次跑完一致：a=%q b=%q", a, b)
+	}
+	ops := git.Ops()
+	if hasOp(ops, "reset hard "+fakeHead) || !hasOp(ops, "commit") || !hasOp(ops, "push") {
+		t.Errorf("续跑应提交并推送且不回滚：%v", ops)
+	}
+	if !strings.Contains(buf.String(), "从第 2 条指令续跑") {
+		t.Errorf("应从中断的指令续跑：\n%s", buf)
+	}
+	if j, _ := loadJournal(patchDir); j != nil {
+		t.Error("续跑完成后应删除任务日志")
+	}
+}
+
+// HEAD 已变化时不能续跑，改为整体回滚
+func TestRecoverJournalResumeFallsBackToRollback(t *testing.T) {
+	patchDir, repo, git := interruptedJob(t, "resume")
+	git.Heads[repo] = "moved00000000000000000000000000000000000"
+	logger, buf := testLogger()
+	RecoverJournal(patchDir, git, logger)
+	if !strings.Contains(buf.String(), "改为整体回滚") || !hasOp(git.Ops(), "reset hard "+fakeHead) {
+		t.Errorf("续跑失败应回滚：%v\n%s", git.Ops(), buf)
+	}
+	if hasOp(git.Ops(), "commit") {
+		t.Errorf("回滚时不应提交：%v", git.Ops())
+	}
+}
+
+// 回滚本身失败：保留任务日志，下次启动重试
+func TestRecoverJournalKeepsJournalWhenRollbackFails(t *testing.T) {
+	patchDir, _, git := interruptedJob(t, "")
+	git.Fail("reset", errors.New("index.lock exists"))
+	logger, buf := testLogger()
+	RecoverJournal(patchDir, git, logger)
+	if !strings.Contains(buf.String(), "回滚失败") || strings.Contains(buf.String(), "已整体回滚") {
+		t.Errorf("日志应报告回滚失败：\n%s", buf)
+	}
+	if j, _ := loadJournal(patchDir); j == nil {
+		t.Fatal("回滚失败时应保留任务日志")
+	}
+
+	delete(git.Script, "reset")
+	RecoverJournal(patchDir, git, logger)
+	if j, _ := loadJournal(patchDir); j != nil {
+		t.Error("重试回滚成功后应删除任务日志")
+	}
+}
+
+func TestRecoverJournalResumeInCommitAndPush(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		phase      string
+		committed  bool // 中断前提交是否已完成（HEAD 已前进）
+		wantCommit bool
+	}{
+		{"提交前中断", phaseCommit, false, true},
+		{"提交后中断", phaseCommit, true, false},
+		{"推送中中断", phasePush, true, false},
+	} {
+		patchDir, repo, git := interruptedJob(t, "resume")
+		j, _ := loadJournal(patchDir)
+		j.Done, j.Current = []int{0, 1}, -1
+		writeRepoFile(t, repo, "b.txt", "b\nbee\n")
+		if err := j.setPhase(c.phase); err != nil {
+			t.Fatal(err)
+		}
+		if c.committed {
+			git.Heads[repo] = "next000000000000000000000000000000000000"
+		}
+		logger, buf := testLogger()
+		RecoverJournal(patchDir, git, logger)
+		ops := git.Ops()
+		if hasOp(ops, "commit") != c.wantCommit || !hasOp(ops, "push origin HEAD") {
+			t.Errorf("%s：%v\n%s", c.name, ops, buf)
+		}
+		if hasOp(ops, "reset hard") {
+			t.Errorf("%s：不应回滚：%v", c.name, ops)
+		}
+		if j, _ := loadJournal(patchDir); j != nil {
+			t.Errorf("%s：完成后应删除任务日志", c.name)
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"xgit/apps/patch/gitops"
 )
 
 const (
@@ -58,8 +60,13 @@ func main() {
 		defer func() { _ = os.Remove(pidFile) }()
 
 		w := NewWatcher(patchFile, eofMark, logger)
-
+		git := gitops.NewExecBackend()
+		// 上次被中断的任务：先于任何清理执行回滚/续跑，避免 CleanAtStart 抹掉现场
 		lastHash := loadLastHash(baseDir)
+		if job := RecoverJournal(baseDir, git, logger); job != "" {
+			lastHash = job // 已回滚/续跑的补丁不再自动重放
+			saveLastHash(baseDir, job)
+		}
 		for {
 			ok, size, h8 := w.StableAndEOF()
 			if ok && h8 != "" && h8 != lastHash {
@@ -81,7 +88,7 @@ func main() {
 					continue
 				}
 
-				ApplyOnce(logger, "", patch, patchFile)
+				ApplyOnce(logger, "", patch, patchFile, git)
 
 				lastHash = h8
 				saveLastHash(baseDir, h8)
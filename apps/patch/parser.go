@@ -21,6 +21,10 @@ type Patch struct {
     CommitMsg string // 可选：提交说明
     Author    string // 可选：提交作者（形如 "Name <email>"）
 	Repo      string // 仓库名
+	Idempotent string // 可选：幂等模式 on|strict|off（作为各指令 idempotent= 的缺省值）
+	Base       string // 可选：补丁生成时所基于的提交（base: <sha>）
+	Stale      string // 可选：目标与 base 不一致时的策略 refuse|merge（作为各指令 stale= 的缺省值）
+	Recover    string // 可选：patchd 中途被杀后的处理策略 rollback|resume（默认 rollback）
 }
 
 // XGIT:END PARSER TYPES
@@ -43,12 +47,13 @@ func ParsePatch(data string, eof string) (*Patch, error) {
 	lines := strings.Split(text, "\n")
 
 	// 头部匹配
-	reHead := regexp.MustCompile(`^===\s*([a-z]+(?:\.[a-z_]+)?)\s*:\s*(.*?)\s*===\s*$`)
-	reKV := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*:\s*(.*)$`) // 顶层 KV：commitmsg/author/repo
+	reHead := regexp.MustCompile(`^===\s*([a-z]+(?:\.[a-z_-]+)*)\s*:\s*(.*?)\s*===\s*$`)
+	reKV := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*:\s*(.*)$`) // 顶层 KV：commitmsg/author/repo/idempotent/base/stale/recover
 
 	// 参数识别（块内）
-	reParamKV := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
-	reBlkStart := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)<$`)
+	// 参数键允许连字符（start-keys / expect-sha256 等）
+	reParamKV := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_-]*)\s*=\s*(.*)$`)
+	reBlkStart := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_-]*)<$`)
 	endMarker := func(key string) string { return ">" + key }
 
 	var (
@@ -107,6 +112,18 @@ func ParsePatch(data string, eof string) (*Patch, error) {
 				case "repo":
 					p.Repo = val
 					continue
+				case "idempotent":
+					p.Idempotent = strings.ToLower(val)
+					continue
+				case "base":
+					p.Base = val
+					continue
+				case "stale":
+					p.Stale = strings.ToLower(val)
+					continue
+				case "recover":
+					p.Recover = strings.ToLower(val)
+					continue
 				}
 			}
 		}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testEOF = "=== PATCH EOF ==="
+
+func TestParsePatch(t *testing.T) {
+	src := strings.Join([]string{
+		"repo: demo",
+		"commitmsg: feat: x",
+		"author: A <a@b.c>",
+		"idempotent: STRICT",
+		"base: abc123",
+		"stale: Merge",
+		"recover: resume",
+		"",
+		`=== line.insert: "dir with space/a.go" ===`,
+		"keys=func main",
+		"Start-Keys=x",
+		"context<",
+		" \tfoo()",
+		"",
+		" bar()",
+		">context",
+		"body line 1",
+		"k=v inside body",
+		"=== end ===",
+		"",
+		`=== file.delete: "b.txt" ===`,
+		"=== end ===",
+		testEOF,
+		"",
+	}, "\r\n")
+	p, err := ParsePatch(src, testEOF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Repo != "demo" || p.CommitMsg != "feat: x" || p.Author != "A <a@b.c>" || p.Idempotent != "strict" ||
+		p.Base != "abc123" || p.Stale != "merge" || p.Recover != "resume" {
+		t.Fatalf("头部字段 = %+v", p)
+	}
+	if len(p.Ops) != 2 {
+		t.Fatalf("指令数 = %d", len(p.Ops))
+	}
+	op := p.Ops[0]
+	if op.Cmd != "line.insert" || op.Path != "dir with space/a.go" {
+		t.Fatalf("块头 = %q %q", op.Cmd, op.Path)
+	}
+	want := map[string]string{"keys": "func main", "start-keys": "x", "context": "\tfoo()\n\nbar()\n"}
+	for k, v := range want {
+		if op.Args[k] != v {
+			t.Errorf("Args[%s] = %q，期望 %q", k, op.Args[k], v)
+		}
+	}
+	if op.Body != "body line 1\nk=v inside body\n" {
+		t.Errorf("正文 = %q（参数区在第一行非参数处结束）", op.Body)
+	}
+	if p.Ops[1].Cmd != "file.delete" || p.Ops[1].Body != "" {
+		t.Errorf("第二条指令 = %+v", p.Ops[1])
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	cases := map[string]string{
+		"缺少 EOF":     "=== file.delete: \"a\" ===\n=== end ===\n",
+		"路径未加引号":     "=== file.delete: a ===\n=== end ===\n" + testEOF,
+		"多行块未结束":     "=== file.write: \"a\" ===\nwith<\n x\n=== end ===\n" + testEOF,
+		"多行块正文未缩进":   "=== file.write: \"a\" ===\nwith<\nx\n>with\n=== end ===\n" + testEOF,
+		"EOF 之后还有内容": "=== file.delete: \"a\" ===\n=== end ===\n" + testEOF + "\ntrailing\n",
+	}
+	for name, src := range cases {
+		if _, err := ParsePatch(src, testEOF); err == nil {
+			t.Errorf("%s：应报错", name)
+		}
+	}
+}
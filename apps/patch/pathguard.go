@@ -0,0 +1,82 @@
+package main
+
+// XGIT:BEGIN FILE-HEADER
+// pathguard.go — 指令路径安全校验：目标路径必须位于仓库内，且不得触及 .git 与受保护路径
+// 批次开始前逐条校验并列出全部违规指令；执行每条指令前再校验一次（防止批内新建的符号链接绕过）
+// XGIT:END FILE-HEADER
+
+import (
+	"fmt"
+	"strings"
+
+	"xgit/apps/patch/fileops"
+	"xgit/apps/patch/gitops"
+)
+
+// opPaths 指令涉及的路径（角色 → 路径）；git.diff 取补丁中的全部文件，其它 git.* 不涉及路径
+func opPaths(repo string, op *FileOp) [][2]string {
+	switch {
+	case op.Cmd == "git.diff":
+		var out [][2]string
+		for _, p := range gitops.DiffPaths(op.Body) {
+			out = append(out, [2]string{"补丁路径", p})
+		}
+		return out
+	case strings.HasPrefix(op.Cmd, "git."):
+		return nil
+	case op.Cmd == "repo.replace", op.Cmd == "go.rename": // 头部路径可省略（整个仓库 / 由 pkg= 指定）
+		if d := strings.TrimSpace(op.Path); d == "" || d == "." {
+			return nil
+		}
+	case isMultiTarget(repo, op):
+		var out [][2]string
+		for _, p := range targetPatterns(op) {
+			out = append(out, [2]string{"目标模式", p})
+		}
+		return out
+	case op.Cmd == "file.move", op.Cmd == "go.move-package":
+		return [][2]string{{"源路径", op.Path}, {"目标路径", op.Args["to"]}}
+	}
+	return [][2]string{{"路径", op.Path}}
+}
+
+// checkOpPaths 校验单条指令的全部路径
+func checkOpPaths(g *fileops.PathGuard, repo string, op *FileOp) error {
+	for _, rp := range opPaths(repo, op) {
+		if rp[0] == "目标路径" && strings.TrimSpace(rp[1]) == "" {
+			continue // 缺少目标由指令自身报错
+		}
+		if _, err := g.Resolve(rp[1]); err != nil {
+			return fmt.Errorf("%s: %s %q 被拒绝：%v", op.Cmd, rp[0], rp[1], err)
+		}
+	}
+	return nil
+}
+
+// guardOp 执行前校验（每条指令重新读取 .xgit/protected）
+func guardOp(repo string, op *FileOp) error {
+	g, err := fileops.NewPathGuard(repo)
+	if err != nil {
+		return fmt.Errorf("路径校验初始化失败：%w", err)
+	}
+	return checkOpPaths(g, repo, op)
+}
+
+// guardBatch 批次开始前校验全部指令，逐条记录被拒绝的指令
+func guardBatch(repo string, ops []*FileOp, start int, logf func(string, ...any)) error {
+	g, err := fileops.NewPathGuard(repo)
+	if err != nil {
+		return fmt.Errorf("路径校验初始化失败：%w", err)
+	}
+	bad := 0
+	for i := start; i < len(ops); i++ {
+		if err := checkOpPaths(g, repo, ops[i]); err != nil {
+			logf("🚫 #%d %v", i+1, err)
+			bad++
+		}
+	}
+	if bad > 0 {
+		return fmt.Errorf("路径安全校验未通过：%d 条指令被拒绝，补丁未执行", bad)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package preflight
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFile 目标仓库的预检配置（可选）
+//
+// 格式（每行一条，# 开头为注释）：
+//
+//	<键> = <值>
+//
+// 示例：
+//
+//	go.imports = fix
+const ConfigFile = ".xgit/preflight"
+
+// Config 预检配置（键统一小写）
+type Config map[string]string
+
+// LoadConfig 读取 repo/.xgit/preflight；不存在或读取失败返回空配置（预检保持默认行为）
+func LoadConfig(repo string) Config {
+	cfg := Config{}
+	f, err := os.Open(filepath.Join(repo, ConfigFile))
+	if err != nil {
+		return cfg
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		cfg[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return cfg
+}
+
+// Get 读取配置值，缺省返回 def
+func (c Config) Get(key, def string) string {
+	if v, ok := c[strings.ToLower(key)]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// List 读取逗号/空白分隔的列表值
+func (c Config) List(key string) []string {
+	return strings.FieldsFunc(c.Get(key, ""), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
@@ -0,0 +1,148 @@
+package preflight
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 配置文件预检：YAML / TOML / INI / dotenv 只做语法校验，不重排（避免丢失注释与手工排版）
+
+type yamlRunner struct{}
+
+func (yamlRunner) Name() string { return "yaml" }
+func (yamlRunner) Match(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+func (yamlRunner) Run(repo, rel string, logf Logf) (bool, error) {
+	return checkSyntax(repo, rel, "yaml", ValidateYAML, logf)
+}
+
+type tomlRunner struct{}
+
+func (tomlRunner) Name() string           { return "toml" }
+func (tomlRunner) Match(path string) bool { return strings.EqualFold(filepath.Ext(path), ".toml") }
+func (tomlRunner) Run(repo, rel string, logf Logf) (bool, error) {
+	return checkSyntax(repo, rel, "toml", func(src []byte) error {
+		_, err := ParseTOMLDoc(src)
+		return err
+	}, logf)
+}
+
+type iniRunner struct{}
+
+func (iniRunner) Name() string { return "ini" }
+func (iniRunner) Match(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ini") || IsEnvFile(path)
+}
+func (iniRunner) Run(repo, rel string, logf Logf) (bool, error) {
+	env := IsEnvFile(rel)
+	return checkSyntax(repo, rel, "ini", func(src []byte) error { return ValidateINI(src, env) }, logf)
+}
+
+func init() {
+	Register(yamlRunner{})
+	Register(tomlRunner{})
+	Register(iniRunner{})
+}
+
+// checkSyntax 读取文件（去 BOM、统一 LF）后校验，失败即报错
+func checkSyntax(repo, rel, name string, check func([]byte) error, logf Logf) (bool, error) {
+	orig, err := os.ReadFile(filepath.Join(repo, rel))
+	if err != nil {
+		return false, err
+	}
+	src := normalizeLF(bytes.TrimPrefix(orig, []byte("\xef\xbb\xbf")))
+	if err := check(src); err != nil {
+		logf("❌ preflight(%s): %s 语法错误：%v", name, rel, err)
+		return false, err
+	}
+	logf("🧪 preflight(%s): %s 语法正确", name, rel)
+	return false, nil
+}
+
+// IsEnvFile .env / .env.local / xxx.env 等按 dotenv 方言处理
+func IsEnvFile(rel string) bool {
+	base := strings.ToLower(filepath.Base(rel))
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ValidateINI 逐行校验：INI 允许 [节]、key=value / key: value、缩进续行与 # ; 注释；
+// dotenv 只允许 (export )KEY=value 与 # 注释，引号值须闭合（双引号值可跨行）
+func ValidateINI(src []byte, env bool) error {
+	lines := strings.Split(string(src), "\n")
+	entry := false // 上一有效行是键值（INI 续行）
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		t := strings.TrimSpace(l)
+		if t == "" || t[0] == '#' || (!env && t[0] == ';') {
+			continue
+		}
+		if env {
+			body := strings.TrimPrefix(t, "export ")
+			k, v, ok := strings.Cut(body, "=")
+			if !ok || !envKey.MatchString(strings.TrimSpace(k)) {
+				return fmt.Errorf("第 %d 行：期望 KEY=value", i+1)
+			}
+			v = strings.TrimSpace(v)
+			if v == "" || (v[0] != '"' && v[0] != '\'') {
+				continue
+			}
+			q := v[0]
+			rest := v[1:]
+			for j := i; ; {
+				if envQuoteClose(rest, q) >= 0 {
+					i = j
+					break
+				}
+				if q == '\'' || j+1 >= len(lines) {
+					return fmt.Errorf("第 %d 行：引号未闭合", i+1)
+				}
+				j++
+				rest = lines[j]
+			}
+			continue
+		}
+		switch {
+		case t[0] == '[':
+			if !strings.HasSuffix(t, "]") || strings.TrimSpace(t[1:len(t)-1]) == "" {
+				return fmt.Errorf("第 %d 行：节名格式应为 [name]", i+1)
+			}
+			entry = false
+		case entry && (l[0] == ' ' || l[0] == '\t') && !iniLooksLikeEntry(t):
+			// 续行（缩进的 key=value 是新的键，如 git config 风格的缩进键）
+		default:
+			cut := strings.IndexAny(t, "=:")
+			if cut <= 0 || strings.TrimSpace(t[:cut]) == "" {
+				return fmt.Errorf("第 %d 行：期望 key=value 或 [section]", i+1)
+			}
+			entry = true
+		}
+	}
+	return nil
+}
+
+// iniLooksLikeEntry t（已去首尾空白）是否为 key=value / key: value
+func iniLooksLikeEntry(t string) bool {
+	cut := strings.IndexAny(t, "=:")
+	return cut > 0 && strings.TrimSpace(t[:cut]) != ""
+}
+
+// envQuoteClose 双引号支持 \" 转义；返回闭合引号下标，未闭合返回 -1
+func envQuoteClose(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,24 @@
+package preflight
+
+import "testing"
+
+func TestValidateINI(t *testing.T) {
+	cases := []struct {
+		name, src string
+		env, ok   bool
+	}{
+		{"git config 缩进键", "[core]\n\tbare = false\n\tfilemode = true\n", false, true},
+		{"续行", "[a]\nx = one\n  two\n", false, true},
+		{"冒号分隔与注释", "; c\n# c\n[a]\nx: 1\n", false, true},
+		{"节名为空", "[ ]\nx = 1\n", false, false},
+		{"不是键值", "[a]\njust text\n", false, false},
+		{"dotenv", "# c\nexport A=1\nB=\"multi\nline\"\n", true, true},
+		{"dotenv 单引号未闭合", "A='x\n", true, false},
+		{"dotenv 非法键", "A B=1\n", true, false},
+	}
+	for _, c := range cases {
+		if err := ValidateINI([]byte(c.src), c.env); (err == nil) != c.ok {
+			t.Errorf("%s：err = %v", c.name, err)
+		}
+	}
+}
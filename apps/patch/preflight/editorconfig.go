@@ -0,0 +1,168 @@
+package preflight
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//
+// .editorconfig 缩进读取（只关心 indent_style / indent_size / tab_width）
+//   - 从文件所在目录向上查找，直到仓库根或遇到 root = true
+//   - 近处的文件覆盖远处的，同一文件中靠后的段覆盖靠前的（与 EditorConfig 规范一致）
+//   - 段名支持 * / ** / ? / [..] / {a,b}；不含 / 的段名匹配任意层级的文件名
+//
+
+// EditorConfigIndent 读取 rel 适用的缩进单位；未配置返回 ("", false)
+func EditorConfigIndent(repo, rel string) (string, bool) {
+	var chain []string // 由远到近
+	dir := filepath.Dir(filepath.Join(repo, filepath.FromSlash(rel)))
+	for {
+		p := filepath.Join(dir, ".editorconfig")
+		if _, err := os.Stat(p); err == nil {
+			chain = append([]string{p}, chain...)
+			if editorConfigIsRoot(p) {
+				break
+			}
+		}
+		if r, err := filepath.Rel(repo, dir); err != nil || r == "." || strings.HasPrefix(r, "..") {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	props := map[string]string{}
+	abs := filepath.Join(repo, filepath.FromSlash(rel))
+	for _, p := range chain {
+		target, _ := filepath.Rel(filepath.Dir(p), abs)
+		readEditorConfig(p, filepath.ToSlash(target), props)
+	}
+	style, size := props["indent_style"], props["indent_size"]
+	if size == "tab" {
+		style = "tab"
+	}
+	switch style {
+	case "tab":
+		return "\t", true
+	case "space":
+		if n, err := strconv.Atoi(size); err == nil && n > 0 {
+			return strings.Repeat(" ", n), true
+		}
+		if n, err := strconv.Atoi(props["tab_width"]); err == nil && n > 0 {
+			return strings.Repeat(" ", n), true
+		}
+		return "  ", true
+	}
+	if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		return strings.Repeat(" ", n), true
+	}
+	return "", false
+}
+
+func editorConfigIsRoot(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") {
+			return false
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && strings.EqualFold(strings.TrimSpace(k), "root") {
+			return strings.EqualFold(strings.TrimSpace(v), "true")
+		}
+	}
+	return false
+}
+
+// readEditorConfig 把匹配 target（相对该 .editorconfig 所在目录）的段中的属性写入 props
+func readEditorConfig(p, target string, props map[string]string) {
+	f, err := os.Open(p)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	active := false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			active = editorConfigMatch(line[1:len(line)-1], target)
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && active {
+			props[strings.ToLower(strings.TrimSpace(k))] = strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+}
+
+// editorConfigMatch 段名匹配：转成正则（** 跨目录，* / ? 不跨目录，{a,b} 为多选）
+func editorConfigMatch(glob, target string) bool {
+	var sb strings.Builder
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("^(?:.*/)?")
+	}
+	depth := 0
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(strings.Replace(glob[i:i+end+1], "[!", "[^", 1))
+			i += end
+		case '{':
+			sb.WriteString("(?:")
+			depth++
+		case '}':
+			if depth == 0 {
+				sb.WriteString(`\}`)
+				continue
+			}
+			sb.WriteString(")")
+			depth--
+		case ',':
+			if depth == 0 {
+				sb.WriteString(",")
+				continue
+			}
+			sb.WriteString("|")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if depth > 0 {
+		return false
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	return err == nil && re.MatchString(target)
+}
@@ -0,0 +1,102 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for rel, body := range files {
+		abs := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestEditorConfigIndent(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		rel   string
+		want  string // "" 表示未配置
+	}{
+		{"未配置", map[string]string{}, "a.json", ""},
+		{"通配段", map[string]string{".editorconfig": "[*]\nindent_style = space\nindent_size = 4\n"}, "a.json", "    "},
+		{"tab", map[string]string{".editorconfig": "[*]\nindent_style = tab\n"}, "a.json", "\t"},
+		{"indent_size = tab", map[string]string{".editorconfig": "[*]\nindent_size = tab\n"}, "a.json", "\t"},
+		{"space 缺 indent_size 用 tab_width", map[string]string{".editorconfig": "[*]\nindent_style = space\ntab_width = 8\n"}, "a.json", "        "},
+		{"靠后的段覆盖靠前的", map[string]string{".editorconfig": "[*]\nindent_size = 4\n\n[*.json]\nindent_size = 2\n"}, "a.json", "  "},
+		{"不匹配的段不生效", map[string]string{".editorconfig": "[*]\nindent_size = 4\n\n[*.md]\nindent_size = 2\n"}, "a.json", "    "},
+		{"{a,b} 多选", map[string]string{".editorconfig": "[*.{json,yml}]\nindent_size = 3\n"}, "x/a.yml", "   "},
+		{"不含 / 的段匹配任意层级", map[string]string{".editorconfig": "[*.json]\nindent_size = 3\n"}, "x/y/a.json", "   "},
+		{"含 / 的段按相对路径匹配", map[string]string{".editorconfig": "[/x/*.json]\nindent_size = 3\n"}, "y/x/a.json", ""},
+		{"* 不跨目录", map[string]string{".editorconfig": "[x/*.json]\nindent_size = 3\n"}, "x/y/a.json", ""},
+		{"** 跨目录", map[string]string{".editorconfig": "[x/**.json]\nindent_size = 3\n"}, "x/y/a.json", "   "},
+		{"近处覆盖远处", map[string]string{
+			".editorconfig":     "[*]\nindent_style = space\nindent_size = 4\n",
+			"sub/.editorconfig": "[*]\nindent_size = 2\n",
+		}, "sub/a.json", "  "},
+		{"近处未设置的属性沿用远处", map[string]string{
+			".editorconfig":     "[*]\nindent_style = tab\n",
+			"sub/.editorconfig": "[*.md]\nindent_size = 2\n",
+		}, "sub/a.json", "\t"},
+		{"root = true 截断向上查找", map[string]string{
+			".editorconfig":     "[*]\nindent_style = tab\n",
+			"sub/.editorconfig": "root = true\n\n[*.md]\nindent_size = 2\n",
+		}, "sub/a.json", ""},
+		{"近处段名相对其所在目录", map[string]string{
+			"sub/.editorconfig": "[/a.json]\nindent_size = 3\n",
+		}, "sub/a.json", "   "},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := writeTree(t, c.files)
+			got, ok := EditorConfigIndent(repo, c.rel)
+			if got != c.want || ok != (c.want != "") {
+				t.Fatalf("EditorConfigIndent = %q, %v，期望 %q", got, ok, c.want)
+			}
+		})
+	}
+}
+
+// 文件已有缩进 → .editorconfig → 2 空格
+func TestJSONIndentPrecedence(t *testing.T) {
+	repo := writeTree(t, map[string]string{".editorconfig": "[*.json]\nindent_style = tab\n"})
+	if got := JSONIndent(repo, "a.json", []byte("{\n    \"a\": 1\n}\n")); got != "    " {
+		t.Errorf("文件已有缩进应优先：%q", got)
+	}
+	if got := JSONIndent(repo, "a.json", []byte("{\"a\": 1}\n")); got != "\t" {
+		t.Errorf("无缩进时应读 .editorconfig：%q", got)
+	}
+	if got := JSONIndent(t.TempDir(), "a.json", []byte("{}")); got != "  " {
+		t.Errorf("缺省应为 2 空格：%q", got)
+	}
+}
+
+func TestIsJSONC(t *testing.T) {
+	repo := writeTree(t, map[string]string{ConfigFile: "json.jsonc = tsconfig*.json, .vscode/*.json\n"})
+	for rel, want := range map[string]bool{
+		"a.jsonc":                 true,
+		"x/A.JSONC":               true,
+		"tsconfig.json":           true,
+		"web/tsconfig.base.json":  true,
+		".vscode/settings.json":   true,
+		"x/.vscode/settings.json": false,
+		"package.json":            false,
+	} {
+		if got := IsJSONC(repo, rel); got != want {
+			t.Errorf("IsJSONC(%q) = %v", rel, got)
+		}
+	}
+	if IsJSONC(t.TempDir(), "tsconfig.json") {
+		t.Error("未配置 json.jsonc 时 tsconfig.json 按严格 JSON 处理")
+	}
+}
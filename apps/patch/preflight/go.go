@@ -5,6 +5,7 @@ import (
 	"go/format"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -43,6 +44,19 @@ func (goFmtRunner) Run(repo, rel string, logf Logf) (bool, error) {
 	in = bytes.TrimRight(in, "\n")
 	in = append(in, '\n')
 
+	// —— 可选：import 自动修复（.xgit/preflight 中 go.imports = fix），须在 gofmt 之前 —— //
+	if strings.EqualFold(LoadConfig(repo).Get("go.imports", "off"), "fix") {
+		fixed, added, removed, err := FixImports(in, SiblingNames(abs))
+		if err != nil {
+			logf("❌ preflight(go): %s import 自动修复失败：%v", rel, err)
+			return false, err
+		}
+		if len(added)+len(removed) > 0 {
+			logf("🛠️ preflight(go): %s 补全 import %v，移除未使用 import %v", rel, added, removed)
+		}
+		in = fixed
+	}
+
 	// —— gofmt —— //
 	formatted, err := format.Source(in)
 	if err != nil {
@@ -66,7 +80,7 @@ func (goFmtRunner) Run(repo, rel string, logf Logf) (bool, error) {
 		return false, nil
 	}
 
-	if err := atomicWrite(abs, out, mode, mtime); err != nil {
+	if err := AtomicWrite(abs, out, mode, mtime); err != nil {
 		return false, err
 	}
 	logf("🛠️ preflight(go): 规范化末尾换行并格式化 %s", rel)
@@ -0,0 +1,305 @@
+package preflight
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//
+// Go import 管理：基于 go/ast 定位，按源码区间做最小改动（排序/对齐交给随后的 gofmt）
+//   - AddImport / RemoveImport：供 go.import.add / go.import.remove 指令使用
+//   - FixImports：预检自动修复（.xgit/preflight 中 go.imports = fix）——补全缺失的标准库 import、移除未使用的 import
+//
+
+// AddImport 添加 import（name 为空表示不带别名）；已存在返回 changed=false
+func AddImport(src []byte, path, name string) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, imp := range f.Imports {
+		if importPath(imp) != path {
+			continue
+		}
+		if importName(imp) == name {
+			return src, false, nil
+		}
+		return nil, false, fmt.Errorf("%q 已以不同别名导入（%s）", path, importName(imp))
+	}
+
+	spec := strconv.Quote(path)
+	if name != "" {
+		spec = name + " " + spec
+	}
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+
+	var last *ast.GenDecl
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		last = gd
+		if len(gd.Specs) == 1 && importPath(gd.Specs[0].(*ast.ImportSpec)) == "C" {
+			continue // cgo 的 import "C" 必须独立
+		}
+		if gd.Lparen.IsValid() {
+			at := off(gd.Rparen)
+			if ls := lineStart(src, at); len(bytes.TrimSpace(src[ls:at])) == 0 {
+				return splice(src, ls, ls, "\t"+spec+"\n"), true, nil
+			}
+			return splice(src, at, at, "\n\t"+spec+"\n"), true, nil
+		}
+		old := string(src[off(gd.Specs[0].Pos()):off(gd.Specs[0].End())])
+		return splice(src, off(gd.Pos()), off(gd.End()), "import (\n\t"+old+"\n\t"+spec+"\n)"), true, nil
+	}
+	at := lineEnd(src, off(f.Name.End()))
+	if last != nil {
+		at = lineEnd(src, off(last.End()))
+	}
+	return splice(src, at, at, "\nimport "+spec+"\n"), true, nil
+}
+
+// RemoveImport 移除 import；不存在返回 changed=false
+func RemoveImport(src []byte, path string) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, s := range gd.Specs {
+			imp := s.(*ast.ImportSpec)
+			if importPath(imp) != path {
+				continue
+			}
+			var from, to int
+			if len(gd.Specs) == 1 { // 整个 import 声明一起删除
+				from, to = off(gd.Pos()), off(gd.End())
+				if gd.Doc != nil {
+					from = off(gd.Doc.Pos())
+				}
+			} else {
+				from, to = off(imp.Pos()), off(imp.End())
+				if imp.Doc != nil {
+					from = off(imp.Doc.Pos())
+				}
+				if imp.Comment != nil {
+					to = off(imp.Comment.End())
+				}
+			}
+			// 独占整行时连同行首缩进与换行一起删除
+			ls, le := lineStart(src, from), lineEnd(src, to)
+			if len(bytes.TrimSpace(src[ls:from])) == 0 && len(bytes.TrimSpace(src[to:le])) == 0 {
+				from, to = ls, le
+			}
+			return splice(src, from, to, ""), true, nil
+		}
+	}
+	return src, false, nil
+}
+
+// FixImports 补全缺失的标准库 import、移除未使用的 import；siblings 为同包其它文件的顶层名字
+func FixImports(src []byte, siblings map[string]bool) (out []byte, added, removed []string, err error) {
+	out = src
+	for round := 0; round < 64; round++ {
+		fset := token.NewFileSet()
+		f, perr := parser.ParseFile(fset, "", out, parser.ParseComments)
+		if perr != nil {
+			return src, nil, nil, perr
+		}
+		used := usedPackageNames(f)
+		imported := map[string]bool{}
+		var unused string
+		for _, imp := range f.Imports {
+			n, sure := localName(imp)
+			imported[n] = true
+			if sure && n != "_" && n != "." && n != "C" && !used[n] && unused == "" {
+				unused = importPath(imp)
+			}
+		}
+		if unused != "" {
+			if out, _, err = RemoveImport(out, unused); err != nil {
+				return src, nil, nil, err
+			}
+			removed = append(removed, unused)
+			continue
+		}
+		missing := ""
+		for n := range used {
+			if p, ok := stdPackages[n]; ok && !imported[n] && !siblings[n] {
+				if missing == "" || p < missing {
+					missing = p
+				}
+			}
+		}
+		if missing == "" {
+			return out, added, removed, nil
+		}
+		if out, _, err = AddImport(out, missing, ""); err != nil {
+			return src, nil, nil, err
+		}
+		added = append(added, missing)
+	}
+	return src, nil, nil, fmt.Errorf("import 自动修复未收敛")
+}
+
+// usedPackageNames 形如 pkg.X 且 pkg 未在文件内解析到声明的标识符
+func usedPackageNames(f *ast.File) map[string]bool {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// SiblingNames 同目录、同包其它 .go 文件的顶层声明名（非测试文件看不到 _test.go 中的声明）
+func SiblingNames(abs string) map[string]bool {
+	names := map[string]bool{}
+	dir, self := filepath.Dir(abs), filepath.Base(abs)
+	selfTest := strings.HasSuffix(self, "_test.go")
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+	fset := token.NewFileSet()
+	selfFile, _ := parser.ParseFile(fset, abs, nil, parser.PackageClauseOnly)
+	for _, e := range ents {
+		n := e.Name()
+		if e.IsDir() || n == self || !strings.HasSuffix(n, ".go") || (!selfTest && strings.HasSuffix(n, "_test.go")) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, n), nil, parser.SkipObjectResolution)
+		if err != nil || (selfFile != nil && f.Name.Name != selfFile.Name.Name) {
+			continue
+		}
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					names[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					switch s := s.(type) {
+					case *ast.TypeSpec:
+						names[s.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, id := range s.Names {
+							names[id.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func importPath(imp *ast.ImportSpec) string {
+	p, err := strconv.Unquote(imp.Path.Value)
+	if err != nil {
+		return imp.Path.Value
+	}
+	return p
+}
+
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	return ""
+}
+
+// localName import 在文件中的引用名；sure=false 表示无法可靠推断包名（此时不做“未使用”判断）
+// 只有显式别名与标准库（GOROOT 下存在的包，包名即末段）才有把握。
+func localName(imp *ast.ImportSpec) (string, bool) {
+	if imp.Name != nil {
+		return imp.Name.Name, true
+	}
+	p := importPath(imp)
+	last := p[strings.LastIndex(p, "/")+1:]
+	if build.Default.GOROOT == "" || strings.Contains(strings.Split(p, "/")[0], ".") {
+		return last, false
+	}
+	fi, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(p)))
+	return last, err == nil && fi.IsDir() && token.IsIdentifier(last)
+}
+
+func lineStart(b []byte, off int) int { return bytes.LastIndexByte(b[:off], '\n') + 1 }
+
+func lineEnd(b []byte, off int) int {
+	if i := bytes.IndexByte(b[off:], '\n'); i >= 0 {
+		return off + i + 1
+	}
+	return len(b)
+}
+
+func splice(b []byte, from, to int, s string) []byte {
+	out := make([]byte, 0, len(b)+len(s))
+	out = append(out, b[:from]...)
+	out = append(out, s...)
+	return append(out, b[to:]...)
+}
+
+// stdPackages 自动补全使用的标准库包名 → 导入路径（名字有歧义的包不收录，如 template、rand）
+var stdPackages = map[string]string{
+	"atomic":   "sync/atomic",
+	"base64":   "encoding/base64",
+	"bufio":    "bufio",
+	"bytes":    "bytes",
+	"context":  "context",
+	"csv":      "encoding/csv",
+	"errors":   "errors",
+	"exec":     "os/exec",
+	"filepath": "path/filepath",
+	"fmt":      "fmt",
+	"fs":       "io/fs",
+	"hex":      "encoding/hex",
+	"http":     "net/http",
+	"io":       "io",
+	"json":     "encoding/json",
+	"log":      "log",
+	"maps":     "maps",
+	"math":     "math",
+	"md5":      "crypto/md5",
+	"net":      "net",
+	"os":       "os",
+	"path":     "path",
+	"reflect":  "reflect",
+	"regexp":   "regexp",
+	"runtime":  "runtime",
+	"sha1":     "crypto/sha1",
+	"sha256":   "crypto/sha256",
+	"signal":   "os/signal",
+	"slices":   "slices",
+	"sort":     "sort",
+	"strconv":  "strconv",
+	"strings":  "strings",
+	"sync":     "sync",
+	"syscall":  "syscall",
+	"time":     "time",
+	"unicode":  "unicode",
+	"url":      "net/url",
+	"utf8":     "unicode/utf8",
+	"xml":      "encoding/xml",
+}
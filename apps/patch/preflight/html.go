@@ -0,0 +1,37 @@
+package preflight
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// htmlRunner / xmlRunner 校验标签配对、属性与注释是否闭合（规则见 htmldoc.go）；只校验不重排
+type htmlRunner struct{}
+
+func (htmlRunner) Name() string { return "html" }
+func (htmlRunner) Match(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
+func (htmlRunner) Run(repo, rel string, logf Logf) (bool, error) {
+	return checkSyntax(repo, rel, "html", func(src []byte) error {
+		_, err := ParseHTMLDoc(src, false)
+		return err
+	}, logf)
+}
+
+type xmlRunner struct{}
+
+func (xmlRunner) Name() string           { return "xml" }
+func (xmlRunner) Match(path string) bool { return IsXMLPath(path) }
+func (xmlRunner) Run(repo, rel string, logf Logf) (bool, error) {
+	return checkSyntax(repo, rel, "xml", func(src []byte) error {
+		_, err := ParseHTMLDoc(src, true)
+		return err
+	}, logf)
+}
+
+func init() {
+	Register(htmlRunner{})
+	Register(xmlRunner{})
+}
@@ -0,0 +1,369 @@
+package preflight
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+//
+// HTML / XML 元素树扫描：记录每个元素开始标签、属性与结束标签的字节区间，
+// 供 html.* 指令按区间编辑，也供预检做格式良好性校验：
+//   - 标签、注释、属性引号必须闭合，同一元素的属性不能重复
+//   - 结束标签必须与最近未闭合的元素配对
+//   - HTML 模式：标签名不区分大小写；void 元素（br、img…）无结束标签；
+//     p、li、td 等可省略结束标签的元素按 HTML 规则隐式闭合；script / style 内容不解析
+//   - XML 模式：区分大小写，所有元素都必须显式闭合或自闭合
+//
+
+// HTMLAttr 开始标签中的一个属性
+type HTMLAttr struct {
+	Name     string // HTML 模式下为小写
+	Value    string // 原始值（不含引号、未解码）
+	Start    int    // 属性名起始
+	End      int    // 属性结束（含值与引号）
+	ValStart int    // 值起始（含引号）；无值时为 -1
+	Quote    byte   // 值的引号（' 或 "）；无引号为 0
+}
+
+// HTMLNode 一个元素；根节点 Tag 为 ""，覆盖整个文档
+type HTMLNode struct {
+	Tag        string
+	Attrs      []HTMLAttr
+	Start      int // "<" 处
+	NameEnd    int // 标签名之后
+	OpenEnd    int // 开始标签的 ">" 之后
+	CloseStart int // 结束标签的 "<" 处；无结束标签时等于 End
+	End        int // 元素结束（结束标签之后）
+	SelfClose  bool
+	Parent     *HTMLNode
+	Children   []*HTMLNode
+}
+
+// Attr 按名称取属性（HTML 模式下 name 需为小写）
+func (n *HTMLNode) Attr(name string) *HTMLAttr {
+	for i := range n.Attrs {
+		if n.Attrs[i].Name == name {
+			return &n.Attrs[i]
+		}
+	}
+	return nil
+}
+
+// Void 元素没有内容区（void 元素或自闭合）
+func (n *HTMLNode) Void() bool { return n.OpenEnd == n.End }
+
+// IsXMLPath 按 XML 规则处理的文件
+func IsXMLPath(rel string) bool {
+	switch strings.ToLower(filepath.Ext(rel)) {
+	case ".xml", ".svg", ".xhtml":
+		return true
+	}
+	return false
+}
+
+var (
+	htmlVoid = setOf("area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr")
+	htmlRaw  = setOf("script", "style", "textarea", "title")
+	// 可省略结束标签的元素（遇到父元素结束或文档结束时隐式闭合）
+	htmlOptionalEnd = setOf("html", "head", "body", "p", "li", "dt", "dd", "option", "optgroup", "tr", "td", "th",
+		"thead", "tbody", "tfoot", "colgroup", "caption", "rt", "rp")
+	// 会隐式闭合未结束的 <p> 的元素
+	htmlClosesP = setOf("address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figcaption",
+		"figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "main", "menu", "nav", "ol",
+		"p", "pre", "section", "table", "ul")
+)
+
+func setOf(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+// htmlImpliedEnd 打开 next 时，未闭合的 open 是否隐式结束
+func htmlImpliedEnd(open, next string) bool {
+	switch open {
+	case "p":
+		return htmlClosesP[next]
+	case "li":
+		return next == "li"
+	case "dt", "dd":
+		return next == "dt" || next == "dd"
+	case "option":
+		return next == "option" || next == "optgroup"
+	case "optgroup":
+		return next == "optgroup"
+	case "tr":
+		return next == "tr" || next == "tbody" || next == "tfoot"
+	case "td", "th":
+		return next == "td" || next == "th" || next == "tr" || next == "tbody" || next == "tfoot"
+	case "thead", "tbody":
+		return next == "tbody" || next == "tfoot"
+	case "rt", "rp":
+		return next == "rt" || next == "rp"
+	}
+	return false
+}
+
+// ParseHTMLDoc 扫描整个文档（输入为 LF 文本），返回根节点
+func ParseHTMLDoc(src []byte, xml bool) (*HTMLNode, error) {
+	root := &HTMLNode{End: len(src), CloseStart: len(src)}
+	p := &htmlParser{src: src, xml: xml, stack: []*HTMLNode{root}}
+	if err := p.run(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type htmlParser struct {
+	src   []byte
+	i     int
+	xml   bool
+	stack []*HTMLNode
+}
+
+func (p *htmlParser) errorf(at int, format string, a ...any) error {
+	line := bytes.Count(p.src[:at], []byte("\n")) + 1
+	col := at - bytes.LastIndexByte(p.src[:at], '\n')
+	return fmt.Errorf("第 %d 行第 %d 列：%s", line, col, fmt.Sprintf(format, a...))
+}
+
+func (p *htmlParser) lineOf(at int) int { return bytes.Count(p.src[:at], []byte("\n")) + 1 }
+
+func (p *htmlParser) top() *HTMLNode { return p.stack[len(p.stack)-1] }
+
+func (p *htmlParser) has(s string) bool { return bytes.HasPrefix(p.src[p.i:], []byte(s)) }
+
+// skipTo 跳到 end 之后；找不到时报错
+func (p *htmlParser) skipTo(end, what string) error {
+	k := bytes.Index(p.src[p.i:], []byte(end))
+	if k < 0 {
+		return p.errorf(p.i, "%s未闭合", what)
+	}
+	p.i += k + len(end)
+	return nil
+}
+
+func (p *htmlParser) run() error {
+	for {
+		lt := bytes.IndexByte(p.src[p.i:], '<')
+		if lt < 0 {
+			break
+		}
+		p.i += lt
+		var err error
+		switch {
+		case p.has("<!--"):
+			err = p.skipTo("-->", "注释")
+		case p.has("<![CDATA["):
+			err = p.skipTo("]]>", "CDATA")
+		case p.has("<!") || p.has("<?"):
+			err = p.skipTo(">", "声明")
+		case p.has("</"):
+			err = p.closeTag()
+		case p.i+1 < len(p.src) && isHTMLNameStart(p.src[p.i+1]):
+			err = p.openTag()
+		default:
+			if p.xml {
+				return p.errorf(p.i, "多余的 '<'（文本中应写作 &lt;）")
+			}
+			p.i++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for len(p.stack) > 1 {
+		n := p.top()
+		if p.xml || !htmlOptionalEnd[n.Tag] {
+			return p.errorf(n.Start, "<%s> 未闭合", n.Tag)
+		}
+		p.implicitClose(len(p.src))
+	}
+	return nil
+}
+
+func isHTMLNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':'
+}
+
+func isHTMLNameChar(c byte) bool {
+	return isHTMLNameStart(c) || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
+
+func isHTMLSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+
+func (p *htmlParser) name() string {
+	start := p.i
+	for p.i < len(p.src) && isHTMLNameChar(p.src[p.i]) {
+		p.i++
+	}
+	s := string(p.src[start:p.i])
+	if !p.xml {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+func (p *htmlParser) spaces() {
+	for p.i < len(p.src) && isHTMLSpace(p.src[p.i]) {
+		p.i++
+	}
+}
+
+// implicitClose 隐式闭合栈顶元素：结束位置退到 at 之前的最后一个非空白字符之后
+func (p *htmlParser) implicitClose(at int) {
+	n := p.top()
+	end := at
+	for end > n.OpenEnd && isHTMLSpace(p.src[end-1]) {
+		end--
+	}
+	n.CloseStart, n.End = end, end
+	p.stack = p.stack[:len(p.stack)-1]
+}
+
+func (p *htmlParser) openTag() error {
+	n := &HTMLNode{Start: p.i}
+	p.i++
+	n.Tag = p.name()
+	n.NameEnd = p.i
+	seen := map[string]bool{}
+	for {
+		p.spaces()
+		if p.i >= len(p.src) {
+			return p.errorf(n.Start, "<%s> 开始标签未闭合", n.Tag)
+		}
+		if p.src[p.i] == '>' {
+			p.i++
+			break
+		}
+		if p.has("/>") {
+			p.i += 2
+			n.SelfClose = true
+			break
+		}
+		a, err := p.attr()
+		if err != nil {
+			return err
+		}
+		if seen[a.Name] {
+			return p.errorf(a.Start, "<%s> 的属性 %s 重复", n.Tag, a.Name)
+		}
+		seen[a.Name] = true
+		n.Attrs = append(n.Attrs, a)
+	}
+	n.OpenEnd = p.i
+
+	if !p.xml {
+		for len(p.stack) > 1 && htmlImpliedEnd(p.top().Tag, n.Tag) {
+			p.implicitClose(n.Start)
+		}
+	}
+	parent := p.top()
+	n.Parent = parent
+	parent.Children = append(parent.Children, n)
+
+	switch {
+	case n.SelfClose || !p.xml && htmlVoid[n.Tag]:
+		n.CloseStart, n.End = n.OpenEnd, n.OpenEnd
+	case !p.xml && htmlRaw[n.Tag]:
+		// 原始文本：直接找对应的结束标签
+		k := bytes.Index(bytes.ToLower(p.src[p.i:]), []byte("</"+n.Tag))
+		if k < 0 {
+			return p.errorf(n.Start, "<%s> 未闭合", n.Tag)
+		}
+		p.i += k
+		n.CloseStart = p.i
+		if err := p.skipTo(">", "结束标签"); err != nil {
+			return err
+		}
+		n.End = p.i
+	default:
+		p.stack = append(p.stack, n)
+	}
+	return nil
+}
+
+func (p *htmlParser) attr() (HTMLAttr, error) {
+	a := HTMLAttr{Start: p.i, ValStart: -1}
+	for p.i < len(p.src) && !isHTMLSpace(p.src[p.i]) && !strings.ContainsRune("=>\"'<", rune(p.src[p.i])) && !p.has("/>") {
+		p.i++
+	}
+	if p.i == a.Start {
+		return a, p.errorf(p.i, "无效的属性（意外的字符 %q）", p.src[p.i])
+	}
+	a.Name = string(p.src[a.Start:p.i])
+	if !p.xml {
+		a.Name = strings.ToLower(a.Name)
+	}
+	a.End = p.i
+	save := p.i
+	p.spaces()
+	if p.i >= len(p.src) || p.src[p.i] != '=' {
+		if p.xml {
+			return a, p.errorf(a.Start, "属性 %s 缺少值", a.Name)
+		}
+		p.i = save
+		return a, nil
+	}
+	p.i++
+	p.spaces()
+	a.ValStart = p.i
+	if p.i < len(p.src) && (p.src[p.i] == '"' || p.src[p.i] == '\'') {
+		a.Quote = p.src[p.i]
+		k := bytes.IndexByte(p.src[p.i+1:], a.Quote)
+		if k < 0 {
+			return a, p.errorf(p.i, "属性 %s 的引号未闭合", a.Name)
+		}
+		a.Value = string(p.src[p.i+1 : p.i+1+k])
+		p.i += k + 2
+	} else {
+		if p.xml {
+			return a, p.errorf(p.i, "属性 %s 的值必须加引号", a.Name)
+		}
+		for p.i < len(p.src) && !isHTMLSpace(p.src[p.i]) && p.src[p.i] != '>' {
+			p.i++
+		}
+		if p.i == a.ValStart {
+			return a, p.errorf(p.i, "属性 %s 缺少值", a.Name)
+		}
+		a.Value = string(p.src[a.ValStart:p.i])
+	}
+	a.End = p.i
+	return a, nil
+}
+
+func (p *htmlParser) closeTag() error {
+	start := p.i
+	p.i += 2
+	tag := p.name()
+	p.spaces()
+	if p.i >= len(p.src) || p.src[p.i] != '>' {
+		return p.errorf(start, "结束标签 </%s> 格式错误", tag)
+	}
+	p.i++
+	k := len(p.stack) - 1
+	for k > 0 && p.stack[k].Tag != tag {
+		k--
+	}
+	if k == 0 {
+		if !p.xml && htmlVoid[tag] {
+			return nil // </br> 之类：浏览器忽略
+		}
+		return p.errorf(start, "多余的结束标签 </%s>", tag)
+	}
+	for len(p.stack)-1 > k {
+		n := p.top()
+		if p.xml || !htmlOptionalEnd[n.Tag] {
+			return p.errorf(start, "<%s>（第 %d 行）未闭合，却遇到了 </%s>", n.Tag, p.lineOf(n.Start), tag)
+		}
+		p.implicitClose(start)
+	}
+	n := p.top()
+	n.CloseStart, n.End = start, p.i
+	p.stack = p.stack[:k]
+	return nil
+}
@@ -2,67 +2,70 @@ package preflight
 
 import (
 	"bytes"
-	"encoding/json"
 	"os"
+	"path"
 	"path/filepath"
-	"time"
+	"strings"
 )
 
+// jsonRunner JSON 预检：按原有键顺序解析校验语法，只校验不改写
+//   - 不重排缩进 / 空白：json.* 指令与其它编辑只改动目标片段，预检不能把未触及的部分整体重排
+//   - JSONC（.jsonc 与 .xgit/preflight 中 json.jsonc 列出的路径）：允许注释与末尾逗号
+//   - json.* 指令插入新值时的缩进单位见 JSONIndent（文件现有缩进 → .editorconfig → 2 空格）
 type jsonRunner struct{}
 
-func (jsonRunner) Name() string { return "json-pretty" }
+func (jsonRunner) Name() string { return "json" }
 func (jsonRunner) Match(path string) bool {
-	ext := filepath.Ext(path)
-	return ext == ".json"
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".json" || ext == ".jsonc"
 }
 func (jsonRunner) Run(repo, rel string, logf Logf) (bool, error) {
-	abs := filepath.Join(repo, rel)
-	orig, err := os.ReadFile(abs)
+	orig, err := os.ReadFile(filepath.Join(repo, rel))
 	if err != nil {
 		return false, err
 	}
-	// 保存权限/mtime 与 EOL
-	isCRLF := bytes.Contains(orig, []byte("\r\n"))
-	mode := os.FileMode(0o644)
-	mtime := time.Time{}
-	if fi, _ := os.Stat(abs); fi != nil {
-		mode = fi.Mode()
-		mtime = fi.ModTime()
-	}
-
-	// 解析校验
-	var v any
-	dec := json.NewDecoder(bytes.NewReader(orig))
-	dec.DisallowUnknownFields() // 严一点：多余字段时报错（可按需放宽）
-	if err := dec.Decode(&v); err != nil {
+	src := normalizeLF(bytes.TrimPrefix(orig, []byte("\xef\xbb\xbf")))
+	jsonc := IsJSONC(repo, rel)
+	if _, err := ParseJSONDoc(src, jsonc); err != nil {
 		logf("❌ preflight(json): %s 语法错误：%v", rel, err)
 		return false, err
 	}
-
-	// pretty 输出（2 空格）
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetEscapeHTML(false)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(v); err != nil {
-		return false, err
+	if jsonc {
+		logf("🧪 preflight(json): %s JSONC 语法正确", rel)
+	} else {
+		logf("🧪 preflight(json): %s 语法正确", rel)
 	}
-	out := buf.Bytes()
-	// 恢复 EOL 风格
-	if isCRLF {
-		out = toCRLF(out)
+	return false, nil
+}
+
+// JSONIndent rel 应使用的缩进单位：文件现有缩进 → .editorconfig → 2 空格
+func JSONIndent(repo, rel string, src []byte) string {
+	if unit := DetectIndent(src); unit != "" {
+		return unit
 	}
-	// 若无变化则跳过
-	if bytes.Equal(orig, out) {
-		logf("🧪 preflight(json): %s 已是规范格式", rel)
-		return false, nil
+	if unit, ok := EditorConfigIndent(repo, rel); ok {
+		return unit
 	}
+	return "  "
+}
 
-	if err := atomicWrite(abs, out, mode, mtime); err != nil {
-		return false, err
+// IsJSONC rel 是否按 JSONC 处理：扩展名 .jsonc，或匹配 .xgit/preflight 中 json.jsonc 列出的模式
+// （不含 / 的模式匹配任意层级的文件名，如 tsconfig*.json；含 / 的模式按仓库相对路径匹配，如 .vscode/*.json）
+func IsJSONC(repo, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	if strings.EqualFold(path.Ext(rel), ".jsonc") {
+		return true
+	}
+	for _, pat := range LoadConfig(repo).List("json.jsonc") {
+		target := rel
+		if !strings.Contains(pat, "/") {
+			target = path.Base(rel)
+		}
+		if ok, _ := path.Match(strings.TrimPrefix(pat, "/"), target); ok {
+			return true
+		}
 	}
-	logf("🛠️ preflight(json): 已格式化 %s", rel)
-	return true, nil
+	return false
 }
 
 func init() { Register(jsonRunner{}) }
@@ -0,0 +1,346 @@
+package preflight
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//
+// 带位置信息的 JSON 文档：解析时记录每个值 / 键的字节区间，供结构化编辑按区间做最小改动
+// （键顺序、缩进与无关内容原样保留），也供预检按原顺序重新排版。
+// jsonc=true 时允许 // 与 /* */ 注释及末尾多余的逗号。
+//
+
+// JSONKind 值类型
+type JSONKind byte
+
+const (
+	JSONObject JSONKind = '{'
+	JSONArray  JSONKind = '['
+	JSONString JSONKind = 's'
+	JSONNumber JSONKind = 'n'
+	JSONBool   JSONKind = 'b'
+	JSONNull   JSONKind = 'z'
+)
+
+// JSONNode 一个 JSON 值；[Start, End) 为其在源文本中的字节区间
+type JSONNode struct {
+	Kind       JSONKind
+	Start, End int
+	Members    []*JSONMember // 对象成员（按出现顺序）
+	Elems      []*JSONNode   // 数组元素
+}
+
+// JSONMember 对象成员；[KeyStart, KeyEnd) 为键（含引号）的区间
+type JSONMember struct {
+	Key              string
+	KeyStart, KeyEnd int
+	Value            *JSONNode
+}
+
+// Member 按键查找成员
+func (n *JSONNode) Member(key string) (int, *JSONMember) {
+	for i, m := range n.Members {
+		if m.Key == key {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+// ParseJSONDoc 解析整个文档（根值前后只允许空白与注释）
+func ParseJSONDoc(src []byte, jsonc bool) (*JSONNode, error) {
+	p := &jsonParser{src: src, jsonc: jsonc}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skip(); err != nil {
+		return nil, err
+	}
+	if p.i < len(src) {
+		return nil, p.errorf("根值之后还有多余内容")
+	}
+	return n, nil
+}
+
+type jsonParser struct {
+	src   []byte
+	i     int
+	jsonc bool
+}
+
+func (p *jsonParser) errorf(format string, a ...any) error {
+	line := bytes.Count(p.src[:p.i], []byte("\n")) + 1
+	col := p.i - bytes.LastIndexByte(p.src[:p.i], '\n')
+	return fmt.Errorf("第 %d 行第 %d 列：%s", line, col, fmt.Sprintf(format, a...))
+}
+
+// skip 跳过空白（jsonc 时也跳过注释）
+func (p *jsonParser) skip() error {
+	for p.i < len(p.src) {
+		switch c := p.src[p.i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.i++
+		case p.jsonc && c == '/' && p.i+1 < len(p.src) && p.src[p.i+1] == '/':
+			for p.i < len(p.src) && p.src[p.i] != '\n' {
+				p.i++
+			}
+		case p.jsonc && c == '/' && p.i+1 < len(p.src) && p.src[p.i+1] == '*':
+			end := bytes.Index(p.src[p.i+2:], []byte("*/"))
+			if end < 0 {
+				return p.errorf("块注释未闭合")
+			}
+			p.i += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *jsonParser) value() (*JSONNode, error) {
+	if p.i >= len(p.src) {
+		return nil, p.errorf("意外的文件结尾")
+	}
+	start := p.i
+	switch c := p.src[p.i]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		if err := p.str(); err != nil {
+			return nil, err
+		}
+		return &JSONNode{Kind: JSONString, Start: start, End: p.i}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		if err := p.number(); err != nil {
+			return nil, err
+		}
+		return &JSONNode{Kind: JSONNumber, Start: start, End: p.i}, nil
+	}
+	for lit, kind := range map[string]JSONKind{"true": JSONBool, "false": JSONBool, "null": JSONNull} {
+		if bytes.HasPrefix(p.src[p.i:], []byte(lit)) {
+			p.i += len(lit)
+			return &JSONNode{Kind: kind, Start: start, End: p.i}, nil
+		}
+	}
+	return nil, p.errorf("无效的值 %q", p.src[p.i])
+}
+
+func (p *jsonParser) object() (*JSONNode, error) {
+	n := &JSONNode{Kind: JSONObject, Start: p.i}
+	p.i++
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.i < len(p.src) && p.src[p.i] == '}' && (len(n.Members) == 0 || p.jsonc) {
+			p.i++
+			n.End = p.i
+			return n, nil
+		}
+		if p.i >= len(p.src) || p.src[p.i] != '"' {
+			return nil, p.errorf("期望对象键（字符串）")
+		}
+		m := &JSONMember{KeyStart: p.i}
+		if err := p.str(); err != nil {
+			return nil, err
+		}
+		m.KeyEnd = p.i
+		if err := json.Unmarshal(p.src[m.KeyStart:m.KeyEnd], &m.Key); err != nil {
+			return nil, p.errorf("键无效：%v", err)
+		}
+		if _, dup := n.Member(m.Key); dup != nil {
+			return nil, p.errorf("重复的键 %q", m.Key)
+		}
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.i >= len(p.src) || p.src[p.i] != ':' {
+			return nil, p.errorf("键 %q 之后期望 ':'", m.Key)
+		}
+		p.i++
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Value = v
+		n.Members = append(n.Members, m)
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.i < len(p.src) && p.src[p.i] == ',' {
+			p.i++
+			continue
+		}
+		if p.i < len(p.src) && p.src[p.i] == '}' {
+			p.i++
+			n.End = p.i
+			return n, nil
+		}
+		return nil, p.errorf("对象中期望 ',' 或 '}'")
+	}
+}
+
+func (p *jsonParser) array() (*JSONNode, error) {
+	n := &JSONNode{Kind: JSONArray, Start: p.i}
+	p.i++
+	for {
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.i < len(p.src) && p.src[p.i] == ']' && (len(n.Elems) == 0 || p.jsonc) {
+			p.i++
+			n.End = p.i
+			return n, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.Elems = append(n.Elems, v)
+		if err := p.skip(); err != nil {
+			return nil, err
+		}
+		if p.i < len(p.src) && p.src[p.i] == ',' {
+			p.i++
+			continue
+		}
+		if p.i < len(p.src) && p.src[p.i] == ']' {
+			p.i++
+			n.End = p.i
+			return n, nil
+		}
+		return nil, p.errorf("数组中期望 ',' 或 ']'")
+	}
+}
+
+func (p *jsonParser) str() error {
+	p.i++ // 开头的引号
+	for p.i < len(p.src) {
+		switch c := p.src[p.i]; {
+		case c == '"':
+			p.i++
+			return nil
+		case c == '\\':
+			if p.i+1 >= len(p.src) || !strings.ContainsRune(`"\/bfnrtu`, rune(p.src[p.i+1])) {
+				return p.errorf("无效的转义")
+			}
+			p.i += 2
+		case c < 0x20:
+			return p.errorf("字符串中含有控制字符")
+		default:
+			p.i++
+		}
+	}
+	return p.errorf("字符串未闭合")
+}
+
+func (p *jsonParser) number() error {
+	start := p.i
+	for p.i < len(p.src) && strings.IndexByte("+-0123456789.eE", p.src[p.i]) >= 0 {
+		p.i++
+	}
+	var f json.Number
+	if err := json.Unmarshal(p.src[start:p.i], &f); err != nil {
+		p.i = start
+		return p.errorf("无效的数字")
+	}
+	return nil
+}
+
+// RenderJSON 按给定缩进重新排版 n（保持成员顺序，标量原样复制）
+// indent 为 n 所在行的缩进，unit 为每级缩进；unit 为空时输出单行紧凑格式
+func RenderJSON(src []byte, n *JSONNode, indent, unit string) string {
+	var sb strings.Builder
+	renderJSON(&sb, src, n, indent, unit)
+	return sb.String()
+}
+
+func renderJSON(sb *strings.Builder, src []byte, n *JSONNode, indent, unit string) {
+	open, close, count := byte('{'), byte('}'), len(n.Members)
+	if n.Kind == JSONArray {
+		open, close, count = '[', ']', len(n.Elems)
+	} else if n.Kind != JSONObject {
+		sb.Write(src[n.Start:n.End])
+		return
+	}
+	sb.WriteByte(open)
+	if count == 0 {
+		sb.WriteByte(close)
+		return
+	}
+	inner := indent + unit
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+			if unit == "" {
+				sb.WriteByte(' ')
+			}
+		}
+		if unit != "" {
+			sb.WriteString("\n" + inner)
+		}
+		if n.Kind == JSONArray {
+			renderJSON(sb, src, n.Elems[i], inner, unit)
+			continue
+		}
+		m := n.Members[i]
+		sb.Write(src[m.KeyStart:m.KeyEnd])
+		sb.WriteString(": ")
+		renderJSON(sb, src, m.Value, inner, unit)
+	}
+	if unit != "" {
+		sb.WriteString("\n" + indent)
+	}
+	sb.WriteByte(close)
+}
+
+// JSONEqual 两个值在语义上是否相等（对象忽略键顺序）
+func JSONEqual(a []byte, na *JSONNode, b []byte, nb *JSONNode) bool {
+	var va, vb any
+	if json.Unmarshal(a[na.Start:na.End], &va) != nil || json.Unmarshal(b[nb.Start:nb.End], &vb) != nil {
+		return bytes.Equal(a[na.Start:na.End], b[nb.Start:nb.End])
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// DetectIndent 推断缩进单位：取所有缩进行中最短的前导空白（以 tab 开头则为 tab）；无缩进行返回 ""
+func DetectIndent(src []byte) string {
+	unit := ""
+	for _, l := range strings.Split(string(src), "\n") {
+		t := strings.TrimLeft(l, " \t")
+		ws := l[:len(l)-len(t)]
+		if ws == "" || strings.TrimSpace(t) == "" {
+			continue
+		}
+		if ws[0] == '\t' {
+			return "\t"
+		}
+		if unit == "" || len(ws) < len(unit) {
+			unit = ws
+		}
+	}
+	return unit
+}
+
+// LineIndent pos 所在行的前导空白
+func LineIndent(src []byte, pos int) string {
+	ls := bytes.LastIndexByte(src[:pos], '\n') + 1
+	i := ls
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return string(src[ls:i])
+}
@@ -0,0 +1,371 @@
+package preflight
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//
+// TOML 行结构扫描：识别表头（[a.b] / [[a]]）与键值行，记录完整键路径和值的字节区间，
+// 同时做语法校验（字符串 / 数组 / 内联表 / 标量字面量、重复键、重复表）。
+// 键路径中数组表的第 N 个元素记作 "[N]"，例如 [[bin]] 的第二个元素下的 name 为 bin.[1].name。
+//
+
+// TOMLEntry 表头或键值行
+type TOMLEntry struct {
+	Path      []string // 完整键路径
+	Table     bool     // 表头（[a] 或 [[a]]）
+	Line      int      // 起始行（0-based）
+	EndLine   int      // 结束行（含；多行值跨多行）
+	ValStart  int      // 值的字节区间（仅键值行）
+	ValEnd    int
+	KeyDotted int // 键值行自身的点分段数（a.b = 1 为 2）
+}
+
+// ParseTOMLDoc 扫描整个文档（输入为 LF 文本）
+func ParseTOMLDoc(src []byte) ([]TOMLEntry, error) {
+	s := &tomlScanner{src: src, arrays: map[string]int{}, defined: map[string]bool{}}
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return s.entries, nil
+}
+
+type tomlScanner struct {
+	src     []byte
+	i       int
+	table   []string
+	arrays  map[string]int  // 数组表路径 → 元素个数
+	defined map[string]bool // 已定义的键 / 表
+	entries []TOMLEntry
+}
+
+func (s *tomlScanner) line() int { return bytes.Count(s.src[:s.i], []byte("\n")) }
+
+func (s *tomlScanner) errorf(format string, a ...any) error {
+	col := s.i - bytes.LastIndexByte(s.src[:s.i], '\n')
+	return fmt.Errorf("第 %d 行第 %d 列：%s", s.line()+1, col, fmt.Sprintf(format, a...))
+}
+
+func (s *tomlScanner) peek() byte {
+	if s.i < len(s.src) {
+		return s.src[s.i]
+	}
+	return 0
+}
+
+func (s *tomlScanner) spaces() {
+	for s.i < len(s.src) && (s.src[s.i] == ' ' || s.src[s.i] == '\t') {
+		s.i++
+	}
+}
+
+// eol 行尾：只允许空白与注释
+func (s *tomlScanner) eol() error {
+	s.spaces()
+	if s.peek() == '#' {
+		for s.i < len(s.src) && s.src[s.i] != '\n' {
+			s.i++
+		}
+	}
+	if s.i < len(s.src) {
+		if s.src[s.i] != '\n' {
+			return s.errorf("行尾有多余内容")
+		}
+		s.i++
+	}
+	return nil
+}
+
+func (s *tomlScanner) run() error {
+	for s.i < len(s.src) {
+		s.spaces()
+		switch c := s.peek(); {
+		case c == 0:
+			return nil
+		case c == '\n' || c == '\r':
+			s.i++
+		case c == '#':
+			if err := s.eol(); err != nil {
+				return err
+			}
+		case c == '[':
+			if err := s.header(); err != nil {
+				return err
+			}
+		default:
+			if err := s.keyValue(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve 给途经的数组表补上当前元素下标
+func (s *tomlScanner) resolve(keys []string) []string {
+	var out []string
+	for _, k := range keys {
+		out = append(out, k)
+		if n, ok := s.arrays[strings.Join(out, "\x00")]; ok {
+			out = append(out, "["+strconv.Itoa(n-1)+"]")
+		}
+	}
+	return out
+}
+
+func (s *tomlScanner) header() error {
+	line := s.line()
+	array := bytes.HasPrefix(s.src[s.i:], []byte("[["))
+	if array {
+		s.i += 2
+	} else {
+		s.i++
+	}
+	s.spaces()
+	keys, err := s.key()
+	if err != nil {
+		return err
+	}
+	s.spaces()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !bytes.HasPrefix(s.src[s.i:], []byte(closing)) {
+		return s.errorf("表头缺少 %s", closing)
+	}
+	s.i += len(closing)
+	var path []string
+	if array {
+		path = append(s.resolve(keys[:len(keys)-1]), keys[len(keys)-1])
+		k := strings.Join(path, "\x00")
+		s.arrays[k]++
+		path = append(path, "["+strconv.Itoa(s.arrays[k]-1)+"]")
+	} else {
+		path = s.resolve(keys)
+		k := strings.Join(path, "\x00")
+		if s.defined[k] {
+			return s.errorf("表 [%s] 重复定义", strings.Join(keys, "."))
+		}
+		s.defined[k] = true
+	}
+	s.table = path
+	s.entries = append(s.entries, TOMLEntry{Path: path, Table: true, Line: line, EndLine: line})
+	return s.eol()
+}
+
+func (s *tomlScanner) keyValue() error {
+	line := s.line()
+	keys, err := s.key()
+	if err != nil {
+		return err
+	}
+	s.spaces()
+	if s.peek() != '=' {
+		return s.errorf("键 %s 之后期望 '='", strings.Join(keys, "."))
+	}
+	s.i++
+	s.spaces()
+	start := s.i
+	if err := s.value(); err != nil {
+		return err
+	}
+	end := s.i
+	path := append(append([]string(nil), s.table...), keys...)
+	k := strings.Join(path, "\x00")
+	if s.defined[k] {
+		return s.errorf("键 %s 重复定义", strings.Join(keys, "."))
+	}
+	s.defined[k] = true
+	e := TOMLEntry{Path: path, Line: line, ValStart: start, ValEnd: end, KeyDotted: len(keys)}
+	if err := s.eol(); err != nil {
+		return err
+	}
+	e.EndLine = bytes.Count(s.src[:end], []byte("\n"))
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+
+// key 点分键：裸键 / "基本字符串" / '字面字符串'
+func (s *tomlScanner) key() ([]string, error) {
+	var keys []string
+	for {
+		s.spaces()
+		switch c := s.peek(); c {
+		case '"', '\'':
+			start := s.i
+			if err := s.str(false); err != nil {
+				return nil, err
+			}
+			k := string(s.src[start+1 : s.i-1])
+			if c == '"' {
+				if u, err := strconv.Unquote(string(s.src[start:s.i])); err == nil {
+					k = u
+				}
+			}
+			keys = append(keys, k)
+		default:
+			m := tomlBareKey.Find(s.src[s.i:])
+			if m == nil {
+				return nil, s.errorf("无效的键")
+			}
+			keys = append(keys, string(m))
+			s.i += len(m)
+		}
+		s.spaces()
+		if s.peek() != '.' {
+			return keys, nil
+		}
+		s.i++
+	}
+}
+
+// str 字符串（multi=true 时允许 """ / ”' 多行形式）
+func (s *tomlScanner) str(multi bool) error {
+	q := s.src[s.i]
+	triple := bytes.Repeat([]byte{q}, 3)
+	if multi && bytes.HasPrefix(s.src[s.i:], triple) {
+		s.i += 3
+		for s.i < len(s.src) {
+			if q == '"' && s.src[s.i] == '\\' {
+				s.i += 2
+				continue
+			}
+			if bytes.HasPrefix(s.src[s.i:], triple) {
+				s.i += 3
+				for k := 0; k < 2 && s.peek() == q; k++ { // 结尾允许紧跟一两个引号
+					s.i++
+				}
+				return nil
+			}
+			s.i++
+		}
+		return s.errorf("多行字符串未闭合")
+	}
+	s.i++
+	for s.i < len(s.src) && s.src[s.i] != '\n' {
+		switch {
+		case s.src[s.i] == q:
+			s.i++
+			return nil
+		case q == '"' && s.src[s.i] == '\\':
+			s.i += 2
+		default:
+			s.i++
+		}
+	}
+	return s.errorf("字符串未闭合")
+}
+
+var tomlScalar = regexp.MustCompile(`^(?:true|false|[+-]?(?:inf|nan)|[+-]?(?:0|[1-9](?:_?[0-9])*)(?:\.[0-9](?:_?[0-9])*)?(?:[eE][+-]?[0-9](?:_?[0-9])*)?|0x[0-9A-Fa-f](?:_?[0-9A-Fa-f])*|0o[0-7](?:_?[0-7])*|0b[01](?:_?[01])*|\d{4}-\d{2}-\d{2}(?:[Tt ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:[Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(?:\.\d+)?)$`)
+
+func (s *tomlScanner) value() error {
+	switch c := s.peek(); c {
+	case '"', '\'':
+		return s.str(true)
+	case '[':
+		return s.array()
+	case '{':
+		return s.inlineTable()
+	case 0, '\n', '#':
+		return s.errorf("缺少值")
+	}
+	start := s.i
+	for s.i < len(s.src) && !strings.ContainsRune(",]}#\n", rune(s.src[s.i])) {
+		s.i++
+	}
+	tok := strings.TrimRight(string(s.src[start:s.i]), " \t")
+	s.i = start + len(tok)
+	if !tomlScalar.MatchString(tok) {
+		s.i = start
+		return s.errorf("无效的值 %q（字符串需加引号）", tok)
+	}
+	return nil
+}
+
+// skipBlank 数组内部：空白、换行与注释
+func (s *tomlScanner) skipBlank() {
+	for s.i < len(s.src) {
+		switch s.src[s.i] {
+		case ' ', '\t', '\n', '\r':
+			s.i++
+		case '#':
+			for s.i < len(s.src) && s.src[s.i] != '\n' {
+				s.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *tomlScanner) array() error {
+	s.i++
+	for {
+		s.skipBlank()
+		if s.peek() == ']' {
+			s.i++
+			return nil
+		}
+		if err := s.value(); err != nil {
+			return err
+		}
+		s.skipBlank()
+		switch s.peek() {
+		case ',':
+			s.i++
+		case ']':
+			s.i++
+			return nil
+		default:
+			return s.errorf("数组中期望 ',' 或 ']'")
+		}
+	}
+}
+
+func (s *tomlScanner) inlineTable() error {
+	s.i++
+	seen := map[string]bool{}
+	s.spaces()
+	if s.peek() == '}' {
+		s.i++
+		return nil
+	}
+	for {
+		keys, err := s.key()
+		if err != nil {
+			return err
+		}
+		if k := strings.Join(keys, "\x00"); seen[k] {
+			return s.errorf("内联表中键 %s 重复", strings.Join(keys, "."))
+		} else {
+			seen[k] = true
+		}
+		s.spaces()
+		if s.peek() != '=' {
+			return s.errorf("内联表中期望 '='")
+		}
+		s.i++
+		s.spaces()
+		if err := s.value(); err != nil {
+			return err
+		}
+		s.spaces()
+		switch s.peek() {
+		case ',':
+			s.i++
+			s.spaces()
+		case '}':
+			s.i++
+			return nil
+		default:
+			return s.errorf("内联表中期望 ',' 或 '}'")
+		}
+	}
+}
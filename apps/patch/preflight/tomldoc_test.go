@@ -0,0 +1,53 @@
+package preflight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTOMLDocPaths(t *testing.T) {
+	src := "title = \"x\"\n[tool.poetry]\nname = \"a\" # c\nsite.url = 'u'\n\n[[bin]]\nname = \"b1\"\n[[bin]]\nname = \"b2\"\ndeps = [\n  \"a\",\n  \"b\",\n]\n"
+	entries, err := ParseTOMLDoc([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]TOMLEntry{}
+	for _, e := range entries {
+		got[strings.Join(e.Path, ".")] = e
+	}
+	for _, p := range []string{"title", "tool.poetry", "tool.poetry.name", "tool.poetry.site.url", "bin.[0].name", "bin.[1].name", "bin.[1].deps"} {
+		if _, ok := got[p]; !ok {
+			t.Errorf("缺少 %s（已有 %v）", p, entries)
+		}
+	}
+	if e := got["tool.poetry.name"]; src[e.ValStart:e.ValEnd] != `"a"` {
+		t.Errorf("name 的值 = %q", src[e.ValStart:e.ValEnd])
+	}
+	if e := got["tool.poetry.site.url"]; e.KeyDotted != 2 {
+		t.Errorf("site.url KeyDotted = %d", e.KeyDotted)
+	}
+	if e := got["bin.[1].deps"]; e.Line != 9 || e.EndLine != 12 {
+		t.Errorf("多行数组行范围 = L%d-L%d", e.Line, e.EndLine)
+	}
+}
+
+func TestParseTOMLDocErrors(t *testing.T) {
+	bad := map[string]string{
+		"重复键":     "a = 1\na = 2\n",
+		"重复表":     "[a]\n[a]\n",
+		"表头未闭合":   "[a\n",
+		"字符串未闭合":  "a = \"x\n",
+		"缺少值":     "a =\n",
+		"值后有多余内容": "a = 1 2\n",
+		"非法字面量":   "a = yes\n",
+	}
+	for name, src := range bad {
+		if _, err := ParseTOMLDoc([]byte(src)); err == nil {
+			t.Errorf("%s：应报错", name)
+		}
+	}
+	ok := "a = 1_000\nb = 1.5e3\nc = true\nd = 1979-05-27T07:32:00Z\ne = { x = 1, y = [1, 2] }\nf = \"\"\"\nmulti\n\"\"\"\n"
+	if _, err := ParseTOMLDoc([]byte(ok)); err != nil {
+		t.Errorf("合法文档报错：%v", err)
+	}
+}
@@ -17,10 +17,11 @@ func toCRLF(b []byte) []byte {
 	return bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
 }
 
-// 原子写入：tmp -> fsync -> rename，保持权限与 mtime
-func atomicWrite(abs string, data []byte, mode os.FileMode, mtime time.Time) error {
+// AtomicWrite 原子写入：tmp -> fsync -> rename，保持权限与 mtime（mtime 为零值时不设置）
+// 预检与 fileops 共用这一写入层。
+func AtomicWrite(abs string, data []byte, mode os.FileMode, mtime time.Time) error {
 	dir := filepath.Dir(abs)
-	tmpf, err := os.CreateTemp(dir, ".xgit_preflight_*")
+	tmpf, err := os.CreateTemp(dir, ".xgit_tmp_*")
 	if err != nil {
 		return err
 	}
@@ -48,15 +49,49 @@ func atomicWrite(abs string, data []byte, mode os.FileMode, mtime time.Time) err
 	return nil
 }
 
-// DetectLangByExt 返回文件语言标签，供预检路由使用。
+// DetectLangByExt 返回文件语言标签，供预检路由与块边界识别（end=auto）使用。
 // 需要的话后续再扩展其它语言/后缀。
 func DetectLangByExt(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".go":
 		return "go"
-	case ".json":
+	case ".json", ".jsonc":
 		return "json"
+	case ".c", ".h":
+		return "c"
+	case ".cc", ".cpp", ".cxx", ".hpp", ".hh":
+		return "cpp"
+	case ".java":
+		return "java"
+	case ".kt", ".kts":
+		return "kotlin"
+	case ".cs":
+		return "csharp"
+	case ".swift":
+		return "swift"
+	case ".js", ".mjs", ".cjs", ".jsx":
+		return "js"
+	case ".ts", ".tsx":
+		return "ts"
+	case ".rs":
+		return "rust"
+	case ".css", ".scss", ".less":
+		return "css"
+	case ".py", ".pyi":
+		return "python"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	case ".ini":
+		return "ini"
+	case ".env":
+		return "env"
+	case ".html", ".htm":
+		return "html"
+	case ".xml", ".svg", ".xhtml":
+		return "xml"
 	default:
 		return ""
 	}
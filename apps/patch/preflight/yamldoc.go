@@ -0,0 +1,300 @@
+package preflight
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// YAML 块结构扫描（不做完整的 YAML 解析）：按缩进识别映射条目与序列项及其行范围，
+// 供 yaml.* 指令按行编辑，也供预检做基本结构校验：
+//   - 缩进不能用 tab，回退的缩进必须对齐到已有层级
+//   - 同一层级不能混用序列项与映射键，同一映射中键不能重复
+//   - 普通标量值之后不能再出现更深缩进的键 / 序列项
+// 行内的流式集合（{..} / [..]）、引号字符串、块标量（| / >）的内容整体视为值，不再深入。
+//
+
+// YAMLNode 一个映射条目或序列项
+type YAMLNode struct {
+	Key      string // 映射键（已去引号）；序列项为 ""
+	Seq      bool   // 序列项（- ）
+	Col      int    // 键或 "-" 所在列
+	Line     int    // 条目所在行
+	End      int    // 条目结束行（不含；已去掉末尾的空行与注释行）
+	ColonEnd int    // 映射键冒号之后的列；序列项为 "-" 之后的列；不透明条目为 -1
+	ValCol   int    // 行内值起始列；无行内值时为 -1
+	ValEnd   int    // 行内值结束列（行内注释之前）
+	Inline   bool   // 序列项的子映射从 "- " 同一行开始（- name: x）
+	Children []*YAMLNode
+}
+
+// YAMLDocuments 按 --- / ... 切分多文档，返回各文档的行范围 [from, to)
+func YAMLDocuments(lines []string) [][2]int {
+	var out [][2]int
+	from, content := 0, false
+	for i, l := range lines {
+		if strings.HasPrefix(l, "---") && (len(l) == 3 || l[3] == ' ' || l[3] == '\t') || l == "..." {
+			if content {
+				out = append(out, [2]int{from, i})
+			}
+			from, content = i+1, false
+			continue
+		}
+		if t := strings.TrimSpace(l); t != "" && t[0] != '#' && t[0] != '%' {
+			content = true
+		}
+	}
+	if content || len(out) == 0 {
+		out = append(out, [2]int{from, len(lines)})
+	}
+	return out
+}
+
+// ParseYAMLDoc 扫描 lines[from:to] 中的一个文档，返回顶层条目
+func ParseYAMLDoc(lines []string, from, to int) ([]*YAMLNode, error) {
+	p := &yamlParser{lines: lines}
+	return p.block(from, to, -1)
+}
+
+// ValidateYAML 逐文档做结构校验
+func ValidateYAML(src []byte) error {
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	for _, d := range YAMLDocuments(lines) {
+		if _, err := ParseYAMLDoc(lines, d[0], d[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type yamlParser struct{ lines []string }
+
+func (p *yamlParser) errorf(line int, format string, a ...any) error {
+	return fmt.Errorf("第 %d 行：%s", line+1, fmt.Sprintf(format, a...))
+}
+
+// significant 非空、非注释行的内容起始列；firstCol ≥ 0 时 from 行从该列开始
+func (p *yamlParser) significant(i, from, firstCol int) (int, bool) {
+	l := p.lines[i]
+	col := len(l) - len(strings.TrimLeft(l, " "))
+	if i == from && firstCol >= 0 {
+		col = firstCol
+	}
+	t := strings.TrimSpace(l[col:])
+	if t == "" || t[0] == '#' {
+		return 0, false
+	}
+	return col, true
+}
+
+func (p *yamlParser) block(from, to, firstCol int) ([]*YAMLNode, error) {
+	var nodes []*YAMLNode
+	base := -1
+	for i := from; i < to; i++ {
+		col, ok := p.significant(i, from, firstCol)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(p.lines[i][col:], "\t") {
+			return nil, p.errorf(i, "缩进中不能使用 tab")
+		}
+		if base < 0 {
+			base = col
+		}
+		switch {
+		case col < base:
+			return nil, p.errorf(i, "缩进与同级条目不一致")
+		case col > base:
+			continue
+		}
+		content := p.lines[i][col:]
+		// 映射键下的序列可以与键同列（key:\n- a）
+		if n := len(nodes); n > 0 && !nodes[n-1].Seq && nodes[n-1].ValCol < 0 && yamlSeqItem(content) {
+			continue
+		}
+		n := &YAMLNode{Col: col, Line: i, ValCol: -1}
+		if yamlSeqItem(content) {
+			n.Seq, n.ColonEnd = true, col+1
+		} else if key, colon, ok := yamlMapKey(content); ok {
+			n.Key, n.ColonEnd = key, col+colon+1
+		} else if strings.HasPrefix(content, "? ") || content == "?" {
+			n.Key, n.ColonEnd = content, -1 // 复杂键：整体视为不透明条目
+		} else if len(nodes) == 0 {
+			n.ColonEnd, n.ValCol, n.ValEnd = -1, col, yamlValueEnd(p.lines[i], col) // 文档本身是标量 / 流式集合
+		} else {
+			return nil, p.errorf(i, "无法识别的行（期望 键: 值 或 - 项）")
+		}
+		if len(nodes) > 0 && nodes[0].Seq != n.Seq {
+			return nil, p.errorf(i, "同一层级混用了序列项与映射键")
+		}
+		nodes = append(nodes, n)
+	}
+
+	seen := map[string]int{}
+	for k, n := range nodes {
+		n.End = to
+		if k+1 < len(nodes) {
+			n.End = nodes[k+1].Line
+		}
+		for n.End > n.Line+1 {
+			if _, ok := p.significant(n.End-1, from, firstCol); ok {
+				break
+			}
+			n.End--
+		}
+		if !n.Seq && n.ColonEnd >= 0 {
+			if prev, dup := seen[n.Key]; dup {
+				return nil, p.errorf(n.Line, "键 %q 重复（首次出现在第 %d 行）", n.Key, prev+1)
+			}
+			seen[n.Key] = n.Line
+		}
+		if err := p.value(n, from, firstCol); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// value 解析条目的行内值与子块
+func (p *yamlParser) value(n *YAMLNode, from, firstCol int) error {
+	if n.ColonEnd < 0 {
+		return nil
+	}
+	l := p.lines[n.Line]
+	vc := n.ColonEnd
+	for vc < len(l) && (l[vc] == ' ' || l[vc] == '\t') {
+		vc++
+	}
+	rest := l[vc:]
+	if strings.HasPrefix(rest, "#") {
+		rest = ""
+	}
+	// 只有锚点 / 标签（key: &base）时，后续行仍是子块
+	if f := strings.Fields(rest); len(f) == 1 && (rest[0] == '&' || rest[0] == '!') {
+		rest = ""
+	}
+	if rest == "" {
+		if n.End > n.Line+1 {
+			kids, err := p.block(n.Line+1, n.End, -1)
+			if err != nil {
+				return err
+			}
+			n.Children = kids
+		}
+		return nil
+	}
+	if n.Seq {
+		if _, _, ok := yamlMapKey(rest); ok || yamlSeqItem(rest) {
+			n.Inline = true
+			kids, err := p.block(n.Line, n.End, vc)
+			if err != nil {
+				return err
+			}
+			n.Children = kids
+			return nil
+		}
+	}
+	n.ValCol, n.ValEnd = vc, yamlValueEnd(l, vc)
+	// 普通标量之后不能再出现更深缩进的键 / 序列项（块标量、流式集合、引号字符串的续行除外）
+	if !strings.ContainsRune("|>[{\"'", rune(rest[0])) {
+		for i := n.Line + 1; i < n.End; i++ {
+			if col, ok := p.significant(i, from, firstCol); ok {
+				c := p.lines[i][col:]
+				if _, _, isKey := yamlMapKey(c); isKey || yamlSeqItem(c) {
+					return p.errorf(i, "值 %q 之后出现了更深缩进的键或序列项", strings.TrimSpace(l[vc:n.ValEnd]))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func yamlSeqItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ") || strings.HasPrefix(s, "-\t")
+}
+
+// yamlMapKey 识别 key: value / "key": value / 'key': value，返回键与冒号所在下标
+func yamlMapKey(s string) (string, int, bool) {
+	if s == "" || strings.ContainsRune("[{#&*!|>%@`", rune(s[0])) {
+		return "", 0, false
+	}
+	key, i := "", 0
+	if s[0] == '"' || s[0] == '\'' {
+		end := yamlQuoteEnd(s, 0)
+		if end < 0 {
+			return "", 0, false
+		}
+		key = yamlUnquote(s[:end])
+		i = end
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || s[i] != ':' {
+			return "", 0, false
+		}
+	} else {
+		for i = 0; i < len(s); i++ {
+			if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t') {
+				break
+			}
+			if s[i] == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t') {
+				return "", 0, false
+			}
+		}
+		if i >= len(s) {
+			return "", 0, false
+		}
+		key = strings.TrimRight(s[:i], " \t")
+	}
+	if i+1 < len(s) && s[i+1] != ' ' && s[i+1] != '\t' {
+		return "", 0, false
+	}
+	return key, i, true
+}
+
+// yamlQuoteEnd 从 start 处的引号开始，返回闭合引号之后的下标；本行未闭合返回 -1
+func yamlQuoteEnd(s string, start int) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func yamlUnquote(s string) string {
+	if s[0] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+}
+
+// yamlValueEnd 行内值的结束列（去掉行内注释与尾随空白）
+func yamlValueEnd(l string, vc int) int {
+	end := len(l)
+	if vc < len(l) && (l[vc] == '"' || l[vc] == '\'') {
+		if q := yamlQuoteEnd(l, vc); q > 0 {
+			if c := strings.Index(l[q:], " #"); c >= 0 {
+				end = q + c
+			}
+			return len(strings.TrimRight(l[:end], " \t"))
+		}
+	}
+	for i := vc; i < len(l); i++ {
+		if l[i] == '#' && i > vc && (l[i-1] == ' ' || l[i-1] == '\t') {
+			end = i
+			break
+		}
+	}
+	return len(strings.TrimRight(l[:end], " \t"))
+}
@@ -0,0 +1,69 @@
+package preflight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLDocStructure(t *testing.T) {
+	src := "# top\nname: app # c\njobs:\n  build:\n    steps:\n      - run: a\n        shell: bash\n      - uses: x\nlist: [a, b]\ntext: |\n  line: not a key\n    - nor an item\nlast: 1\n"
+	lines := strings.Split(src, "\n")
+	nodes, err := ParseYAMLDoc(lines, 0, len(lines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, n := range nodes {
+		keys = append(keys, n.Key)
+	}
+	if got := strings.Join(keys, ","); got != "name,jobs,list,text,last" {
+		t.Fatalf("顶层键 = %s", got)
+	}
+	if n := nodes[0]; lines[n.Line][n.ValCol:n.ValEnd] != "app" {
+		t.Errorf("name 的值 = %q（行内注释不属于值）", lines[n.Line][n.ValCol:n.ValEnd])
+	}
+	steps := nodes[1].Children[0].Children[0]
+	if steps.Key != "steps" || len(steps.Children) != 2 || !steps.Children[0].Seq || !steps.Children[0].Inline {
+		t.Fatalf("steps = %+v", steps)
+	}
+	if item := steps.Children[0]; item.End != 7 {
+		t.Errorf("第一个序列项应覆盖 L6-L7，End = %d", item.End)
+	}
+	if text := nodes[3]; text.End != 12 || len(text.Children) != 0 {
+		t.Errorf("块标量内容应整体视为值：%+v", text)
+	}
+}
+
+func TestYAMLDocuments(t *testing.T) {
+	lines := strings.Split("a: 1\n---\nb: 2\n...\n---\nc: 3\n", "\n")
+	docs := YAMLDocuments(lines)
+	if len(docs) != 3 || docs[1] != [2]int{2, 3} || docs[2][0] != 5 {
+		t.Fatalf("文档 = %v", docs)
+	}
+}
+
+func TestValidateYAML(t *testing.T) {
+	ok := []string{
+		"a: 1\nb:\n  - x\n  - y\n",
+		"a: {b: 1, c: [2, 3]}\n",
+		"a: \"x: y\"\nb: 'it''s'\n",
+		"a: 1\n---\na: 2\n",
+	}
+	for _, src := range ok {
+		if err := ValidateYAML([]byte(src)); err != nil {
+			t.Errorf("%q：%v", src, err)
+		}
+	}
+	bad := map[string]string{
+		"tab 缩进":  "a:\n\tb: 1\n",
+		"重复键":     "a: 1\na: 2\n",
+		"混用序列与映射": "a:\n  - x\n  b: 1\n",
+		"标量后出现子键": "a: 1\n  b: 2\n",
+		"缩进回退未对齐": "a:\n    b: 1\n  c: 2\n",
+	}
+	for name, src := range bad {
+		if err := ValidateYAML([]byte(src)); err == nil {
+			t.Errorf("%s：应报错", name)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+// XGIT:BEGIN FILE-HEADER
+// stale.go — 基线提交固定（base:）与逐文件乐观并发（expect-sha256= / expect-lines=）
+// 目标文件与作者所见不一致 → 默认拒绝（stale base）；stale=merge 时以 base 版本为共同祖先做三方合并
+// XGIT:END FILE-HEADER
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"xgit/apps/patch/fileops"
+	"xgit/apps/patch/gitops"
+)
+
+// baseInfo 补丁的基线信息（无 base: 头时 sha 为空）
+type baseInfo struct {
+	sha   string             // base 解析后的完整提交号
+	stale bool               // HEAD 已不等于 base
+	files map[string]*pinned // 批次开始前目标文件的快照（按仓库相对路径）
+}
+
+// pinned 目标文件在批次开始前的内容：base / expect-* 校验都与它比较，批内前序指令的改动不算“被修改”
+type pinned struct {
+	data   []byte
+	exists bool
+	view   []byte // stale=merge 时作者视角的版本（base 上已重放前序指令的结果）；nil 表示尚未合并过
+}
+
+// snapshot 在批次的任何指令执行前记录内容类指令涉及的全部文件（通配按当前工作区展开）
+func (bi *baseInfo) snapshot(repo string, ops []*FileOp, git gitops.GitBackend) {
+	for _, op := range ops {
+		if !isContentOp(op.Cmd) {
+			continue
+		}
+		paths := []string{op.Path}
+		if isMultiTarget(repo, op) {
+			paths, _ = expandTargets(repo, op, git) // 展开失败由指令执行时报错
+		}
+		for _, p := range paths {
+			bi.pin(repo, p)
+		}
+	}
+}
+
+// pin 返回 rel 的快照；批次开始时未记录（如通配在批内才命中的新文件）则以当前内容补记
+func (bi *baseInfo) pin(repo, rel string) *pinned {
+	key := filepath.ToSlash(filepath.Clean(rel))
+	if p := bi.files[key]; p != nil {
+		return p
+	}
+	if bi.files == nil {
+		bi.files = map[string]*pinned{}
+	}
+	data, err := os.ReadFile(filepath.Join(repo, rel))
+	p := &pinned{data: data, exists: err == nil}
+	bi.files[key] = p
+	return p
+}
+
+// resolveBase 解析补丁头 base:，与当前 HEAD 比较
+func resolveBase(repo string, patch *Patch, git gitops.GitBackend) (*baseInfo, error) {
+	bi := &baseInfo{}
+	ref := strings.TrimSpace(patch.Base)
+	if ref == "" {
+		return bi, nil
+	}
+	sha, err := git.RevParse(repo, ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("stale base: base %s 在目标仓库中不存在（是否未 fetch？）", ref)
+	}
+	head, _ := git.RevParse(repo, "HEAD")
+	bi.sha = sha
+	bi.stale = head != sha
+	return bi, nil
+}
+
+// staleMode 指令参数 stale= 优先，其次补丁头 stale:；默认 refuse
+func staleMode(op *FileOp, patch *Patch) string {
+	m := strings.ToLower(strings.TrimSpace(argStr(op.Args, "stale", patch.Stale)))
+	if m == "merge" {
+		return "merge"
+	}
+	return "refuse"
+}
+
+// isContentOp：只改单个文件内容、可在 base 版本上重放后三方合并的指令
+func isContentOp(cmd string) bool {
+	switch {
+	case strings.HasPrefix(cmd, "line."), strings.HasPrefix(cmd, "block."), strings.HasPrefix(cmd, "text."), strings.HasPrefix(cmd, "go.import."),
+		strings.HasPrefix(cmd, "anchor."), strings.HasPrefix(cmd, "json."),
+		strings.HasPrefix(cmd, "yaml."), strings.HasPrefix(cmd, "toml."), strings.HasPrefix(cmd, "ini."), strings.HasPrefix(cmd, "html."),
+		strings.HasPrefix(cmd, "md."):
+		return true
+	}
+	switch cmd {
+	case "file.write", "file.append", "file.prepend", "file.eol", "file.ensure-line", "file.ensure-block":
+		return true
+	}
+	return false
+}
+
+// applyOpPinned 在执行指令前校验目标文件是否仍与作者所见一致；不一致时拒绝或三方合并
+func applyOpPinned(repo string, op *FileOp, patch *Patch, bi *baseInfo, git gitops.GitBackend, logger *DualLogger) error {
+	if !isContentOp(op.Cmd) {
+		return applyOp(repo, op, git, logger)
+	}
+	reason, err := staleReason(repo, op, bi, git)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return applyOp(repo, op, git, logger)
+	}
+	if staleMode(op, patch) != "merge" {
+		return fmt.Errorf("stale base: %s %s（可用 stale=merge 三方合并）", op.Path, reason)
+	}
+	if bi.sha == "" {
+		return fmt.Errorf("stale base: %s %s；三方合并需要补丁头 base:", op.Path, reason)
+	}
+	logger.Log("🔀 %s: %s %s → 基于 %s 三方合并", op.Cmd, op.Path, reason, shortSHA(bi.sha))
+	return applyOpMerged(repo, op, bi, git, logger)
+}
+
+// staleReason 返回不一致原因（空串表示一致）；比较对象是批次开始前的快照
+func staleReason(repo string, op *FileOp, bi *baseInfo, git gitops.GitBackend) (string, error) {
+	snap := bi.pin(repo, op.Path)
+	cur, exists := snap.data, snap.exists
+
+	if want := strings.ToLower(strings.TrimSpace(op.Args["expect-sha256"])); want != "" {
+		if len(want) < 8 {
+			return "", fmt.Errorf("%s: expect-sha256 至少需要 8 位十六进制", op.Cmd)
+		}
+		got := ""
+		if exists {
+			sum := sha256.Sum256(cur)
+			got = hex.EncodeToString(sum[:])
+		}
+		if !strings.HasPrefix(got, want) {
+			return fmt.Sprintf("sha256 不一致（期望 %s，实际 %s）", want, shortSHA(got)), nil
+		}
+	}
+	if raw := strings.TrimSpace(op.Args["expect-lines"]); raw != "" {
+		want := argInt(op.Args, "expect-lines", -1)
+		if want < 0 {
+			return "", fmt.Errorf("%s: expect-lines 非法：%q", op.Cmd, raw)
+		}
+		if got := countLines(cur); got != want {
+			return fmt.Sprintf("行数不一致（期望 %d，实际 %d）", want, got), nil
+		}
+	}
+	if bi.sha != "" && bi.stale {
+		baseData, berr := git.Show(repo, bi.sha, op.Path)
+		switch {
+		case berr != nil && exists:
+			return "在 base 中不存在但当前已存在", nil
+		case berr == nil && !exists:
+			return "在 base 之后被删除", nil
+		case berr == nil && !bytes.Equal(baseData, cur):
+			return "自 base " + shortSHA(bi.sha) + " 以来已被修改", nil
+		}
+	}
+	return "", nil
+}
+
+// applyOpMerged 在 base 版本上重放指令，再以 base 为共同祖先与当前版本做三方合并；
+// 同一文件的后续指令在上一次重放结果上继续重放，并以它为共同祖先
+func applyOpMerged(repo string, op *FileOp, bi *baseInfo, git gitops.GitBackend, logger *DualLogger) error {
+	abs := filepath.Join(repo, op.Path)
+	cur, err := os.ReadFile(abs)
+	if err != nil {
+		return fmt.Errorf("stale base: 读取当前版本失败：%w", err)
+	}
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(abs); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	snap := bi.pin(repo, op.Path)
+	baseData := snap.view
+	if baseData == nil {
+		if baseData, err = git.Show(repo, bi.sha, op.Path); err != nil {
+			return fmt.Errorf("stale base: 读取 base 版本失败：%w", err)
+		}
+	}
+
+	restore := func() { _ = fileops.WriteFileAtomic(abs, cur, mode) }
+	if err := fileops.WriteFileAtomic(abs, baseData, mode); err != nil {
+		return err
+	}
+	if err := applyOp(repo, op, git, logger); err != nil {
+		restore()
+		return fmt.Errorf("在 base 版本上重放失败：%w", err)
+	}
+	theirs, err := os.ReadFile(abs)
+	if err != nil {
+		restore()
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "xgit-merge-*")
+	if err != nil {
+		restore()
+		return err
+	}
+	defer os.RemoveAll(dir)
+	oursP, baseP, theirsP := filepath.Join(dir, "current"), filepath.Join(dir, "base"), filepath.Join(dir, "patched")
+	for p, b := range map[string][]byte{oursP: cur, baseP: baseData, theirsP: theirs} {
+		if err := os.WriteFile(p, b, 0o600); err != nil {
+			restore()
+			return err
+		}
+	}
+	merged, conflict, err := git.MergeFile(repo, oursP, baseP, theirsP)
+	if err != nil {
+		restore()
+		return fmt.Errorf("三方合并失败：%w", err)
+	}
+	if conflict {
+		restore()
+		return fmt.Errorf("stale base: %s 三方合并存在冲突，请基于最新版本重新生成补丁", op.Path)
+	}
+	if err := fileops.WriteFileAtomic(abs, merged, mode); err != nil {
+		return err
+	}
+	snap.view = theirs
+	logger.Log("✅ 三方合并完成：%s", op.Path)
+	return fileops.Preflight(repo, op.Path, logger)
+}
+
+func countLines(b []byte) int {
+	n := bytes.Count(b, []byte{'\n'})
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+func shortSHA(s string) string {
+	if len(s) > 8 {
+		return s[:8]
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"xgit/apps/patch/gitops"
+)
+
+const fakeBase = "feed000000000000000000000000000000000000"
+
+// newStaleFake HEAD 已前进到 fakeHead，base 为 fakeBase；baseFiles 为 base 中的文件内容
+func newStaleFake(repo string, baseFiles map[string]string) *gitops.FakeBackend {
+	f := newTestFake(repo, "a.txt")
+	f.On("rev-parse", func(c gitops.FakeCall) (string, error) {
+		if strings.HasPrefix(c.Args[0], fakeBase) {
+			return fakeBase, nil
+		}
+		return fakeHead, nil
+	})
+	for p, b := range baseFiles {
+		f.Files[fakeBase+":"+p] = b
+	}
+	return f
+}
+
+// 第二条指令看到的是第一条指令的改动，不能因此被判为 stale
+func TestStaleCheckUsesBatchSnapshot(t *testing.T) {
+	const orig = "a\nb\nc\n"
+	repo := newTestRepo(t, map[string]string{"a.txt": orig})
+	git := newStaleFake(repo, map[string]string{"a.txt": orig})
+	sum := sha256.Sum256([]byte(orig))
+	sha := hex.EncodeToString(sum[:])[:12]
+	logger, buf := testLogger()
+	patch := &Patch{Base: fakeBase, Ops: []*FileOp{
+		{Cmd: "line.insert", Path: "a.txt", Body: "x\n", Args: map[string]string{"keys": "b", "expect-sha256": sha, "expect-lines": "3"}},
+		{Cmd: "line.append", Path: "a.txt", Body: "y\n", Args: map[string]string{"keys": "c", "expect-sha256": sha, "expect-lines": "3"}},
+	}}
+	if !runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatalf("runBatch 失败：\n%s", buf)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(got) != "a\nx\nb\nc\ny\n" {
+		t.Fatalf("a.txt = %q", got)
+	}
+}
+
+func TestStaleRefuseWhenChangedSinceBase(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"a.txt": "a\nb\nc\nd\n"})
+	git := newStaleFake(repo, map[string]string{"a.txt": "a\nb\nc\n"})
+	logger, buf := testLogger()
+	patch := &Patch{Base: fakeBase, Ops: []*FileOp{
+		{Cmd: "line.insert", Path: "a.txt", Body: "x\n", Args: map[string]string{"keys": "b"}},
+	}}
+	if runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatal("自 base 以来被修改的文件应被拒绝")
+	}
+	if !strings.Contains(buf.String(), "自 base") {
+		t.Errorf("日志缺少 stale 原因：\n%s", buf)
+	}
+}
+
+// stale=merge：同一文件的第二条指令锚定在第一条指令新增的行上
+func TestStaleMergeChainsOpsOnSameFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("需要 git merge-file")
+	}
+	repo := newTestRepo(t, map[string]string{"a.txt": "a\nb\nc\nd\ne\nf\nlocal\n"})
+	git := newStaleFake(repo, map[string]string{"a.txt": "a\nb\nc\nd\ne\nf\n"})
+	real := gitops.NewExecBackend()
+	git.On("merge-file", func(c gitops.FakeCall) (string, error) {
+		out, conflict, err := real.MergeFile(repo, c.Args[0], c.Args[1], c.Args[2])
+		if conflict {
+			return string(out), gitops.ErrFakeConflict
+		}
+		return string(out), err
+	})
+	logger, buf := testLogger()
+	patch := &Patch{Base: fakeBase, Stale: "merge", Ops: []*FileOp{
+		{Cmd: "line.append", Path: "a.txt", Body: "x\n", Args: map[string]string{"keys": "a"}},
+		{Cmd: "line.append", Path: "a.txt", Body: "y\n", Args: map[string]string{"keys": "x", "match": "exact"}},
+	}}
+	if !runBatch(logger, repo, patch, git, nil, 0) {
+		t.Fatalf("runBatch 失败：\n%s", buf)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(got) != "a\nx\ny\nb\nc\nd\ne\nf\nlocal\n" {
+		t.Fatalf("a.txt = %q", got)
+	}
+}